@@ -82,14 +82,23 @@ type Blockchain struct {
 
 	checkpints_disabled bool // are checkpoints disabled
 	simulator           bool // is simulator mode
+	prune               bool // discard prunable tx data of stable blocks covered by checkpoints
+	index               bool // maintain secondary indexes
+	sc_trace            bool // store execution trace of every SC tx
+
+	prune_event chan bool // wakes up prune worker
 
 	P2P_Block_Relayer func(*block.Complete_Block, uint64) // tell p2p to broadcast any block this daemon hash found
 
+	events event_dispatcher // subscribers to chain events
+
 	sync.RWMutex
 }
 
 var logger *log.Entry
 
+var metrics_once sync.Once // metrics are registered globally
+
 //var Exit_Event = make(chan bool) // causes all threads to exit
 
 // All blockchain activity is store in a single
@@ -109,7 +118,10 @@ func Blockchain_Start(params map[string]interface{}) (*Blockchain, error) {
 	//init_static_checkpoints()           // init some hard coded checkpoints
 	checkpoints.LoadCheckPoints(logger) // load checkpoints from file if provided
 
-	if params["--simulator"] == true { // simulator always uses boltdb backend
+	if params["--memorydb"] == true { // nothing touches the disk, can be combined with simulator
+		chain.store = &storage.MemoryStore{} // setup backend, every chain gets its own
+		chain.store.Init(params)             // init backend
+	} else if params["--simulator"] == true { // simulator always uses boltdb backend
 		chain.store = storage.Bolt_backend // setup backend
 		chain.store.Init(params)           // init backend
 
@@ -151,10 +163,28 @@ func Blockchain_Start(params map[string]interface{}) (*Blockchain, error) {
 		chain.simulator = true // enable simulator mode, this will set hard coded difficulty to 1
 	}
 
+	if params["--prune"] == true {
+		chain.prune = true // enable pruning of old prunable data
+	}
+
+	if params["--index"] == true {
+		chain.index = true // maintain secondary indexes
+	}
+
+	if params["--sc-trace"] == true {
+		chain.sc_trace = true // store SC execution traces
+	}
+
 	chain.Exit_Event = make(chan bool) // init exit channel
 
+	if chain.prune {
+		chain.prune_event = make(chan bool, 1)
+		go chain.prune_worker()
+	}
+
 	// init mempool before chain starts
 	chain.Mempool, err = mempool.Init_Mempool(params)
+	chain.Mempool.TX_Event_Notifier = chain.mempool_event // mempool changes are delivered to subscribers
 
 	// we need to check mainnet/testnet check whether the genesis block matches the testnet/mainet
 	// mean whether the user is trying to use mainnet db with testnet option or vice-versa
@@ -245,6 +275,14 @@ func Blockchain_Start(params map[string]interface{}) (*Blockchain, error) {
 	// hard forks must be initialized after chain is up
 	init_hard_forks(params)
 
+	// catch up index, in case daemon was previously run without it
+	if chain.index {
+		if err = chain.build_index(); err != nil {
+			logger.Warnf("Index could not be built err %s", err)
+			return nil, err
+		}
+	}
+
 	go clean_up_valid_cache() // clean up valid cache
 
 	/*  txlist := chain.Mempool.Mempool_List_TX()
@@ -267,14 +305,16 @@ func Blockchain_Start(params map[string]interface{}) (*Blockchain, error) {
 
 	atomic.AddUint32(&globals.Subsystem_Active, 1) // increment subsystem
 
-	// register the metrics with the metrics registry
-	metrics.Registry.MustRegister(blockchain_tx_counter)
-	metrics.Registry.MustRegister(mempool_tx_counter)
-	metrics.Registry.MustRegister(mempool_tx_count)
-	metrics.Registry.MustRegister(block_size)
-	metrics.Registry.MustRegister(transaction_size)
-	metrics.Registry.MustRegister(block_tx_count)
-	metrics.Registry.MustRegister(block_processing_time)
+	// register the metrics with the metrics registry, only once even if chain is started again
+	metrics_once.Do(func() {
+		metrics.Registry.MustRegister(blockchain_tx_counter)
+		metrics.Registry.MustRegister(mempool_tx_counter)
+		metrics.Registry.MustRegister(mempool_tx_count)
+		metrics.Registry.MustRegister(block_size)
+		metrics.Registry.MustRegister(transaction_size)
+		metrics.Registry.MustRegister(block_tx_count)
+		metrics.Registry.MustRegister(block_processing_time)
+	})
 
 	return &chain, nil
 }
@@ -690,6 +730,8 @@ func (chain *Blockchain) Add_Complete_Block(cbl *block.Complete_Block) (err erro
 
 			rlog.Infof("Block successfully acceppted by chain %s", block_hash)
 
+			chain.publish_pending_events() // changes are now visible, let subscribers know
+
 			// gracefully try to instrument
 			func() {
 				defer func() {
@@ -716,6 +758,10 @@ func (chain *Blockchain) Add_Complete_Block(cbl *block.Complete_Block) (err erro
 				}
 			}()
 
+			if chain.prune { // prune in background, as we are holding the lock
+				chain.trigger_prune()
+			}
+
 			//dbtx.Sync() // sync the DB to disk after every execution of this function
 
 			//if old_top != chain.Load_TOP_ID() { // if top has changed, discard mining templates and start afresh
@@ -724,6 +770,7 @@ func (chain *Blockchain) Add_Complete_Block(cbl *block.Complete_Block) (err erro
 			//}
 		} else {
 			dbtx.Rollback() // if block could not be added, rollback all changes to previous block
+			chain.discard_pending_events()
 			rlog.Infof("Block rejected by chain %s err %s", block_hash, err)
 		}
 	}()
@@ -1032,6 +1079,10 @@ skip_checks:
 
 	chain.Store_BL(dbtx, bl)
 
+	if chain.index {
+		chain.index_block(dbtx, bl, block_hash)
+	}
+
 	// if the block is on a lower height tip, the block will not increase chain height
 	height := chain.Load_Height_for_BL_ID(dbtx, block_hash)
 	if height > chain.Get_Height() || height == 0 { // exception for genesis block
@@ -1099,6 +1150,17 @@ skip_checks:
 			base_topo_index = 0
 		}
 
+		// collect existing order, so as subscribers can be informed if it changes
+		var old_order []crypto.Hash
+		for i := base_topo_index; len(bl.Tips) != 0 && i <= last_topo_height; i++ {
+			if blid, err := chain.Load_Block_Topological_order_at_index(dbtx, i); err == nil {
+				old_order = append(old_order, blid)
+			}
+		}
+		if is_topo_reordered(old_order, full_order) {
+			chain.queue_event(Event{Type: EVENT_TOPO_REORDER, TopoHeight: base_topo_index, Old_Order: old_order, New_Order: full_order})
+		}
+
 		// run the client_protocol_reverse , till we reach the base block
 		for last_topo_height > 0 {
 			last_topo_block, err := chain.Load_Block_Topological_order_at_index(dbtx, last_topo_height)
@@ -1129,125 +1191,14 @@ skip_checks:
 		// TODO FIXME we must avoid reprocessing  base block and or duplicate blocks, no point in reprocessing it
 		for i := int64(0); i < int64(len(full_order)); i++ {
 
-			chain.Store_Block_Topological_order(dbtx, full_order[i], i+base_topo_index)
 			highest_topo = base_topo_index + i
 
 			rlog.Debugf("%d %s   topo_index %d  base topo %d", i, full_order[i], i+base_topo_index, base_topo_index)
 
-			// TODO we must run smart contracts and TXs in this order
-			// basically client protocol must run here
-			// even if the HF has triggered we may still accept, old blocks for some time
-			// so hf is detected block-wise and processed as such
-
-			bl_current_hash := full_order[i]
-			bl_current, err1 := chain.Load_BL_FROM_ID(dbtx, bl_current_hash)
-			if err1 != nil {
-				block_logger.Debugf("Cannot load block %s for client protocol,probably DB corruption", bl_current_hash)
+			if !chain.process_topo_block(dbtx, full_order[i], highest_topo) {
 				return errormsg.ErrInvalidBlock, false
 			}
 
-			height_current := chain.Calculate_Height_At_Tips(dbtx, bl_current.Tips)
-			hard_fork_version_current := chain.Get_Current_Version_at_Height(height_current)
-
-			//  run full client protocol and find valid transactions
-			// find all transactions within this block which are NOT double-spend
-			// if any double-SPEND are found ignore them, else collect their fees to give to miner
-			total_fees := chain.client_protocol(dbtx, bl_current, bl_current_hash, height_current, highest_topo)
-
-			rlog.Debugf("running client protocol for %s minertx %s  topo %d", bl_current_hash, bl_current.Miner_TX.GetHash(), highest_topo)
-
-			// store and parse miner tx
-			chain.Store_TX(dbtx, &bl_current.Miner_TX)
-			chain.Store_TX_Height(dbtx, bl_current.Miner_TX.GetHash(), highest_topo)
-
-			// mark TX found in this block also  for explorer
-			chain.store_TX_in_Block(dbtx, bl_current_hash, bl_current.Miner_TX.GetHash())
-
-			//mark tx found in this block is valid
-			chain.mark_TX(dbtx, bl_current_hash, bl_current.Miner_TX.GetHash(), true)
-
-			// hard fork version is used to import transactions from earlier version of DERO chain
-			// in order to keep things simple, the earlier emission/fees calculation/dynamic block size has been discarded
-			// due to above reasons miner TX from the earlier could NOT be verified
-			// emission calculations/ total supply should NOT change when importing earlier chain
-			if hard_fork_version_current == 1 {
-
-				// store total  reward
-				dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_MINERTX_REWARD, bl_current.Miner_TX.Vout[0].Amount)
-
-				// store base reward
-				dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_BASEREWARD, bl_current.Miner_TX.Vout[0].Amount)
-
-				// store total generated coins
-				// this is hardcoded at initial chain import, keeping original emission schedule
-				if globals.IsMainnet() {
-					dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_ALREADY_GENERATED_COINS, config.MAINNET_HARDFORK_1_TOTAL_SUPPLY)
-				} else {
-					dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_ALREADY_GENERATED_COINS, config.TESTNET_HARDFORK_1_TOTAL_SUPPLY)
-				}
-
-			} else { //  hf 2 or later generate miner TX rewards as per client protocol
-
-				past_coins_generated := chain.Load_Already_Generated_Coins_for_Topo_Index(dbtx, highest_topo-1)
-
-				base_reward := emission.GetBlockReward_Atlantis(hard_fork_version_current, past_coins_generated)
-
-				// base reward is only 90%, rest 10 % is pushed back
-				if globals.IsMainnet() {
-					base_reward = (base_reward * 9) / 10
-				}
-
-				// lower reward for byzantine behaviour
-				// for as many block as added
-				if chain.isblock_SideBlock(dbtx, bl_current_hash, highest_topo) { // lost race (or byzantine behaviour)
-					if hard_fork_version_current == 2 {
-						base_reward = (base_reward * 67) / 100 // give only 67 % reward
-					} else {
-						base_reward = (base_reward * 8) / 100 // give only 8 % reward
-					}
-				}
-
-				// logger.Infof("past coins generated %d base reward %d", past_coins_generated, base_reward)
-
-				// the total reward must be given to the miner TX, since it contains 0, we patch only the output
-				// and leave the original TX untouched
-				total_reward := base_reward + total_fees
-
-				// store total  reward
-				dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_MINERTX_REWARD, total_reward)
-
-				// store base reward
-				dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_BASEREWARD, base_reward)
-
-				// store total generated coins
-				dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_ALREADY_GENERATED_COINS, past_coins_generated+base_reward)
-
-				//logger.Infof("base reward %s  total generated %s",globals.FormatMoney12(base_reward), globals.FormatMoney12(past_coins_generated+base_reward))
-
-			}
-
-			// TODO FIXME valid transactions must be found and thier fees should be added as reward
-
-			// output index starts from the ending of the previous block
-
-			// get previous block
-			output_index_start := int64(0)
-			if (highest_topo - 1) >= 0 {
-				previous_block, err1 := chain.Load_Block_Topological_order_at_index(dbtx, highest_topo-1)
-				if err1 != nil {
-					logger.Warnf("Errr could not find topo index of previous block")
-					return errormsg.ErrInvalidBlock, false
-				}
-				// we will start where the previous block vouts ended
-				_, output_index_start = chain.Get_Block_Output_Index(dbtx, previous_block)
-			}
-			if !chain.write_output_index(dbtx, bl_current_hash, output_index_start, hard_fork_version_current) {
-				logger.Warnf("Since output index data cannot be wrritten, skipping block")
-				return errormsg.ErrInvalidBlock, false
-			}
-
-			// this tx must be stored, linked with this block
-
 		}
 
 		chain.Store_TOPO_HEIGHT(dbtx, int64(highest_topo))
@@ -1302,8 +1253,14 @@ skip_checks:
 		block_logger.Infof("Chain Height %d", chain.Height)
 	}
 
+	if chain.index {
+		chain.store_index_topoheight(dbtx, chain.Load_TOPO_HEIGHT(dbtx))
+	}
+
 	result = true
 
+	chain.queue_event(Event{Type: EVENT_NEW_BLOCK, BLID: block_hash, Height: chain.Load_Height_for_BL_ID(dbtx, block_hash), TopoHeight: chain.Load_TOPO_HEIGHT(dbtx)})
+
 	// TODO fix hard fork
 	// maintain hard fork votes to keep them SANE
 	//chain.Recount_Votes() // does not return anything
@@ -1336,6 +1293,126 @@ skip_checks:
 	return // run any handlers necesary to atomically
 }
 
+// places block at topoheight and runs client protocol for it, followed by rewards and output index
+// the block and its txs must already be stored
+func (chain *Blockchain) process_topo_block(dbtx storage.DBTX, bl_current_hash crypto.Hash, highest_topo int64) bool {
+	chain.Store_Block_Topological_order(dbtx, bl_current_hash, highest_topo)
+
+	// TODO we must run smart contracts and TXs in this order
+	// basically client protocol must run here
+	// even if the HF has triggered we may still accept, old blocks for some time
+	// so hf is detected block-wise and processed as such
+
+	bl_current, err1 := chain.Load_BL_FROM_ID(dbtx, bl_current_hash)
+	if err1 != nil {
+		logger.Debugf("Cannot load block %s for client protocol,probably DB corruption", bl_current_hash)
+		return false
+	}
+
+	height_current := chain.Calculate_Height_At_Tips(dbtx, bl_current.Tips)
+	hard_fork_version_current := chain.Get_Current_Version_at_Height(height_current)
+
+	//  run full client protocol and find valid transactions
+	// find all transactions within this block which are NOT double-spend
+	// if any double-SPEND are found ignore them, else collect their fees to give to miner
+	total_fees := chain.client_protocol(dbtx, bl_current, bl_current_hash, height_current, highest_topo)
+
+	rlog.Debugf("running client protocol for %s minertx %s  topo %d", bl_current_hash, bl_current.Miner_TX.GetHash(), highest_topo)
+
+	// store and parse miner tx
+	chain.Store_TX(dbtx, &bl_current.Miner_TX)
+	chain.Store_TX_Height(dbtx, bl_current.Miner_TX.GetHash(), highest_topo)
+
+	// mark TX found in this block also  for explorer
+	chain.store_TX_in_Block(dbtx, bl_current_hash, bl_current.Miner_TX.GetHash())
+
+	//mark tx found in this block is valid
+	chain.mark_TX(dbtx, bl_current_hash, bl_current.Miner_TX.GetHash(), true)
+
+	// hard fork version is used to import transactions from earlier version of DERO chain
+	// in order to keep things simple, the earlier emission/fees calculation/dynamic block size has been discarded
+	// due to above reasons miner TX from the earlier could NOT be verified
+	// emission calculations/ total supply should NOT change when importing earlier chain
+	if hard_fork_version_current == 1 {
+
+		// store total  reward
+		dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_MINERTX_REWARD, bl_current.Miner_TX.Vout[0].Amount)
+
+		// store base reward
+		dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_BASEREWARD, bl_current.Miner_TX.Vout[0].Amount)
+
+		// store total generated coins
+		// this is hardcoded at initial chain import, keeping original emission schedule
+		if globals.IsMainnet() {
+			dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_ALREADY_GENERATED_COINS, config.MAINNET_HARDFORK_1_TOTAL_SUPPLY)
+		} else {
+			dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_ALREADY_GENERATED_COINS, config.TESTNET_HARDFORK_1_TOTAL_SUPPLY)
+		}
+
+	} else { //  hf 2 or later generate miner TX rewards as per client protocol
+
+		past_coins_generated := chain.Load_Already_Generated_Coins_for_Topo_Index(dbtx, highest_topo-1)
+
+		base_reward := emission.GetBlockReward_Atlantis(hard_fork_version_current, past_coins_generated)
+
+		// base reward is only 90%, rest 10 % is pushed back
+		if globals.IsMainnet() {
+			base_reward = (base_reward * 9) / 10
+		}
+
+		// lower reward for byzantine behaviour
+		// for as many block as added
+		if chain.isblock_SideBlock(dbtx, bl_current_hash, highest_topo) { // lost race (or byzantine behaviour)
+			if hard_fork_version_current == 2 {
+				base_reward = (base_reward * 67) / 100 // give only 67 % reward
+			} else {
+				base_reward = (base_reward * 8) / 100 // give only 8 % reward
+			}
+		}
+
+		// logger.Infof("past coins generated %d base reward %d", past_coins_generated, base_reward)
+
+		// the total reward must be given to the miner TX, since it contains 0, we patch only the output
+		// and leave the original TX untouched
+		total_reward := base_reward + total_fees
+
+		// store total  reward
+		dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_MINERTX_REWARD, total_reward)
+
+		// store base reward
+		dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_BASEREWARD, base_reward)
+
+		// store total generated coins
+		dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, bl_current_hash[:], PLANET_ALREADY_GENERATED_COINS, past_coins_generated+base_reward)
+
+		//logger.Infof("base reward %s  total generated %s",globals.FormatMoney12(base_reward), globals.FormatMoney12(past_coins_generated+base_reward))
+
+	}
+
+	// TODO FIXME valid transactions must be found and thier fees should be added as reward
+
+	// output index starts from the ending of the previous block
+
+	// get previous block
+	output_index_start := int64(0)
+	if (highest_topo - 1) >= 0 {
+		previous_block, err1 := chain.Load_Block_Topological_order_at_index(dbtx, highest_topo-1)
+		if err1 != nil {
+			logger.Warnf("Errr could not find topo index of previous block")
+			return false
+		}
+		// we will start where the previous block vouts ended
+		_, output_index_start = chain.Get_Block_Output_Index(dbtx, previous_block)
+	}
+	if !chain.write_output_index(dbtx, bl_current_hash, output_index_start, hard_fork_version_current) {
+		logger.Warnf("Since output index data cannot be wrritten, skipping block")
+		return false
+	}
+
+	// this tx must be stored, linked with this block
+	return true
+}
+
 // runs the client protocol which includes the following operations
 // if any TX are being duplicate or double-spend ignore them
 // mark all the valid transactions as valid
@@ -1366,6 +1443,10 @@ func (chain *Blockchain) client_protocol(dbtx storage.DBTX, bl *block.Block, bli
 			// execute SC TX if HF is active
 			if bl.Major_Version >= 4 {
 				chain.Process_SC(dbtx, bl, tx, int64(bl.Major_Version))
+
+				if chain.index {
+					chain.index_sc_tx(dbtx, tx, topoheight, true)
+				}
 			}
 
 		} else { // TX is double spend or reincluded by 2 blocks simultaneously
@@ -1388,6 +1469,10 @@ func (chain *Blockchain) client_protocol_reverse(dbtx storage.DBTX, bl *block.Bl
 		}
 		// only the  valid TX must be revoked
 		if chain.IS_TX_Valid(dbtx, blid, bl.Tx_hashes[i]) {
+			if chain.index && bl.Major_Version >= 4 { // must be done before tx is unlinked from topo height
+				chain.index_sc_tx(dbtx, tx, chain.Load_TX_Height(dbtx, bl.Tx_hashes[i]), false)
+			}
+
 			chain.revoke_keyimages(dbtx, tx) // mark key images as not used
 
 			chain.Store_TX_Height(dbtx, bl.Tx_hashes[i], -1) // unlink the tx with the topo height
@@ -1752,11 +1837,16 @@ func (chain *Blockchain) Rewind_Chain(rewind_count int) (result bool) {
 		if result == true { // block was successfully added, commit it atomically
 			dbtx.Commit()
 			dbtx.Sync() // sync the DB to disk after every execution of this function
+			chain.publish_pending_events()
 		} else {
 			dbtx.Rollback() // if block could not be added, rollback all changes to previous block
+			chain.discard_pending_events()
 		}
 	}()
 
+	// blocks removed from topo order are reported as a reorder with empty new order
+	removed_order := map[int64]crypto.Hash{}
+
 	// we must always rewind till a safety point is found
 	stable_points := map[int64]bool{}
 
@@ -1826,6 +1916,14 @@ func (chain *Blockchain) Rewind_Chain(rewind_count int) (result bool) {
 			// run client protocol in reverse
 			chain.client_protocol_reverse(dbtx, bl_current, blid)
 
+			if chain.index {
+				chain.unindex_block(dbtx, bl_current, blid)
+			}
+
+			if chain.Is_Block_Topological_order(dbtx, blid) {
+				removed_order[chain.Load_Block_Topological_order(dbtx, blid)] = blid
+			}
+
 			// delete the tip
 			tips := chain.load_TIPS(dbtx)
 			new_tips := []crypto.Hash{}
@@ -1882,6 +1980,26 @@ func (chain *Blockchain) Rewind_Chain(rewind_count int) (result bool) {
 	}
 	rlog.Infof("height after rewind %d", chain.Load_TOPO_HEIGHT(dbtx))
 
+	chain.rewind_pruned_topoheight(dbtx, chain.Load_TOPO_HEIGHT(dbtx))
+
+	if chain.index {
+		chain.store_index_topoheight(dbtx, chain.Load_TOPO_HEIGHT(dbtx))
+	}
+
+	if len(removed_order) > 0 {
+		var topoheights []int64
+		for topoheight := range removed_order {
+			topoheights = append(topoheights, topoheight)
+		}
+		sort.Slice(topoheights, func(i, j int) bool { return topoheights[i] < topoheights[j] })
+
+		var old_order []crypto.Hash
+		for i := range topoheights {
+			old_order = append(old_order, removed_order[topoheights[i]])
+		}
+		chain.queue_event(Event{Type: EVENT_TOPO_REORDER, TopoHeight: topoheights[0], Old_Order: old_order})
+	}
+
 	return true
 }
 
@@ -0,0 +1,197 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+// this file implements event subscription, so as rpcserver, explorer, miner etc can learn about chain changes without polling
+// events generated while processing a block are queued and only delivered after the DB TX commits
+// so subscribers never see changes which are later rolled back
+// delivery is non-blocking, if a subscriber is not reading fast enough, its events are dropped
+import "sync"
+import "sync/atomic"
+
+import "github.com/romana/rlog"
+
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/transaction"
+
+type Event_Type int
+
+const (
+	EVENT_NEW_BLOCK    Event_Type = 1 << iota // block added to chain
+	EVENT_TOPO_REORDER                        // topological order of already ordered blocks changed
+	EVENT_TX_ADDED                            // tx added to mempool
+	EVENT_TX_REMOVED                          // tx removed from mempool, either mined or discarded
+	EVENT_SC_CHANGED                          // SC state changed by a tx, or change was reverted
+
+	EVENT_ALL = EVENT_NEW_BLOCK | EVENT_TOPO_REORDER | EVENT_TX_ADDED | EVENT_TX_REMOVED | EVENT_SC_CHANGED
+)
+
+func (t Event_Type) String() string {
+	switch t {
+	case EVENT_NEW_BLOCK:
+		return "new_block"
+	case EVENT_TOPO_REORDER:
+		return "topo_reorder"
+	case EVENT_TX_ADDED:
+		return "tx_added"
+	case EVENT_TX_REMOVED:
+		return "tx_removed"
+	case EVENT_SC_CHANGED:
+		return "sc_changed"
+	}
+	return "unknown"
+}
+
+// only the fields relevant to the type are filled
+type Event struct {
+	Type       Event_Type
+	BLID       crypto.Hash   // new block
+	Height     int64         // new block
+	TopoHeight int64         // new block, topoheight at which old and new order start for reorder
+	Old_Order  []crypto.Hash // topo reorder, blocks which were ordered before
+	New_Order  []crypto.Hash // topo reorder, blocks which are ordered now
+	TXID       crypto.Hash   // mempool tx, or tx which changed SC state
+	SCID       crypto.Hash   // SC whose state changed
+	Reverted   bool          // SC change was reverted
+}
+
+type subscriber struct {
+	events  chan Event
+	mask    Event_Type
+	dropped uint64 // events dropped since subscriber was slow
+}
+
+// subscribers are protected by their own lock, as mempool events are raised without holding chain lock
+type event_dispatcher struct {
+	subscribers map[<-chan Event]*subscriber
+	pending     []Event // events queued while chain lock is held, delivered on commit
+	sync.Mutex
+}
+
+// subscribe to events of given types ( may be ORed together ), buffer is the channel capacity
+func (chain *Blockchain) Subscribe(mask Event_Type, buffer int) <-chan Event {
+	if buffer < 1 {
+		buffer = 1
+	}
+	s := &subscriber{events: make(chan Event, buffer), mask: mask}
+
+	chain.events.Lock()
+	defer chain.events.Unlock()
+	if chain.events.subscribers == nil {
+		chain.events.subscribers = map[<-chan Event]*subscriber{}
+	}
+	chain.events.subscribers[s.events] = s
+	return s.events
+}
+
+// stop receiving events, the channel is closed
+func (chain *Blockchain) Unsubscribe(events <-chan Event) {
+	chain.events.Lock()
+	defer chain.events.Unlock()
+	if s, ok := chain.events.subscribers[events]; ok {
+		delete(chain.events.subscribers, events)
+		close(s.events)
+	}
+}
+
+// number of events dropped for a subscriber, since it was not reading fast enough
+func (chain *Blockchain) Subscription_Dropped(events <-chan Event) uint64 {
+	chain.events.Lock()
+	defer chain.events.Unlock()
+	if s, ok := chain.events.subscribers[events]; ok {
+		return atomic.LoadUint64(&s.dropped)
+	}
+	return 0
+}
+
+// deliver event to all interested subscribers without blocking
+func (chain *Blockchain) publish_event(e Event) {
+	chain.events.Lock()
+	defer chain.events.Unlock()
+	for _, s := range chain.events.subscribers {
+		if s.mask&e.Type == 0 {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+			rlog.Debugf("Subscriber is slow, dropping %s event", e.Type)
+		}
+	}
+}
+
+// queue an event, till the current DB TX commits
+// caller must hold chain lock
+func (chain *Blockchain) queue_event(e Event) {
+	chain.events.Lock()
+	chain.events.pending = append(chain.events.pending, e)
+	chain.events.Unlock()
+}
+
+// deliver queued events, called after the DB TX commits
+func (chain *Blockchain) publish_pending_events() {
+	chain.events.Lock()
+	pending := chain.events.pending
+	chain.events.pending = nil
+	chain.events.Unlock()
+
+	for i := range pending {
+		chain.publish_event(pending[i])
+	}
+}
+
+// discard queued events, called when DB TX is rolled back
+func (chain *Blockchain) discard_pending_events() {
+	chain.events.Lock()
+	chain.events.pending = nil
+	chain.events.Unlock()
+}
+
+// mempool is not transactional, so its events are delivered immediately
+func (chain *Blockchain) mempool_event(tx *transaction.Transaction, added bool) {
+	e := Event{Type: EVENT_TX_REMOVED, TXID: tx.GetHash()}
+	if added {
+		e.Type = EVENT_TX_ADDED
+	}
+	chain.publish_event(e)
+}
+
+// queue SC changed events for every SCID touched by the changelog
+func (chain *Blockchain) queue_sc_events(tx_hash crypto.Key, changelog []TX_SC_storage, reverted bool) {
+	seen := map[crypto.Key]bool{}
+	for i := range changelog {
+		if seen[changelog[i].SCID] || changelog[i].Refund { // refund does not change any SC
+			continue
+		}
+		seen[changelog[i].SCID] = true
+		chain.queue_event(Event{Type: EVENT_SC_CHANGED, TXID: crypto.Hash(tx_hash), SCID: crypto.Hash(changelog[i].SCID), Reverted: reverted})
+	}
+}
+
+// order is changed only if an already ordered block moved or got removed, appending blocks is not a reorder
+func is_topo_reordered(old_order, new_order []crypto.Hash) bool {
+	if len(old_order) > len(new_order) {
+		return true
+	}
+	for i := range old_order {
+		if old_order[i] != new_order[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "testing"
+
+import "github.com/deroproject/derosuite/crypto"
+
+func Test_Events_New_Block(t *testing.T) {
+	chain := new_test_chain(t)
+
+	blocks := chain.Subscribe(EVENT_NEW_BLOCK, 16)
+	mempool := chain.Subscribe(EVENT_TX_ADDED|EVENT_TX_REMOVED, 16)
+	defer chain.Unsubscribe(mempool)
+
+	mine_blocks(t, chain, 3)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case e := <-blocks:
+			if e.Type != EVENT_NEW_BLOCK {
+				t.Fatalf("unexpected event %s", e.Type)
+			}
+			if blid, _ := chain.Load_Block_Topological_order_at_index(nil, e.TopoHeight); blid != e.BLID {
+				t.Fatalf("new block event topoheight %d does not point to block %s", e.TopoHeight, e.BLID)
+			}
+		default:
+			t.Fatalf("expected 3 new block events, received %d", i)
+		}
+	}
+
+	select {
+	case e := <-mempool:
+		t.Fatalf("mempool subscriber received unexpected event %s", e.Type)
+	default:
+	}
+
+	chain.Unsubscribe(blocks)
+	if _, ok := <-blocks; ok {
+		t.Fatalf("channel must be closed after unsubscribe")
+	}
+}
+
+func Test_Events_Slow_Subscriber(t *testing.T) {
+	chain := new_test_chain(t)
+
+	events := chain.Subscribe(EVENT_NEW_BLOCK, 1)
+	defer chain.Unsubscribe(events)
+
+	mine_blocks(t, chain, 3) // chain must not block on subscriber
+
+	if dropped := chain.Subscription_Dropped(events); dropped != 2 {
+		t.Fatalf("expected 2 dropped events actual %d", dropped)
+	}
+}
+
+func Test_Topo_Reordered(t *testing.T) {
+	a, b, c := crypto.Hash{1}, crypto.Hash{2}, crypto.Hash{3}
+
+	tests := []struct {
+		old_order, new_order []crypto.Hash
+		reordered            bool
+	}{
+		{[]crypto.Hash{a, b}, []crypto.Hash{a, b, c}, false}, // block appended
+		{[]crypto.Hash{a, b}, []crypto.Hash{a, c, b}, true},  // block inserted in between
+		{[]crypto.Hash{a, b, c}, []crypto.Hash{a, b}, true},  // block removed
+		{nil, []crypto.Hash{a}, false},                       // genesis
+	}
+
+	for i := range tests {
+		if is_topo_reordered(tests[i].old_order, tests[i].new_order) != tests[i].reordered {
+			t.Fatalf("test %d expected reordered %v", i, tests[i].reordered)
+		}
+	}
+}
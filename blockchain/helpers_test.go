@@ -0,0 +1,319 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "os"
+import "sync"
+import "testing"
+import "io/ioutil"
+import "encoding/hex"
+import "path/filepath"
+
+import log "github.com/sirupsen/logrus"
+import "github.com/vmihailenco/msgpack"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/config"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/address"
+import "github.com/deroproject/derosuite/globals"
+import "github.com/deroproject/derosuite/storage"
+import "github.com/deroproject/derosuite/walletapi"
+import "github.com/deroproject/derosuite/transaction"
+import "github.com/deroproject/derosuite/crypto/ringct"
+import "github.com/deroproject/derosuite/blockchain/inputmaturity"
+
+var test_globals_once sync.Once
+
+// globals are shared by the whole process, so they are setup only once
+func init_test_globals(t *testing.T) {
+	test_globals_once.Do(func() {
+		globals.Logger = log.New()
+		globals.Arguments = map[string]interface{}{"--testnet": true, "--debug": false,
+			"--boltdb": false, "--badgerdb": false, "--disable-checkpoints": false}
+		globals.Init_rlog()
+		globals.Initialize()
+	})
+}
+
+// every test gets its own simulator chain running on a fresh memory backend
+func new_test_chain(t *testing.T) *Blockchain {
+	init_test_globals(t)
+
+	dir, err := ioutil.TempDir("", "derotest")
+	if err != nil {
+		t.Fatalf("cannot create temp dir err %s", err)
+	}
+	globals.Arguments["--data-dir"] = dir
+
+	chain, err := Blockchain_Start(map[string]interface{}{"--memorydb": true, "--simulator": true})
+	if err != nil {
+		t.Fatalf("cannot start chain err %s", err)
+	}
+	t.Cleanup(chain.Shutdown)
+	return chain
+}
+
+// SC txs are only processed from hard fork 4, which simulator hard forks do not reach
+var sc_test_hard_forks = []Hard_fork{
+	{1, 0, 0, 0, 0, true},
+	{2, 1, 0, 0, 0, true},
+	{4, 2, 0, 0, 0, true},
+}
+
+// simulator chain reaching hard fork 4 at height 2, so as SC txs are processed within mined blocks
+func new_sc_test_chain(t *testing.T) *Blockchain {
+	chain := new_test_chain(t)
+	current_hard_forks = sc_test_hard_forks
+	t.Cleanup(func() { current_hard_forks = Simulation_hard_forks })
+	return chain
+}
+
+// bare chain without genesis block, only the store is setup
+func empty_test_chain(t *testing.T) *Blockchain {
+	init_test_globals(t)
+
+	chain := &Blockchain{store: &storage.MemoryStore{}}
+	chain.store.Init(nil)
+	return chain
+}
+
+// mines count blocks on top of the chain
+func mine_blocks(t *testing.T, chain *Blockchain, count int) {
+	_, spend := crypto.NewKeyPair()
+	_, view := crypto.NewKeyPair()
+	mine_blocks_to(t, chain, address.Address{Network: globals.Config.Public_Address_Prefix, SpendKey: *spend, ViewKey: *view}, count)
+}
+
+// mines count blocks on top of the chain, rewards go to miner
+// txs in mempool are mined too
+func mine_blocks_to(t *testing.T, chain *Blockchain, miner address.Address, count int) {
+	for i := 0; i < count; i++ {
+		cbl, _ := chain.Create_new_miner_block(miner)
+		if err, ok := chain.Add_Complete_Block(cbl); !ok {
+			t.Fatalf("block rejected at topoheight %d err %s", chain.Load_TOPO_HEIGHT(nil), err)
+		}
+	}
+}
+
+// locks the chain and opens a writable TX, both are released when test finishes
+// nothing is committed
+func write_test_tx(t *testing.T, chain *Blockchain) storage.DBTX {
+	chain.Lock()
+	dbtx, err := chain.store.BeginTX(true)
+	if err != nil {
+		chain.Unlock()
+		t.Fatalf("cannot begin TX err %s", err)
+	}
+	t.Cleanup(func() {
+		dbtx.Rollback()
+		chain.Unlock()
+	})
+	return dbtx
+}
+
+// installs SC directly into the chain state at current topoheight, as if it was installed with a zero balance
+func install_test_sc(t *testing.T, chain *Blockchain, src string) (scid crypto.Key) {
+	sc_parsed, pos, err := dvm.ParseSmartContract(src)
+	if err != nil {
+		t.Fatalf("SC could not be parsed pos %s err %s", pos, err)
+	}
+	serialized, err := msgpack.Marshal(sc_parsed)
+	if err != nil {
+		t.Fatalf("SC could not be serialized err %s", err)
+	}
+
+	scid = crypto.Key(crypto.Keccak256([]byte(src)))
+
+	chain.Lock()
+	defer chain.Unlock()
+	dbtx, err := chain.store.BeginTX(true)
+	if err != nil {
+		t.Fatalf("cannot begin TX err %s", err)
+	}
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, scid[:], PLANET_TX_SC_BYTES, []byte(src))
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, scid[:], PLANET_TX_SC_PROCESSED, serialized)
+	keyhash := crypto.Key(crypto.Keccak256(dvm.Serialize_DataKey(dvm.GetBalanceKey(scid))))
+	chain.StoreSCValue(dbtx, scid, keyhash, dvm.Serialize_Variable(dvm.Variable{Type: dvm.Uint64, Value: uint64(0)}))
+	chain.Store_TX_Height(dbtx, crypto.Hash(scid), chain.Load_TOPO_HEIGHT(dbtx))
+	if err = dbtx.Commit(); err != nil {
+		t.Fatalf("cannot commit TX err %s", err)
+	}
+	return
+}
+
+// SC tx built from the real ringct tx of prune test, signed by a fresh signer with value attached as open output
+// ringct signature does not match anymore, so it can only be processed directly
+func sc_test_tx(t *testing.T, data []byte, value uint64) (tx *transaction.Transaction, signer address.Address) {
+	tx_raw, _ := hex.DecodeString(prune_test_tx_hex)
+	tx = &transaction.Transaction{}
+	if err := tx.DeserializeHeader(tx_raw); err != nil {
+		t.Fatalf("tx deserialisation failed err %s", err)
+	}
+	tx.Vout[0].Amount = value
+	tx.Vout[0].Target = transaction.Txout_to_key{}
+
+	secret, public := crypto.NewKeyPair()
+	signer = *address.NewAddressFromKeys(*public, *public)
+
+	first_keyimage := crypto.Key(tx.Vin[0].(transaction.Txin_to_key).K_image)
+	var sig crypto.Signature
+	crypto.Signature_Generate(crypto.Key(crypto.Keccak256(data, first_keyimage[:])), *public, *secret, &sig)
+
+	_, tx_public_key := crypto.NewKeyPair()
+	tx.Extra_map = map[transaction.EXTRA_TAG]interface{}{transaction.TX_PUBLIC_KEY: *tx_public_key,
+		transaction.TX_EXTRA_SCDATA: data, transaction.TX_EXTRA_SIG: sig, transaction.TX_EXTRA_ADDRESS: signer}
+	tx.PaymentID_map = map[transaction.EXTRA_TAG]interface{}{}
+	tx.Extra = tx.Serialize_Extra()
+	return
+}
+
+// serialized SC tx data
+func sc_test_data(t *testing.T, sc_tx transaction.SC_Transaction) []byte {
+	data, err := msgpack.Marshal(&sc_tx)
+	if err != nil {
+		t.Fatalf("SC tx could not be serialized err %s", err)
+	}
+	return data
+}
+
+// processes tx within a new block on top of chain, the same way client protocol does at given hard fork version
+// output index of the block is written too, returns global index of first output generated by SC
+func process_test_sc_tx(t *testing.T, chain *Blockchain, dbtx storage.DBTX, tx *transaction.Transaction, hard_fork_version int64) (sc_outputs uint64) {
+	topoheight := chain.Load_TOPO_HEIGHT(dbtx)
+	top, err := chain.Load_Block_Topological_order_at_index(dbtx, topoheight)
+	if err != nil {
+		t.Fatalf("top block not found err %s", err)
+	}
+	bl, err := chain.Load_BL_FROM_ID(dbtx, top)
+	if err != nil {
+		t.Fatalf("top block could not be loaded err %s", err)
+	}
+	bl.Tips = []crypto.Hash{top}
+	bl.Tx_hashes = []crypto.Hash{tx.GetHash()}
+	blid := bl.GetHash()
+
+	chain.Store_BL(dbtx, bl)
+	chain.Store_Block_Topological_order(dbtx, blid, topoheight+1)
+	dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, blid[:], PLANET_MINERTX_REWARD, 0)
+	chain.Store_TX(dbtx, tx)
+	chain.mark_TX(dbtx, blid, tx.GetHash(), true)
+
+	chain.Process_SC(dbtx, bl, tx, hard_fork_version)
+
+	start, _ := dbtx.LoadUint64(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, top[:], PLANET_OUTPUT_INDEX_END)
+	if !chain.write_output_index(dbtx, blid, int64(start), hard_fork_version) {
+		t.Fatalf("output index could not be written")
+	}
+	sc_outputs = start + 1 // miner tx and tx outputs come first, open SC output is not indexed
+	for _, vout := range tx.Vout {
+		if vout.Target.(transaction.Txout_to_key).Key != (crypto.Key{}) {
+			sc_outputs++
+		}
+	}
+	return
+}
+
+// outputs generated by SC for the tx processed by process_test_sc_tx
+func sc_test_outputs(t *testing.T, chain *Blockchain, dbtx storage.DBTX, tx *transaction.Transaction, sc_outputs uint64) (amounts []uint64) {
+	for i := sc_outputs; ; i++ {
+		output, ok := chain.load_output_index(dbtx, i)
+		if !ok || output.TXID != tx.GetHash() {
+			return
+		}
+		amounts = append(amounts, output.Amount)
+	}
+}
+
+// wallet used to spend miner rewards in SC txs, wallet db is removed when test finishes
+func new_test_wallet(t *testing.T) *walletapi.Wallet {
+	dir, err := ioutil.TempDir("", "derowallet")
+	if err != nil {
+		t.Fatalf("cannot create temp dir err %s", err)
+	}
+	w, err := walletapi.Create_Encrypted_Wallet(filepath.Join(dir, "wallet.db"), "test", *crypto.RandomScalar())
+	if err != nil {
+		t.Fatalf("cannot create wallet err %s", err)
+	}
+	t.Cleanup(func() {
+		w.Close_Encrypted_Wallet()
+		os.RemoveAll(dir)
+	})
+	return w
+}
+
+// SC tx signed by the wallet, which can be mined by the chain
+// first mature unspent miner output of the wallet is spent, following outputs are used as ring members
+// value is attached as open amount, rest minus fees is sent back to the wallet
+func wallet_test_sc_tx(t *testing.T, chain *Blockchain, w *walletapi.Wallet, sc_tx transaction.SC_Transaction, value uint64) *transaction.Transaction {
+	dbtx, err := chain.store.BeginTX(false)
+	if err != nil {
+		t.Fatalf("cannot begin TX err %s", err)
+	}
+	defer dbtx.Rollback()
+
+	height := uint64(chain.Get_Height())
+	mature := func(index uint64) (globals.TX_Output_Data, bool) {
+		output, ok := chain.load_output_index(dbtx, index)
+		return output, ok && inputmaturity.Is_Input_Mature(height, output.Height, output.Unlock_Height, output.SigType)
+	}
+
+	for index := uint64(0); ; index++ {
+		output, ok := mature(index)
+		if !ok {
+			t.Fatalf("wallet has no mature unspent output at height %d", height)
+		}
+		if output.SigType != 0 || !w.Is_Output_Ours(output.Tx_Public_Key, 0, output.InKey.Destination) {
+			continue
+		}
+		secret, _, keyimage := w.Generate_Helper_Key_Image(output.Tx_Public_Key, 0)
+		if _, spent := chain.Read_KeyImage_Status(dbtx, crypto.Hash(keyimage)); spent {
+			continue
+		}
+
+		// miner outputs are committed with mask 1
+		input := ringct.Input_info{Amount: output.Amount, Key_image: crypto.Hash(keyimage), Index_Global: index,
+			Sk: ringct.CtKey{Destination: secret, Mask: crypto.Key{1}}}
+		for member := index; len(input.Ring_Members) < config.MIN_MIXIN; member++ {
+			ring_member, ok := mature(member)
+			if !ok {
+				t.Fatalf("not enough mature ring members after output %d", index)
+			}
+			input.Ring_Members = append(input.Ring_Members, member)
+			input.Pubs = append(input.Pubs, ring_member.InKey)
+		}
+
+		fees := chain.Calculate_TX_fee(chain.Get_Current_Version_at_Height(int64(height)), 8*1024)
+		if output.Amount < value+fees {
+			t.Fatalf("output amount %d does not cover value %d", output.Amount, value)
+		}
+		outputs := []ringct.Output_info{{Amount: value},
+			{Amount: output.Amount - value - fees, Public_Spend_Key: w.GetAddress().SpendKey, Public_View_Key: w.GetAddress().ViewKey}}
+		return w.Create_TX_v2([]ringct.Input_info{input}, outputs, fees, 0, nil, true, &sc_tx)
+	}
+}
+
+// adds tx to mempool and mines it in the next block, rewards go to the wallet
+func mine_test_tx(t *testing.T, chain *Blockchain, w *walletapi.Wallet, tx *transaction.Transaction) {
+	if !chain.Add_TX_To_Pool(tx) {
+		t.Fatalf("tx %s rejected by mempool", tx.GetHash())
+	}
+	mine_blocks_to(t, chain, w.GetAddress(), 1)
+	if chain.Load_TX_Height(nil, tx.GetHash()) < 0 {
+		t.Fatalf("tx %s was not mined", tx.GetHash())
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+// this file implements optional secondary indexes, enabled using --index
+// SCID => valid txs which invoked the SC, keyed by topoheight so as they are returned in chain order
+// TX => blocks which included the tx, together with position within block ( 0 is miner tx )
+// BLOCK => miner tx keys
+// outputs are stealth, so miner address cannot be recovered from the miner tx,
+// instead the tx public key and output key are indexed, owner can recognise them using the view key
+// indexes are updated within the same DB TX as the block, so they are never out of sync
+// topo position and validity change with reorders, so they are read live from chain while querying
+import "fmt"
+import "encoding/binary"
+
+import "github.com/vmihailenco/msgpack"
+
+import "github.com/deroproject/derosuite/block"
+import "github.com/deroproject/derosuite/config"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/storage"
+import "github.com/deroproject/derosuite/transaction"
+
+var GALAXY_INDEX_SC = []byte("ISC")    // scid => itob(topoheight) + txid
+var GALAXY_INDEX_TX = []byte("ITX")    // txid => blid => position within block
+var GALAXY_INDEX_MINER = []byte("IMN") // blid => miner keys
+
+var PLANET_INDEX_MINER = []byte("MINER")
+
+var INDEX_TOPO_HEIGHT = []byte("INDEX_TOPO_HEIGHT") // index is upto date till this topo height
+
+const INDEX_BLOCKS_PER_TX = 1000 // blocks indexed in a single DB TX while building index
+
+// keys of the miner tx, indexed per block
+type Index_Miner struct {
+	Miner_TX      crypto.Hash `msgpack:"M"`
+	TX_Public_Key crypto.Key  `msgpack:"P"`
+	Output_Key    crypto.Key  `msgpack:"O"`
+}
+
+type Index_SC_Entry struct {
+	TXID       crypto.Hash
+	TopoHeight int64
+}
+
+type Index_TX_Entry struct {
+	BLID       crypto.Hash
+	TopoHeight int64 // -1 if block is not ordered
+	Position   int64 // position within block, 0 is miner tx
+	Valid      bool  // whether tx was considered valid in this block
+}
+
+// whether the chain is maintaining secondary indexes
+func (chain *Blockchain) Is_Indexing() bool {
+	return chain.index
+}
+
+// index txs and miner of a block, this is done only once when block is added
+func (chain *Blockchain) index_block(dbtx storage.DBTX, bl *block.Block, blid crypto.Hash) {
+	if dbtx == nil {
+		panic("dbtx cannot be nil")
+	}
+
+	miner_txid := bl.Miner_TX.GetHash()
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_TX, miner_txid[:], blid[:], itob(0))
+	for i := range bl.Tx_hashes {
+		dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_TX, bl.Tx_hashes[i][:], blid[:], itob(uint64(i+1)))
+	}
+
+	miner := Index_Miner{Miner_TX: miner_txid}
+	if bl.Miner_TX.Parse_Extra() {
+		miner.TX_Public_Key, _ = bl.Miner_TX.Extra_map[transaction.TX_PUBLIC_KEY].(crypto.Key)
+	}
+	if len(bl.Miner_TX.Vout) >= 1 {
+		if target, ok := bl.Miner_TX.Vout[0].Target.(transaction.Txout_to_key); ok {
+			miner.Output_Key = target.Key
+		}
+	}
+	serialized, _ := msgpack.Marshal(&miner)
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_MINER, blid[:], PLANET_INDEX_MINER, serialized)
+}
+
+// block is being deleted from chain
+func (chain *Blockchain) unindex_block(dbtx storage.DBTX, bl *block.Block, blid crypto.Hash) {
+	if dbtx == nil {
+		panic("dbtx cannot be nil")
+	}
+
+	miner_txid := bl.Miner_TX.GetHash()
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_TX, miner_txid[:], blid[:], []byte{})
+	for i := range bl.Tx_hashes {
+		dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_TX, bl.Tx_hashes[i][:], blid[:], []byte{})
+	}
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_MINER, blid[:], PLANET_INDEX_MINER, []byte{})
+}
+
+// add or remove a valid SC tx from the SCID index, non SC txs are ignored
+func (chain *Blockchain) index_sc_tx(dbtx storage.DBTX, tx *transaction.Transaction, topoheight int64, add bool) {
+	if dbtx == nil {
+		panic("dbtx cannot be nil")
+	}
+
+	scid, ok := sc_tx_scid(tx)
+	if !ok {
+		return
+	}
+
+	txid := tx.GetHash()
+	value := []byte{}
+	if add {
+		value = []byte{1}
+	}
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_SC, scid[:], append(itob(uint64(topoheight)), txid[:]...), value)
+}
+
+// SCID invoked by the tx, the tx itself for SC installation
+func sc_tx_scid(tx *transaction.Transaction) (scid crypto.Key, ok bool) {
+	if !tx.Verify_SC_Signature() { // not SC tx, or SC would not have been processed
+		return
+	}
+	data, ok := tx.Extra_map[transaction.TX_EXTRA_SCDATA].([]byte)
+	if !ok || len(data) < 3 {
+		return scid, false
+	}
+
+	var sc_tx transaction.SC_Transaction
+	if err := msgpack.Unmarshal(data, &sc_tx); err != nil {
+		return scid, false
+	}
+
+	if len(sc_tx.SC) > 0 {
+		return crypto.Key(tx.GetHash()), true
+	}
+	return sc_tx.SCID, true
+}
+
+// list valid txs which invoked the SC, starting from specific topoheight, upto max_count entries
+func (chain *Blockchain) Index_SC_TXs(dbtx storage.DBTX, scid crypto.Hash, topoheight int64, max_count int) (entries []Index_SC_Entry, err error) {
+	if !chain.index {
+		return nil, fmt.Errorf("index is not enabled")
+	}
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			return
+		}
+		defer dbtx.Rollback()
+	}
+
+	cursor, err := dbtx.Cursor(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_SC, scid[:])
+	if err != nil {
+		return
+	}
+	defer cursor.Close()
+
+	if topoheight < 0 {
+		topoheight = 0
+	}
+	for k, v := cursor.Seek(itob(uint64(topoheight))); k != nil && len(entries) < max_count; k, v = cursor.Next() {
+		if len(k) != 40 || len(v) == 0 {
+			continue
+		}
+		var entry Index_SC_Entry
+		entry.TopoHeight = int64(binary.BigEndian.Uint64(k[:8]))
+		copy(entry.TXID[:], k[8:])
+
+		// entries left over by reorders while index was disabled are skipped
+		if chain.Load_TX_Height(dbtx, entry.TXID) != entry.TopoHeight {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// list blocks which included the tx
+func (chain *Blockchain) Index_TX_Blocks(dbtx storage.DBTX, txid crypto.Hash) (entries []Index_TX_Entry, err error) {
+	if !chain.index {
+		return nil, fmt.Errorf("index is not enabled")
+	}
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			return
+		}
+		defer dbtx.Rollback()
+	}
+
+	err = dbtx.PrefixScan(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_TX, txid[:], nil, false, func(k, v []byte) bool {
+		if len(k) != 32 || len(v) != 8 {
+			return true
+		}
+		entry := Index_TX_Entry{TopoHeight: -1, Position: int64(binary.BigEndian.Uint64(v))}
+		copy(entry.BLID[:], k)
+		if chain.Is_Block_Topological_order(dbtx, entry.BLID) {
+			entry.TopoHeight = chain.Load_Block_Topological_order(dbtx, entry.BLID)
+		}
+		entry.Valid = entry.Position == 0 || chain.IS_TX_Valid(dbtx, entry.BLID, txid)
+		entries = append(entries, entry)
+		return true
+	})
+	return
+}
+
+// miner tx keys of a block
+func (chain *Blockchain) Index_Block_Miner(dbtx storage.DBTX, blid crypto.Hash) (miner Index_Miner, err error) {
+	if !chain.index {
+		return miner, fmt.Errorf("index is not enabled")
+	}
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			return
+		}
+		defer dbtx.Rollback()
+	}
+
+	serialized, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_MINER, blid[:], PLANET_INDEX_MINER)
+	if err != nil || len(serialized) == 0 {
+		return miner, fmt.Errorf("block %s not found in index", blid)
+	}
+	err = msgpack.Unmarshal(serialized, &miner)
+	return
+}
+
+func (chain *Blockchain) store_index_topoheight(dbtx storage.DBTX, topoheight int64) {
+	dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_KEYVALUE, INDEX_TOPO_HEIGHT, INDEX_TOPO_HEIGHT, uint64(topoheight))
+}
+
+// builds index for blocks added while daemon was running without --index
+// blocks within stable limit of the last indexed block are reindexed, since they might have been reordered
+func (chain *Blockchain) build_index() error {
+	chain.Lock()
+	defer chain.Unlock()
+
+	start := int64(0)
+	top_topoheight := chain.Load_TOPO_HEIGHT(nil)
+	if dbtx, err := chain.store.BeginTX(false); err == nil {
+		if indexed, err := dbtx.LoadUint64(BLOCKCHAIN_UNIVERSE, GALAXY_KEYVALUE, INDEX_TOPO_HEIGHT, INDEX_TOPO_HEIGHT); err == nil {
+			if int64(indexed) == top_topoheight {
+				dbtx.Rollback()
+				return nil
+			}
+			start = int64(indexed) - config.STABLE_LIMIT
+		}
+		dbtx.Rollback()
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	logger.Infof("Building index from topoheight %d to %d", start, top_topoheight)
+	for start <= top_topoheight {
+		end := start + INDEX_BLOCKS_PER_TX
+		if end > top_topoheight+1 {
+			end = top_topoheight + 1
+		}
+		if err := chain.build_index_range(start, end); err != nil {
+			return err
+		}
+		start = end
+		logger.Infof("Indexed upto topoheight %d", start-1)
+	}
+	return nil
+}
+
+// index blocks in range [start, end) within a single DB TX
+func (chain *Blockchain) build_index_range(start, end int64) (err error) {
+	dbtx, err := chain.store.BeginTX(true)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Recovered while building index %v", r)
+		}
+		if err == nil {
+			err = dbtx.Commit()
+		} else {
+			dbtx.Rollback()
+		}
+	}()
+
+	for topoheight := start; topoheight < end; topoheight++ {
+		blid, err := chain.Load_Block_Topological_order_at_index(dbtx, topoheight)
+		if err != nil {
+			return err
+		}
+		bl, err := chain.Load_BL_FROM_ID(dbtx, blid)
+		if err != nil {
+			return err
+		}
+
+		chain.index_block(dbtx, bl, blid)
+
+		if bl.Major_Version < 4 { // SCs are only processed from hard fork 4
+			continue
+		}
+		for i := range bl.Tx_hashes {
+			if !chain.IS_TX_Valid(dbtx, blid, bl.Tx_hashes[i]) {
+				continue
+			}
+			tx, err := chain.Load_TX_FROM_ID(dbtx, bl.Tx_hashes[i])
+			if err != nil {
+				return err
+			}
+			chain.index_sc_tx(dbtx, tx, topoheight, true)
+		}
+	}
+	chain.store_index_topoheight(dbtx, end-1)
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "testing"
+
+import "github.com/deroproject/derosuite/config"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/transaction"
+
+// blocks mined before index was enabled are indexed by build_index, later ones while being added
+func Test_Index(t *testing.T) {
+	chain := new_test_chain(t)
+	mine_blocks(t, chain, 2)
+
+	chain.index = true
+	defer func() { chain.index = false }()
+
+	if err := chain.build_index(); err != nil {
+		t.Fatalf("index could not be built err %s", err)
+	}
+	mine_blocks(t, chain, 2)
+
+	for _, topoheight := range []int64{1, chain.Load_TOPO_HEIGHT(nil)} {
+		blid, err := chain.Load_Block_Topological_order_at_index(nil, topoheight)
+		if err != nil {
+			t.Fatalf("block missing at topoheight %d", topoheight)
+		}
+		bl, err := chain.Load_BL_FROM_ID(nil, blid)
+		if err != nil {
+			t.Fatalf("block %s could not be loaded", blid)
+		}
+
+		miner, err := chain.Index_Block_Miner(nil, blid)
+		if err != nil {
+			t.Fatalf("block %s not indexed err %s", blid, err)
+		}
+		if miner.Miner_TX != bl.Miner_TX.GetHash() || miner.Output_Key == (crypto.Key{}) {
+			t.Fatalf("miner index mismatch for block %s", blid)
+		}
+
+		entries, err := chain.Index_TX_Blocks(nil, miner.Miner_TX)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("miner tx index expected 1 entry, got %d err %v", len(entries), err)
+		}
+		if entries[0].BLID != blid || entries[0].TopoHeight != topoheight || entries[0].Position != 0 || !entries[0].Valid {
+			t.Fatalf("miner tx index entry mismatch %+v", entries[0])
+		}
+	}
+
+	if _, err := chain.Index_Block_Miner(nil, crypto.Hash{1}); err == nil {
+		t.Fatalf("unknown block must not be found in index")
+	}
+}
+
+// SC txs removed by a rewind are removed from SCID and tx indexes
+func Test_Index_SC_Rewind(t *testing.T) {
+	chain := new_sc_test_chain(t)
+	chain.index = true
+	defer func() { chain.index = false }()
+
+	w := new_test_wallet(t)
+	mine_blocks_to(t, chain, w.GetAddress(), int(config.MINER_TX_AMOUNT_UNLOCK)+5)
+
+	tx := wallet_test_sc_tx(t, chain, w, transaction.SC_Transaction{SC: gas_test_sc, Gas: 1000}, 2000*config.SC_GAS_PRICE)
+	mine_test_tx(t, chain, w, tx)
+	txid := tx.GetHash()
+	topoheight := chain.Load_TX_Height(nil, txid)
+
+	if entries, err := chain.Index_SC_TXs(nil, txid, 0, 10); err != nil || len(entries) != 1 || entries[0].TXID != txid || entries[0].TopoHeight != topoheight {
+		t.Fatalf("SC install not indexed %+v err %v", entries, err)
+	}
+	if entries, err := chain.Index_TX_Blocks(nil, txid); err != nil || len(entries) != 1 || entries[0].TopoHeight != topoheight || !entries[0].Valid {
+		t.Fatalf("SC tx block not indexed %+v err %v", entries, err)
+	}
+
+	mine_blocks_to(t, chain, w.GetAddress(), int(config.STABLE_LIMIT)+2)
+	if !chain.Rewind_Chain(int(config.STABLE_LIMIT) + 4) {
+		t.Fatalf("rewind failed")
+	}
+	if chain.Load_TOPO_HEIGHT(nil) >= topoheight {
+		t.Fatalf("rewind did not remove SC tx block, topoheight %d", chain.Load_TOPO_HEIGHT(nil))
+	}
+
+	if entries, err := chain.Index_SC_TXs(nil, txid, 0, 10); err != nil || len(entries) != 0 {
+		t.Fatalf("rewound SC tx still indexed %+v err %v", entries, err)
+	}
+	if entries, err := chain.Index_TX_Blocks(nil, txid); err != nil || len(entries) != 0 {
+		t.Fatalf("rewound tx block still indexed %+v err %v", entries, err)
+	}
+
+	// entry itself must be removed, not only skipped since tx is no longer at topoheight
+	dbtx, _ := chain.store.BeginTX(false)
+	defer dbtx.Rollback()
+	if value, _ := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_INDEX_SC, txid[:], append(itob(uint64(topoheight)), txid[:]...)); len(value) != 0 {
+		t.Fatalf("SCID index entry was not removed")
+	}
+}
@@ -33,6 +33,25 @@ import "github.com/deroproject/derosuite/transaction"
 import "github.com/deroproject/derosuite/globals"
 import "github.com/deroproject/derosuite/crypto"
 
+const MEMPOOL_DEFAULT_MAX_SIZE = 100 * 1024 * 1024 // pool size in bytes, used if --mempool-size is not provided
+const MEMPOOL_MAX_EVICTED = 100                    // evictions remembered, so as they can be inspected over RPC
+
+// reasons a tx was removed from pool without being mined
+const (
+	EVICT_REPLACED  = "replaced"        // tx spending same key images with higher fee per KB arrived
+	EVICT_POOL_FULL = "pool_full"       // pool reached its size limit and tx had lowest fee per KB
+	EVICT_SPENT     = "key_image_spent" // key images were consumed by chain
+	EVICT_EXPIRED   = "expired"         // tx could not be mined in time
+)
+
+// tx removed from pool without being mined
+type Evicted_TX struct {
+	TXID   crypto.Hash
+	Reason string // one of EVICT_*
+	Detail string
+	Time   int64 // time in epoch format
+}
+
 // this is only used for sorting and nothing else
 type TX_Sorting_struct struct {
 	FeesPerByte uint64      // this is fees per byte
@@ -59,6 +78,13 @@ type Mempool struct {
 
 	P2P_TX_Relayer p2p_TX_Relayer // actual pointer, setup by the dero daemon during runtime
 
+	TX_Event_Notifier tx_Event_Notifier // called whenever a tx is added or removed, setup by blockchain
+
+	max_size uint64 // total size of txs in bytes is kept below this, 0 is unlimited
+
+	evicted      []Evicted_TX // most recent evictions, oldest first
+	evicted_lock sync.Mutex
+
 	// global variable , but don't see it utilisation here except fot tx verification
 	//chain *Blockchain
 	Exit_Mutex chan bool
@@ -77,6 +103,18 @@ type mempool_object struct {
 	FEEperBYTE uint64 // fee per byte
 }
 
+// fee per KB is used for replacement and eviction, since fee per byte loses precision for small fees
+func fee_per_kb(fee, size uint64) uint64 {
+	if size == 0 {
+		return 0
+	}
+	return fee * 1024 / size
+}
+
+func (obj *mempool_object) fee_per_kb() uint64 {
+	return fee_per_kb(obj.Tx.RctSignature.Get_TX_Fee(), obj.Size)
+}
+
 var loggerpool *log.Entry
 
 // marshal object as json
@@ -140,6 +178,11 @@ func Init_Mempool(params map[string]interface{}) (*Mempool, error) {
 
 	mempool.Exit_Mutex = make(chan bool)
 
+	mempool.max_size = MEMPOOL_DEFAULT_MAX_SIZE
+	if max_size, ok := params["--mempool-size"].(uint64); ok {
+		mempool.max_size = max_size
+	}
+
 	// initialize maps
 	//mempool.txs = map[crypto.Hash]*mempool_object{}
 	//mempool.key_images = map[crypto.Hash]bool{}
@@ -200,7 +243,7 @@ func (pool *Mempool) HouseKeeping(height uint64, Verifier func(*transaction.Tran
 	// thereby avoiding problem of input maturity
 	// carry over book keeping activities, such as keep transactions hidden till specific height is reached
 	// this is done to avoid a soft-fork issue, related to input maturity
-	var delete_list []crypto.Hash
+	var delete_list []Evicted_TX
 
 	pool.txs.Range(func(k, value interface{}) bool {
 		txhash := k.(crypto.Hash)
@@ -209,31 +252,31 @@ func (pool *Mempool) HouseKeeping(height uint64, Verifier func(*transaction.Tran
 		if height > v.Height { // verify all tx in pool for double spending
 			if !Verifier(v.Tx) { // this tx could not verified against specific height
 				rlog.Warnf("!!MEMPOOL Deleting TX since atleast one of the key images is already consumed  txid=%s chain height %d tx height %d", txhash, height, v.Height)
-				delete_list = append(delete_list, txhash)
+				delete_list = append(delete_list, Evicted_TX{TXID: txhash, Reason: EVICT_SPENT})
 			}
 		}
 
 		// delete transactions whose keyimages have been mined already
 		if v.Height < height {
 			if !Verifier(v.Tx) { // this tx is already spent and thus can never be mined
-				delete_list = append(delete_list, txhash)
+				delete_list = append(delete_list, Evicted_TX{TXID: txhash, Reason: EVICT_SPENT})
 			}
 		}
 		// expire TX which could not be mined in 1 day 86400 hrs
 		if v.Height == 0 && (v.Added+86400) <= uint64(time.Now().UTC().Unix()) {
 			rlog.Warnf("!! MEMPOOL Deleting TX since TX could not be mined in 1 day txid=%s chain height %d tx height %d", txhash, height, v.Height)
-			delete_list = append(delete_list, txhash)
+			delete_list = append(delete_list, Evicted_TX{TXID: txhash, Reason: EVICT_EXPIRED, Detail: "not mined in 1 day"})
 		}
 		// give 7 days time to alt chain txs
 		if v.Height != 0 && (v.Added+(86400*7)) <= uint64(time.Now().UTC().Unix()) {
 			rlog.Warnf("!! MEMPOOL Deleting alt-chain TX since TX could not be mined in 7 days txid=%s chain height %d tx height %d", txhash, height, v.Height)
-			delete_list = append(delete_list, txhash)
+			delete_list = append(delete_list, Evicted_TX{TXID: txhash, Reason: EVICT_EXPIRED, Detail: "alt-chain tx not mined in 7 days"})
 		}
 		return true
 	})
 
 	for i := range delete_list {
-		pool.Mempool_Delete_TX(delete_list[i])
+		pool.evict(delete_list[i].TXID, delete_list[i].Reason, delete_list[i].Detail)
 	}
 }
 
@@ -314,21 +357,6 @@ func (pool *Mempool) Mempool_Add_TX(tx *transaction.Transaction, Height uint64)
 		return false
 	}
 
-	// we should also extract all key images and add them to have multiple pending
-	for i := 0; i < len(tx.Vin); i++ {
-		if _, ok := pool.key_images.Load(tx.Vin[i].(transaction.Txin_to_key).K_image); ok {
-			rlog.Warnf("TX using inputs  which have already been used, Possible Double spend attack rejected txid %s kimage %s", tx_hash,
-				tx.Vin[i].(transaction.Txin_to_key).K_image)
-			return false
-		}
-	}
-
-	// add all the key images to check double spend attack within the pool
-	for i := 0; i < len(tx.Vin); i++ {
-		pool.key_images.Store(tx.Vin[i].(transaction.Txin_to_key).K_image, true) // add element to map for next check
-	}
-
-	// we are here means we can add it to pool
 	object.Tx = tx
 	object.Height = Height
 	object.Added = uint64(time.Now().UTC().Unix())
@@ -336,11 +364,41 @@ func (pool *Mempool) Mempool_Add_TX(tx *transaction.Transaction, Height uint64)
 	object.Size = uint64(len(tx.Serialize()))
 	object.FEEperBYTE = tx.RctSignature.Get_TX_Fee() / object.Size
 
+	// tx using key images already in pool is rejected, unless it can replace the tx using them
+	replaced, err := pool.find_replaceable(tx, object.fee_per_kb())
+	if err != nil {
+		rlog.Warnf("TX using inputs  which have already been used, Possible Double spend attack rejected txid %s err %s", tx_hash, err)
+		return false
+	}
+
+	evict_list, err := pool.find_evictable(object.Size, object.fee_per_kb(), replaced)
+	if err != nil {
+		rlog.Debugf("Pool rejected txid %s err %s", tx_hash, err)
+		return false
+	}
+
+	// we are here means we can add it to pool, make space for it first
+	if replaced != nil {
+		pool.evict(*replaced, EVICT_REPLACED, fmt.Sprintf("replaced by %s with fee per KB %d", tx_hash, object.fee_per_kb()))
+	}
+	for i := range evict_list {
+		pool.evict(evict_list[i], EVICT_POOL_FULL, fmt.Sprintf("evicted for %s with fee per KB %d", tx_hash, object.fee_per_kb()))
+	}
+
+	// add all the key images to check double spend attack within the pool
+	for i := 0; i < len(tx.Vin); i++ {
+		pool.key_images.Store(tx.Vin[i].(transaction.Txin_to_key).K_image, tx_hash) // add element to map for next check
+	}
+
 	pool.txs.Store(tx_hash, &object)
 	pool.modified = true // pool has been modified
 
 	//pool.sort_list() // sort and update pool list
 
+	if pool.TX_Event_Notifier != nil {
+		pool.TX_Event_Notifier(tx, true)
+	}
+
 	return true
 }
 
@@ -392,7 +450,118 @@ func (pool *Mempool) Mempool_Delete_TX(txid crypto.Hash) (tx *transaction.Transa
 
 	//pool.sort_list()     // sort and update pool list
 	pool.modified = true // pool has been modified
-	return object.Tx     // return the tx
+
+	if pool.TX_Event_Notifier != nil {
+		pool.TX_Event_Notifier(object.Tx, false)
+	}
+	return object.Tx // return the tx
+}
+
+// a tx conflicting with pool can only replace a single tx spending exactly the same key images
+// and only if it pays strictly higher fee per KB, nil is returned if tx does not conflict
+func (pool *Mempool) find_replaceable(tx *transaction.Transaction, fee_per_kb uint64) (replaced *crypto.Hash, err error) {
+	conflicts := 0
+	for i := 0; i < len(tx.Vin); i++ {
+		txidi, ok := pool.key_images.Load(tx.Vin[i].(transaction.Txin_to_key).K_image)
+		if !ok {
+			continue
+		}
+		txid := txidi.(crypto.Hash)
+		if replaced != nil && *replaced != txid {
+			return nil, fmt.Errorf("key images are used by multiple txs")
+		}
+		replaced = &txid
+		conflicts++
+	}
+	if replaced == nil {
+		return nil, nil
+	}
+
+	objecti, ok := pool.txs.Load(*replaced)
+	if !ok {
+		return nil, fmt.Errorf("key image %s is used by unknown tx", *replaced)
+	}
+	object := objecti.(*mempool_object)
+	if conflicts != len(tx.Vin) || len(object.Tx.Vin) != len(tx.Vin) {
+		return nil, fmt.Errorf("key images partially overlap with txid %s", *replaced)
+	}
+	if fee_per_kb <= object.fee_per_kb() {
+		return nil, fmt.Errorf("fee per KB %d is not higher than %d of txid %s", fee_per_kb, object.fee_per_kb(), *replaced)
+	}
+	return replaced, nil
+}
+
+// lowest fee per KB txs which must be evicted to make space for a tx of size bytes, space of replaced tx is reused
+// tx is rejected if it would have to evict a tx paying same or higher fee per KB
+func (pool *Mempool) find_evictable(size uint64, fee_per_kb uint64, replaced *crypto.Hash) (evict_list []crypto.Hash, err error) {
+	if pool.max_size == 0 {
+		return
+	}
+	if size > pool.max_size {
+		return nil, fmt.Errorf("tx size %d exceeds pool size %d", size, pool.max_size)
+	}
+
+	used := uint64(0)
+	var objects []*mempool_object
+	var hashes []crypto.Hash
+	pool.txs.Range(func(k, value interface{}) bool {
+		if replaced == nil || k.(crypto.Hash) != *replaced {
+			hashes = append(hashes, k.(crypto.Hash))
+			objects = append(objects, value.(*mempool_object))
+			used += value.(*mempool_object).Size
+		}
+		return true
+	})
+	if used+size <= pool.max_size {
+		return
+	}
+
+	// lowest fee per KB first, newest first among equal, so as older txs survive
+	order := make([]int, len(objects))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := objects[order[i]], objects[order[j]]
+		if a.fee_per_kb() != b.fee_per_kb() {
+			return a.fee_per_kb() < b.fee_per_kb()
+		}
+		return a.Added > b.Added
+	})
+
+	for _, i := range order {
+		if objects[i].fee_per_kb() >= fee_per_kb {
+			break
+		}
+		evict_list = append(evict_list, hashes[i])
+		used -= objects[i].Size
+		if used+size <= pool.max_size {
+			return
+		}
+	}
+	return nil, fmt.Errorf("pool is full, fee per KB %d is not higher than lowest in pool", fee_per_kb)
+}
+
+// removes tx without it being mined and remembers why
+func (pool *Mempool) evict(txid crypto.Hash, reason string, detail string) {
+	if pool.Mempool_Delete_TX(txid) == nil {
+		return
+	}
+	rlog.Debugf("Evicted txid %s reason %s %s", txid, reason, detail)
+
+	pool.evicted_lock.Lock()
+	defer pool.evicted_lock.Unlock()
+	pool.evicted = append(pool.evicted, Evicted_TX{TXID: txid, Reason: reason, Detail: detail, Time: time.Now().UTC().Unix()})
+	if len(pool.evicted) > MEMPOOL_MAX_EVICTED {
+		pool.evicted = append([]Evicted_TX{}, pool.evicted[len(pool.evicted)-MEMPOOL_MAX_EVICTED:]...)
+	}
+}
+
+// return most recent evictions, oldest first
+func (pool *Mempool) Mempool_List_Evicted() []Evicted_TX {
+	pool.evicted_lock.Lock()
+	defer pool.evicted_lock.Unlock()
+	return append([]Evicted_TX{}, pool.evicted...)
 }
 
 // get specific tx from mem pool without removing it
@@ -538,6 +707,8 @@ func (pool *Mempool) sort_list() ([]crypto.Hash, []TX_Sorting_struct) {
 
 type p2p_TX_Relayer func(*transaction.Transaction, uint64) int // function type, exported in p2p but cannot use due to cyclic dependency
 
+type tx_Event_Notifier func(tx *transaction.Transaction, added bool) // function type, blockchain cannot be imported due to cyclic dependency
+
 // this tx relayer keeps on relaying tx and cleaning mempool
 // if a tx has been relayed less than 10 peers, tx relaying is agressive
 // otherwise the tx are relayed every 30 minutes, till it has been relayed to 20
@@ -0,0 +1,194 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+// this file implements pruning of old transaction data
+// range proofs and mlsag signatures are only required while verifying a tx
+// once a block is below stable height and its checksum is known to checkpoints, its txs will never be verified again
+// so their prunable data is discarded, prunable hash is kept so as tx hash can still be calculated
+import "runtime/debug"
+
+import "github.com/romana/rlog"
+
+import "github.com/deroproject/derosuite/block"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/storage"
+import "github.com/deroproject/derosuite/checkpoints"
+import "github.com/deroproject/derosuite/transaction"
+
+// maximum blocks pruned in a single run, so as chain lock is not held for long
+const PRUNE_BLOCKS_PER_RUN = 100
+
+// whether the chain is discarding prunable data
+func (chain *Blockchain) Is_Pruning() bool {
+	return chain.prune
+}
+
+// all blocks below this topoheight have been pruned, 0 means nothing has been pruned
+func (chain *Blockchain) Load_Pruned_TopoHeight(dbtx storage.DBTX) int64 {
+	var err error
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			logger.Warnf("Could NOT load pruned topoheight. Error opening readable TX, err %s", err)
+			return 0
+		}
+
+		defer dbtx.Rollback()
+
+	}
+
+	topoheight, err := dbtx.LoadUint64(BLOCKCHAIN_UNIVERSE, GALAXY_KEYVALUE, PRUNED_TOPO_HEIGHT, PRUNED_TOPO_HEIGHT)
+	if err != nil {
+		return 0
+	}
+	return int64(topoheight)
+}
+
+// single long lived worker, so as pruning never competes with itself for the chain lock
+// every wake up prunes one batch, more blocks are picked up when next block is added
+func (chain *Blockchain) prune_worker() {
+	for {
+		select {
+		case <-chain.Exit_Event:
+			return
+		case <-chain.prune_event:
+			chain.Prune_Chain(PRUNE_BLOCKS_PER_RUN)
+		}
+	}
+}
+
+// wakes up prune worker, never blocks, if worker is already awake the request is merged
+func (chain *Blockchain) trigger_prune() {
+	select {
+	case chain.prune_event <- true:
+	default:
+	}
+}
+
+// prunes upto max_blocks blocks, starting from the last pruned block
+// returns number of blocks pruned
+func (chain *Blockchain) Prune_Chain(max_blocks int) (pruned int) {
+	chain.Lock()
+	defer chain.Unlock()
+
+	stable_height := chain.Get_Stable_Height()
+
+	dbtx, err := chain.store.BeginTX(true)
+	if err != nil {
+		logger.Warnf("Could NOT prune chain. Error opening writable TX, err %s", err)
+		return 0
+	}
+
+	defer func() {
+		// safety so if anything wrong happens, nothing is pruned
+		if r := recover(); r != nil {
+			logger.Warnf("Recovered while pruning chain, Stack trace below")
+			logger.Warnf("Stack trace  \n%s", debug.Stack())
+			pruned = 0
+		}
+
+		if pruned > 0 {
+			dbtx.Commit()
+			dbtx.Sync()
+		} else {
+			dbtx.Rollback()
+		}
+	}()
+
+	top_topoheight := chain.Load_TOPO_HEIGHT(dbtx)
+	topoheight := chain.Load_Pruned_TopoHeight(dbtx)
+
+prune_loop:
+	for ; topoheight <= top_topoheight && pruned < max_blocks; topoheight++ {
+		blid, err := chain.Load_Block_Topological_order_at_index(dbtx, topoheight)
+		if err != nil {
+			break
+		}
+
+		if chain.Load_Height_for_BL_ID(dbtx, blid) >= stable_height { // block may still be reorganised
+			break
+		}
+
+		bl, err := chain.Load_BL_FROM_ID(dbtx, blid)
+		if err != nil {
+			break
+		}
+
+		cbl := block.Complete_Block{Bl: bl}
+		for i := range bl.Tx_hashes {
+			tx, err := chain.Load_TX_FROM_ID(dbtx, bl.Tx_hashes[i])
+			if err != nil || tx.IsPruned() {
+				logger.Warnf("Cannot prune block %s, tx %s is not available in full", blid, bl.Tx_hashes[i])
+				break prune_loop
+			}
+			cbl.Txs = append(cbl.Txs, tx)
+		}
+
+		// only blocks covered by checkpoints are pruned
+		if !checkpoints.IsCheckSumKnown(chain.BlockCheckSum(&cbl)) {
+			break
+		}
+
+		for i := range cbl.Txs {
+			if chain.can_prune_TX(dbtx, bl.Tx_hashes[i], topoheight) {
+				chain.prune_TX(dbtx, cbl.Txs[i])
+			}
+		}
+		pruned++
+	}
+
+	if pruned > 0 {
+		dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_KEYVALUE, PRUNED_TOPO_HEIGHT, PRUNED_TOPO_HEIGHT, uint64(topoheight))
+		rlog.Debugf("Pruned %d blocks, chain pruned upto topoheight %d", pruned, topoheight)
+	}
+	return
+}
+
+// blocks above topoheight have been removed by a rewind, blocks added again at their topoheights are full
+// so pruning must resume above topoheight, peers are also told that those blocks can be served
+func (chain *Blockchain) rewind_pruned_topoheight(dbtx storage.DBTX, topoheight int64) {
+	if chain.Load_Pruned_TopoHeight(dbtx) > topoheight+1 {
+		dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_KEYVALUE, PRUNED_TOPO_HEIGHT, PRUNED_TOPO_HEIGHT, uint64(topoheight+1))
+	}
+}
+
+// a tx can be mined in multiple blocks, it is only pruned while pruning the last of them
+// so as checksum of every block can still be calculated
+func (chain *Blockchain) can_prune_TX(dbtx storage.DBTX, txid crypto.Hash, topoheight int64) bool {
+	blocks := chain.Load_TX_blocks(dbtx, txid)
+	for i := range blocks {
+		if !chain.Is_Block_Topological_order(dbtx, blocks[i]) || chain.Load_Block_Topological_order(dbtx, blocks[i]) > topoheight {
+			return false
+		}
+	}
+	return true
+}
+
+// discard prunable data of a tx, the prunable hash is stored separately
+func (chain *Blockchain) prune_TX(dbtx storage.DBTX, tx *transaction.Transaction) {
+	if dbtx == nil {
+		panic("dbtx cannot be nil")
+	}
+
+	hash := tx.GetHash()
+	prunable_hash := tx.RctSignature.PrunableHash()
+	tx.RctSignature.Prune()
+
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, hash[:], PLANET_TX_BLOB, tx.Serialize())
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, hash[:], PLANET_TX_PRUNED, prunable_hash[:])
+}
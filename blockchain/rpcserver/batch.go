@@ -0,0 +1,145 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// this file implements the /json_rpc endpoint on top of jsonrpc method repository
+// it follows JSON-RPC 2.0 batch semantics, every call in a batch is rate limited separately
+// notifications ( requests without id ) are executed but not responded to
+import "io"
+import "bytes"
+import "context"
+import "strings"
+import "net/http"
+import "io/ioutil"
+
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+const RPC_MAX_BATCH = 100                    // maximum calls in a single batch
+const RPC_MAX_REQUEST_SIZE = 8 * 1024 * 1024 // request body is not read beyond this
+
+const RPC_ERROR_RATE_LIMITED jsonrpc.ErrorCode = -32005
+
+// mining, admin and tracing methods, these are not available if rpc is restricted
+// scdryrun without gas limit and historic getsc/getscstate are refused by their handlers
+var restricted_methods = map[string]bool{
+	"getblocktemplate": true,
+	"submitblock":      true,
+	"tracetransaction": true,
+}
+
+// rpc server is public facing, hide restricted methods
+var restricted bool
+
+type rpc_handler struct {
+	mr *jsonrpc.MethodRepository
+}
+
+func (h rpc_handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		send_rpc_response(w, rpc_error(nil, jsonrpc.ErrInvalidRequest()))
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, RPC_MAX_REQUEST_SIZE))
+	if err != nil {
+		send_rpc_response(w, rpc_error(nil, jsonrpc.ErrInvalidRequest()))
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	ip := remote_ip(r)
+
+	if len(body) == 0 || body[0] != '[' { // single call
+		var v interface{}
+		if err := fastjson.Unmarshal(body, &v); err != nil {
+			send_rpc_response(w, rpc_error(nil, jsonrpc.ErrParse()))
+			return
+		}
+		if response := h.invoke(r.Context(), ip, body); response != nil {
+			send_rpc_response(w, response)
+		}
+		return
+	}
+
+	var batch []fastjson.RawMessage
+	if err := fastjson.Unmarshal(body, &batch); err != nil {
+		send_rpc_response(w, rpc_error(nil, jsonrpc.ErrParse()))
+		return
+	}
+
+	if len(batch) == 0 {
+		send_rpc_response(w, rpc_error(nil, jsonrpc.ErrInvalidRequest()))
+		return
+	}
+	if len(batch) > RPC_MAX_BATCH {
+		send_rpc_response(w, rpc_error(nil, &jsonrpc.Error{Code: jsonrpc.ErrorCodeInvalidRequest, Message: "Batch too large"}))
+		return
+	}
+
+	responses := []*jsonrpc.Response{}
+	for i := range batch {
+		if response := h.invoke(r.Context(), ip, batch[i]); response != nil {
+			responses = append(responses, response)
+		}
+	}
+
+	if len(responses) > 0 { // nothing is returned if batch contained only notifications
+		send_rpc_response(w, responses)
+	}
+}
+
+// executes a single call, nil is returned for notifications
+func (h rpc_handler) invoke(c context.Context, ip string, raw []byte) *jsonrpc.Response {
+	var members map[string]*fastjson.RawMessage
+	var request jsonrpc.Request
+	if fastjson.Unmarshal(raw, &members) != nil || members == nil || fastjson.Unmarshal(raw, &request) != nil {
+		return rpc_error(nil, jsonrpc.ErrInvalidRequest())
+	}
+
+	response := h.call(c, ip, &request)
+	if _, ok := members["id"]; !ok {
+		return nil
+	}
+	return response
+}
+
+func (h rpc_handler) call(c context.Context, ip string, request *jsonrpc.Request) *jsonrpc.Response {
+	if restricted && restricted_methods[request.Method] {
+		return rpc_error(request.ID, jsonrpc.ErrMethodNotFound())
+	}
+
+	if !limiter.Allow(ip, request.Method) {
+		logger.Debugf("Rate limited %s calling %s", ip, request.Method)
+		return rpc_error(request.ID, &jsonrpc.Error{Code: RPC_ERROR_RATE_LIMITED, Message: "Too many requests"})
+	}
+
+	return h.mr.InvokeMethod(c, request)
+}
+
+func rpc_error(id *fastjson.RawMessage, err *jsonrpc.Error) *jsonrpc.Response {
+	return &jsonrpc.Response{ID: id, Version: jsonrpc.Version, Error: err}
+}
+
+func send_rpc_response(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := fastjson.NewEncoder(w).Encode(response); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
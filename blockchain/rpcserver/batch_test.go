@@ -0,0 +1,145 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+
+package rpcserver
+
+import "strings"
+import "testing"
+import "net/http"
+import "net/http/httptest"
+
+import log "github.com/sirupsen/logrus"
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+import "github.com/deroproject/derosuite/structures"
+
+// posts body to a rpc handler with only Main.Echo registered
+func rpc_post(t *testing.T, body string) string {
+	mr := jsonrpc.NewMethodRepository()
+	mr.RegisterMethod("Main.Echo", EchoHandler{}, EchoParams{}, EchoResult{})
+	mr.RegisterMethod("submitblock", EchoHandler{}, EchoParams{}, EchoResult{})
+	mr.RegisterMethod("tracetransaction", EchoHandler{}, EchoParams{}, EchoResult{})
+
+	request := httptest.NewRequest("POST", "/json_rpc", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.RemoteAddr = "192.0.2.1:1234"
+
+	recorder := httptest.NewRecorder()
+	rpc_handler{mr: mr}.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected http status %d", recorder.Code)
+	}
+	return strings.TrimSpace(recorder.Body.String())
+}
+
+func Test_RPC_Batch(t *testing.T) {
+	logger = log.New().WithFields(log.Fields{})
+	limiter = nil
+
+	tests := []struct {
+		request, response string
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"Main.Echo","params":{"name":"dero"}}`, `{"id":1,"jsonrpc":"2.0","result":{"message":"Hello, dero"}}`},
+		{`[{"jsonrpc":"2.0","id":1,"method":"Main.Echo","params":{"name":"a"}},{"jsonrpc":"2.0","id":2,"method":"Main.Echo","params":{"name":"b"}}]`,
+			`[{"id":1,"jsonrpc":"2.0","result":{"message":"Hello, a"}},{"id":2,"jsonrpc":"2.0","result":{"message":"Hello, b"}}]`},
+		{`[{"jsonrpc":"2.0","method":"Main.Echo"},{"jsonrpc":"2.0","id":2,"method":"Main.Echo","params":{"name":"b"}}]`,
+			`[{"id":2,"jsonrpc":"2.0","result":{"message":"Hello, b"}}]`}, // notification is not responded
+		{`{"jsonrpc":"2.0","method":"Main.Echo"}`, ``},
+		{`[1,{"jsonrpc":"2.0","id":2,"method":"unknown"}]`, `[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"}},{"id":2,"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"}}]`},
+		{`[]`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"}}`},
+		{`[{"jsonrpc":"2.0"`, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"}}`},
+	}
+
+	for i := range tests {
+		if response := rpc_post(t, tests[i].request); response != tests[i].response {
+			t.Fatalf("test %d expected %s actual %s", i, tests[i].response, response)
+		}
+	}
+
+	batch := "[" + strings.Repeat(`{"jsonrpc":"2.0","id":1,"method":"Main.Echo"},`, RPC_MAX_BATCH) + `{"jsonrpc":"2.0","id":1,"method":"Main.Echo"}]`
+	if response := rpc_post(t, batch); !strings.Contains(response, "Batch too large") {
+		t.Fatalf("oversized batch must be rejected %s", response)
+	}
+}
+
+func Test_RPC_Restricted(t *testing.T) {
+	logger = log.New().WithFields(log.Fields{})
+	limiter = nil
+
+	for _, method := range []string{"submitblock", "tracetransaction"} {
+		request := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{"name":"a"}}`
+		restricted = true
+		if response := rpc_post(t, request); !strings.Contains(response, "Method not found") {
+			t.Fatalf("restricted method must be hidden %s", response)
+		}
+		restricted = false
+		if response := rpc_post(t, request); strings.Contains(response, "error") {
+			t.Fatalf("method must be available if not restricted %s", response)
+		}
+	}
+
+	// dry run without gas limit is refused before touching the chain
+	restricted = true
+	defer func() { restricted = false }()
+	params := fastjson.RawMessage(`{"scid":"0000000000000000000000000000000000000000000000000000000000000001","entrypoint":"Initialize"}`)
+	result, err := SC_DryRun_Handler{}.ServeJSONRPC(nil, &params)
+	if err != nil || !strings.Contains(result.(structures.SC_DryRun_Result).Status, "Gas limit is required") {
+		t.Fatalf("dry run without gas must be refused %+v err %v", result, err)
+	}
+}
+
+func Test_RPC_RateLimit(t *testing.T) {
+	logger = log.New().WithFields(log.Fields{})
+
+	var err error
+	if _, err = new_rate_limiter("", "getblock=abc"); err == nil {
+		t.Fatalf("invalid rate limit must fail")
+	}
+
+	// without defaults, only given methods are limited
+	if limiter, err = new_rate_limiter("", "Main.Echo=1:2"); err != nil {
+		t.Fatalf("rate limit parsing failed err %s", err)
+	}
+	for i := 0; i < 200; i++ {
+		if !limiter.Allow("192.0.2.5", "scdryrun") || !limiter.Allow("192.0.2.5", "unknown") {
+			t.Fatalf("methods without limits must not be limited")
+		}
+	}
+
+	if limiter, err = new_rate_limiter(RPC_DEFAULT_RATELIMIT, "Main.Echo=1:2"); err != nil {
+		t.Fatalf("rate limit parsing failed err %s", err)
+	}
+	defer func() { limiter = nil }()
+
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"Main.Echo"},{"jsonrpc":"2.0","id":2,"method":"Main.Echo"},{"jsonrpc":"2.0","id":3,"method":"Main.Echo"}]`
+	response := rpc_post(t, batch)
+	if strings.Count(response, "Too many requests") != 1 || !strings.Contains(response, `{"id":3,"jsonrpc":"2.0","error":{"code":-32005`) {
+		t.Fatalf("only third call must be rate limited %s", response)
+	}
+
+	// other ips and loopback are limited separately
+	if !limiter.Allow("192.0.2.2", "Main.Echo") || !limiter.Allow("127.0.0.1", "Main.Echo") {
+		t.Fatalf("rate limit must be per ip")
+	}
+
+	// unknown methods share the default limit and do not get limiters of their own
+	for i := 0; i < 100; i++ {
+		limiter.Allow("192.0.2.3", "unknown"+string(rune('a'+i%26)))
+	}
+	if limiter.Allow("192.0.2.3", "some_other") {
+		t.Fatalf("default limit must be shared by unknown methods")
+	}
+
+	// contract execution has its own, lower default limit
+	for i := 0; i < 4; i++ {
+		if !limiter.Allow("192.0.2.4", "scdryrun") {
+			t.Fatalf("scdryrun burst must be allowed")
+		}
+	}
+	if limiter.Allow("192.0.2.4", "scdryrun") || !limiter.Allow("192.0.2.4", "getblockcount") {
+		t.Fatalf("scdryrun must be limited separately from default")
+	}
+}
@@ -78,7 +78,12 @@ func (h GetBlockTemplate_Handler) ServeJSONRPC(c context.Context, params *fastjs
 		}, nil
 	}
 
-	bl, block_hashing_blob_hex, block_template_hex, reserved_pos := chain.Create_new_block_template_mining(chain.Get_Top_ID(), *miner_address, int(p.Reserve_size))
+	return block_template(*miner_address, p.Reserve_size), nil
+}
+
+// creates a new block template for the miner address, also pushed on websocket subscriptions
+func block_template(miner_address address.Address, reserve_size uint64) structures.GetBlockTemplate_Result {
+	bl, block_hashing_blob_hex, block_template_hex, reserved_pos := chain.Create_new_block_template_mining(chain.Get_Top_ID(), miner_address, int(reserve_size))
 
 	prev_hash := ""
 	for i := range bl.Tips {
@@ -94,6 +99,5 @@ func (h GetBlockTemplate_Handler) ServeJSONRPC(c context.Context, params *fastjs
 		Epoch:              uint64(uint64(time.Now().UTC().Unix()) + config.BLOCK_TIME), // expiry time of this block
 		Difficulty:         chain.Get_Difficulty_At_Tips(nil, bl.Tips).Uint64(),
 		Status:             "OK",
-	}, nil
-
+	}
 }
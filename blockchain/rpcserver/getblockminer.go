@@ -0,0 +1,52 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// returns miner tx keys of a block, requires --index
+// owner of the reward can recognise the block using view key
+import "context"
+
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/structures"
+
+type GetBlockMiner_Handler struct{}
+
+func (h GetBlockMiner_Handler) ServeJSONRPC(c context.Context, params *fastjson.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p structures.GetBlockMiner_Params
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	if !chain.Is_Indexing() {
+		return structures.GetBlockMiner_Result{Status: structures.INDEX_DISABLED_STATUS}, nil
+	}
+
+	miner, err := chain.Index_Block_Miner(nil, crypto.HashHexToHash(p.Hash))
+	if err != nil {
+		return nil, jsonrpc.ErrInvalidParams()
+	}
+
+	return structures.GetBlockMiner_Result{
+		Miner_TX:      miner.Miner_TX.String(),
+		TX_Public_Key: miner.TX_Public_Key.String(),
+		Output_Key:    miner.Output_Key.String(),
+		Status:        "OK",
+	}, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// returns SC source, function list, balance and stored values, at current or a historic topoheight
+import "sort"
+import "context"
+
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/blockchain"
+import "github.com/deroproject/derosuite/structures"
+
+const SC_MAX_KEYS = 1024 // maximum keys queried in a single call
+
+// getsc and getscstate replay SC changes back to historic topoheight, which public nodes do not allow
+const RPC_HISTORIC_RESTRICTED_STATUS = "Historic SC state is not available on restricted nodes"
+
+type GetSC_Handler struct{}
+
+func (h GetSC_Handler) ServeJSONRPC(c context.Context, params *fastjson.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p structures.GetSC_Params
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	if len(p.KeysUint64)+len(p.KeysString) > SC_MAX_KEYS {
+		return nil, jsonrpc.ErrInvalidParams()
+	}
+
+	scid := crypto.Key(crypto.HashHexToHash(p.SCID))
+
+	// everything is read within a single TX, so as code, balance and values are from the same state
+	dbtx, err := chain.Begin_Read_TX()
+	if err != nil {
+		return nil, jsonrpc.ErrInternal()
+	}
+	defer dbtx.Rollback()
+
+	topoheight := chain.Load_TOPO_HEIGHT(dbtx)
+	result := structures.GetSC_Result{TopoHeight: topoheight, Functions: []structures.SC_Function{}, ValuesUint64: []structures.SC_Variable{}, ValuesString: []structures.SC_Variable{}}
+	if p.TopoHeight >= 1 && p.TopoHeight < topoheight { // historic state
+		if restricted { // replaying SC changes is expensive
+			result.Status = RPC_HISTORIC_RESTRICTED_STATUS
+			return result, nil
+		}
+		if err := chain.Check_SC_Replay(dbtx, p.TopoHeight); err != nil {
+			result.Status = err.Error()
+			return result, nil
+		}
+		result.TopoHeight = p.TopoHeight
+	}
+
+	if !chain.Is_SC_Installed(dbtx, scid, result.TopoHeight) {
+		result.Status = "SC not found"
+		return result, nil
+	}
+
+	sc, _ := chain.ReadSC(dbtx, scid)
+	code, _ := chain.ReadSC_Code(dbtx, scid)
+	if result.TopoHeight != topoheight { // code may have been replaced later
+		code, _ = chain.ReadSC_Code_At(dbtx, scid, result.TopoHeight)
+		sc, _, _ = dvm.ParseSmartContract(code)
+	}
+	for _, name := range sc_function_names(sc) {
+		function := sc.Functions[name]
+		f := structures.SC_Function{Name: name, Params: []structures.SC_Variable{}, Return: function.ReturnValue.Type.String()}
+		for _, param := range function.Params {
+			f.Params = append(f.Params, structures.SC_Variable{Type: param.Type.String(), Value: param.Name})
+		}
+		result.Functions = append(result.Functions, f)
+	}
+
+	if p.Code {
+		result.Code = code
+	}
+
+	keyhashes := []crypto.Key{blockchain.SC_Balance_Hash(scid)}
+	for _, key := range p.KeysUint64 {
+		keyhashes = append(keyhashes, blockchain.SC_Key_Hash(dvm.Variable{Type: dvm.Uint64, Value: key}))
+	}
+	for _, key := range p.KeysString {
+		keyhashes = append(keyhashes, blockchain.SC_Key_Hash(dvm.Variable{Type: dvm.String, Value: key}))
+	}
+
+	historic := int64(-1)
+	if result.TopoHeight != topoheight {
+		historic = result.TopoHeight
+	}
+	values, err := chain.LoadSCValues_At(dbtx, scid, keyhashes, historic)
+	if err != nil {
+		result.Status = err.Error()
+		return result, nil
+	}
+
+	if values[0].Type == dvm.Uint64 {
+		result.Balance = values[0].Value.(uint64)
+	}
+	values = values[1:]
+	for i := range p.KeysUint64 {
+		result.ValuesUint64 = append(result.ValuesUint64, sc_variable(values[i]))
+	}
+	values = values[len(p.KeysUint64):]
+	for i := range p.KeysString {
+		result.ValuesString = append(result.ValuesString, sc_variable(values[i]))
+	}
+
+	result.Status = "OK"
+	return result, nil
+}
+
+// function names in fixed order
+func sc_function_names(sc dvm.SmartContract) (names []string) {
+	for name := range sc.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
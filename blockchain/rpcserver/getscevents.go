@@ -0,0 +1,51 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// returns events emitted by an SC using EMIT within a topoheight range, in chain order
+import "context"
+
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/structures"
+
+type GetSCEvents_Handler struct{}
+
+func (h GetSCEvents_Handler) ServeJSONRPC(c context.Context, params *fastjson.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p structures.GetSCEvents_Params
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	if p.TopoHeight_End < p.TopoHeight_Start {
+		return nil, jsonrpc.ErrInvalidParams()
+	}
+
+	scid := crypto.Key(crypto.HashHexToHash(p.SCID))
+	entries, err := chain.Load_SC_Events_Range(nil, scid, p.Name, p.TopoHeight_Start, p.TopoHeight_End)
+	if err != nil {
+		return structures.GetSCEvents_Result{Status: err.Error()}, nil
+	}
+
+	result := structures.GetSCEvents_Result{Events: []structures.SC_Event_Info{}, Status: "OK"}
+	for i := range entries {
+		result.Events = append(result.Events, structures.SC_Event_Info{TXID: entries[i].TXID.String(), TopoHeight: entries[i].TopoHeight, Event: sc_event(entries[i].Event)})
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// returns complete SC state at current or a historic topoheight
+import "sort"
+import "bytes"
+import "context"
+
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/blockchain"
+import "github.com/deroproject/derosuite/structures"
+
+type GetSCState_Handler struct{}
+
+func (h GetSCState_Handler) ServeJSONRPC(c context.Context, params *fastjson.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p structures.GetSCState_Params
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	scid := crypto.Key(crypto.HashHexToHash(p.SCID))
+
+	// state is read within a single TX, so as a block added meanwhile is not seen partially
+	dbtx, err := chain.Begin_Read_TX()
+	if err != nil {
+		return nil, jsonrpc.ErrInternal()
+	}
+	defer dbtx.Rollback()
+
+	result := structures.GetSCState_Result{TopoHeight: chain.Load_TOPO_HEIGHT(dbtx), Values: []structures.SC_KeyValue{}}
+	if p.TopoHeight >= 1 && p.TopoHeight < result.TopoHeight {
+		if restricted {
+			result.Status = RPC_HISTORIC_RESTRICTED_STATUS
+			return result, nil
+		}
+		result.TopoHeight = p.TopoHeight
+	}
+
+	if !chain.Is_SC_Installed(dbtx, scid, result.TopoHeight) {
+		result.Status = "SC not found"
+		return result, nil
+	}
+
+	state, err := chain.Load_SC_State_At(dbtx, scid, result.TopoHeight)
+	if err != nil {
+		result.Status = err.Error()
+		return result, nil
+	}
+
+	balance_hash := blockchain.SC_Balance_Hash(scid)
+	if balance, ok := state[balance_hash]; ok && balance.Type == dvm.Uint64 {
+		result.Balance = balance.Value.(uint64)
+	}
+	delete(state, balance_hash)
+
+	keyhashes := make([]crypto.Key, 0, len(state))
+	for keyhash := range state {
+		keyhashes = append(keyhashes, keyhash)
+	}
+	sort.Slice(keyhashes, func(i, j int) bool { return bytes.Compare(keyhashes[i][:], keyhashes[j][:]) == -1 })
+
+	for _, keyhash := range keyhashes {
+		result.Values = append(result.Values, structures.SC_KeyValue{Key: keyhash.String(), Value: sc_variable(state[keyhash])})
+	}
+
+	result.Status = "OK"
+	return result, nil
+}
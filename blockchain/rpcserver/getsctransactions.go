@@ -0,0 +1,60 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// lists valid txs which invoked an SC, in chain order, requires --index
+import "context"
+
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/structures"
+
+const SC_TXS_DEFAULT_COUNT = 100
+const SC_TXS_MAX_COUNT = 1000
+
+type GetSCTransactions_Handler struct{}
+
+func (h GetSCTransactions_Handler) ServeJSONRPC(c context.Context, params *fastjson.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p structures.GetSCTransactions_Params
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	if !chain.Is_Indexing() {
+		return structures.GetSCTransactions_Result{Status: structures.INDEX_DISABLED_STATUS}, nil
+	}
+
+	if p.Count <= 0 {
+		p.Count = SC_TXS_DEFAULT_COUNT
+	}
+	if p.Count > SC_TXS_MAX_COUNT {
+		p.Count = SC_TXS_MAX_COUNT
+	}
+
+	entries, err := chain.Index_SC_TXs(nil, crypto.HashHexToHash(p.SCID), p.TopoHeight, p.Count)
+	if err != nil {
+		return nil, jsonrpc.ErrInvalidParams()
+	}
+
+	result := structures.GetSCTransactions_Result{Txs: []structures.SC_Transaction_Info{}, Status: "OK"}
+	for i := range entries {
+		result.Txs = append(result.Txs, structures.SC_Transaction_Info{TXID: entries[i].TXID.String(), TopoHeight: entries[i].TopoHeight})
+	}
+	return result, nil
+}
@@ -27,6 +27,7 @@ import "github.com/vmihailenco/msgpack"
 
 import "github.com/deroproject/derosuite/crypto"
 import "github.com/deroproject/derosuite/globals"
+import "github.com/deroproject/derosuite/blockchain"
 import "github.com/deroproject/derosuite/structures"
 import "github.com/deroproject/derosuite/transaction"
 
@@ -78,6 +79,12 @@ func gettransactions_fill(p structures.GetTransaction_Params) (result structures
 					// topo height at which it was mined
 					related.Block_Height = int64(chain.Load_TX_Height(nil, hash))
 
+					// pruned tx cannot be verified, so clients must fetch it from an archival node if required
+					if tx.IsPruned() {
+						related.Pruned = true
+						related.Prunable_Hash = tx.RctSignature.PrunableHash().String()
+					}
+
 					if tx.IsCoinbase() { // fill reward but only for coinbase
 						blhash, err := chain.Load_Block_Topological_order_at_index(nil, int64(related.Block_Height))
 						if err == nil { // if err return err
@@ -136,6 +143,10 @@ func gettransactions_fill(p structures.GetTransaction_Params) (result structures
 
 						related.SC, _ = chain.ReadSC(nil, crypto.Key(hash))
 
+						if receipt, ok := chain.Load_SCReceipt(nil, crypto.Key(hash)); ok {
+							related.SC_Receipt = sc_receipt(receipt)
+						}
+
 						err = nil
 					}
 
@@ -213,3 +224,9 @@ func gettransactions_fill(p structures.GetTransaction_Params) (result structures
 	result.Status = "OK"
 	return
 }
+
+func sc_receipt(receipt blockchain.SC_Receipt) *structures.SC_Receipt {
+	return &structures.SC_Receipt{SCID: receipt.SCID.String(), EntryPoint: receipt.EntryPoint,
+		Value: receipt.Value, Refund: receipt.Refund, Success: receipt.Success, Error: receipt.Error, Return: sc_variable(receipt.Returned()),
+		Gas_Used: receipt.Gas_Used, Lines: receipt.Lines}
+}
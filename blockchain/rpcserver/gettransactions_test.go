@@ -0,0 +1,31 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+
+package rpcserver
+
+import "testing"
+import "encoding/json"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/blockchain"
+
+func Test_SC_Receipt_Result(t *testing.T) {
+	receipt := blockchain.SC_Receipt{SCID: crypto.Key{1}, EntryPoint: "Withdraw", Value: 300, Refund: 250, Error: "entrypoint returned 7",
+		Return: dvm.Serialize_Variable(dvm.Variable{Type: dvm.Uint64, Value: uint64(7)}), Gas_Used: 5, Lines: 3}
+
+	result, _ := json.Marshal(sc_receipt(receipt))
+	expected := `{"scid":"` + crypto.Key{1}.String() + `","entrypoint":"Withdraw","value":300,"refund":250,"success":false,"error":"entrypoint returned 7","return":{"type":"Uint64","value":7},"gas_used":5,"lines":3}`
+	if string(result) != expected {
+		t.Fatalf("receipt result mismatch\nexpected %s\nactual   %s", expected, result)
+	}
+
+	// tx which did not execute has no return value
+	result, _ = json.Marshal(sc_receipt(blockchain.SC_Receipt{SCID: crypto.Key{1}, Success: true}))
+	expected = `{"scid":"` + crypto.Key{1}.String() + `","entrypoint":"","value":0,"refund":0,"success":true,"return":{"type":"Invalid"},"gas_used":0,"lines":0}`
+	if string(result) != expected {
+		t.Fatalf("receipt result mismatch\nexpected %s\nactual   %s", expected, result)
+	}
+}
@@ -42,5 +42,9 @@ func (h GetTxPool_Handler) ServeJSONRPC(c context.Context, params *fastjson.RawM
 		result.Tx_list = append(result.Tx_list, fmt.Sprintf("%s", pool_list[i]))
 	}
 
+	for _, evicted := range chain.Mempool.Mempool_List_Evicted() {
+		result.Evicted = append(result.Evicted, structures.TxPool_Evicted{TXID: evicted.TXID.String(), Reason: evicted.Reason, Detail: evicted.Detail, Time: evicted.Time})
+	}
+
 	return result, nil
 }
@@ -0,0 +1,154 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// this file implements per IP, per method rate limiting, so as a public node cannot be exhausted by spam
+// limits are given as method=rate:burst pairs separated by comma, rate is requests per second
+// only restricted nodes are limited by default, other nodes are limited only by --rpc-ratelimit
+// methods without their own limit share the default limit of the IP
+// a rate of 0 disables limiting for the method
+// requests from loopback are never limited, since they come from own miner/wallet
+// NOTE: behind a reverse proxy on the same host every request arrives from loopback, so all limits are off,
+// such nodes must rate limit in the proxy or bind the proxy to a non loopback address
+import "fmt"
+import "net"
+import "strconv"
+import "strings"
+import "net/http"
+
+import "golang.org/x/time/rate"
+import "github.com/hashicorp/golang-lru"
+
+// used by restricted nodes, any limits provided by --rpc-ratelimit are merged on top of these
+// scdryrun and tracetransaction execute smart contracts, so they are limited much more than lookups
+const RPC_DEFAULT_RATELIMIT = "default=50:100,getblock=10:20,gettransactions=10:20,getoutputs.bin=2:4,gettxpool=5:10,scdryrun=2:4,tracetransaction=2:4"
+
+const RPC_RATELIMIT_DEFAULT_KEY = "default"
+const RPC_RATELIMIT_TRACKED = 16384 // number of ip,method pairs tracked, least recently used are discarded
+
+type rate_spec struct {
+	rate  rate.Limit
+	burst int
+}
+
+type rate_limiter struct {
+	methods  map[string]rate_spec
+	limiters *lru.Cache // ip + method => *rate.Limiter
+}
+
+// rate limiter used by the rpc server, nil means no limits
+var limiter *rate_limiter
+
+// parses limits as method=rate:burst,method=rate:burst
+func parse_rate_limits(spec string, methods map[string]rate_spec) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid rate limit '%s', expected method=rate:burst", item)
+		}
+
+		values := strings.SplitN(parts[1], ":", 2)
+		r, err := strconv.ParseFloat(values[0], 64)
+		if err != nil || r < 0 {
+			return fmt.Errorf("invalid rate in '%s'", item)
+		}
+
+		burst := int(r) // burst defaults to one second worth of requests
+		if len(values) == 2 {
+			if burst, err = strconv.Atoi(values[1]); err != nil || burst < 1 {
+				return fmt.Errorf("invalid burst in '%s'", item)
+			}
+		}
+		if burst < 1 {
+			burst = 1
+		}
+
+		methods[parts[0]] = rate_spec{rate: rate.Limit(r), burst: burst}
+	}
+	return nil
+}
+
+// creates a limiter from default limits, overridden by the provided spec
+// methods without limits, and the default key if not given, are not limited
+func new_rate_limiter(defaults string, spec string) (*rate_limiter, error) {
+	l := &rate_limiter{methods: map[string]rate_spec{}}
+	if err := parse_rate_limits(defaults, l.methods); err != nil {
+		return nil, err
+	}
+	if err := parse_rate_limits(spec, l.methods); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if l.limiters, err = lru.New(RPC_RATELIMIT_TRACKED); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// whether the ip may call the method now
+func (l *rate_limiter) Allow(ip string, method string) bool {
+	if l == nil {
+		return true
+	}
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.IsLoopback() {
+		return true
+	}
+
+	spec, ok := l.methods[method]
+	if !ok { // arbitrary method names must not create new limiters
+		method = RPC_RATELIMIT_DEFAULT_KEY
+		spec = l.methods[method]
+	}
+	if spec.rate == 0 {
+		return true
+	}
+
+	key := ip + " " + method
+	if v, ok := l.limiters.Get(key); ok {
+		return v.(*rate.Limiter).Allow()
+	}
+	method_limiter := rate.NewLimiter(spec.rate, spec.burst)
+	l.limiters.Add(key, method_limiter)
+	return method_limiter.Allow()
+}
+
+// ip of the client, port is discarded
+// forwarded headers are not trusted, since they can be spoofed
+func remote_ip(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// wraps the plain http endpoints with rate limiting, name is used as method
+func rate_limited(name string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !limiter.Allow(remote_ip(r), name) {
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		handler(w, r)
+	}
+}
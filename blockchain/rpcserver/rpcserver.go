@@ -72,6 +72,25 @@ func RPCServer_Start(params map[string]interface{}) (*RPCServer, error) {
 	logger = globals.Logger.WithFields(log.Fields{"com": "RPC"}) // all components must use this logger
 	chain = params["chain"].(*blockchain.Blockchain)
 
+	if globals.Arguments["--rpc-restricted"] != nil && globals.Arguments["--rpc-restricted"].(bool) {
+		restricted = true
+		logger.Infof("RPC is restricted, mining, admin and tracing methods and historic SC state are not available, SC dry runs are limited to %d gas", RPC_DRYRUN_GAS_RESTRICTED)
+	}
+
+	// default limits are only applied to restricted nodes, others are limited only if asked for
+	ratelimit_defaults, ratelimit := "", ""
+	if restricted {
+		ratelimit_defaults = RPC_DEFAULT_RATELIMIT
+	}
+	if globals.Arguments["--rpc-ratelimit"] != nil {
+		ratelimit = globals.Arguments["--rpc-ratelimit"].(string)
+	}
+	if ratelimit_defaults != "" || ratelimit != "" {
+		if limiter, err = new_rate_limiter(ratelimit_defaults, ratelimit); err != nil {
+			return nil, fmt.Errorf("--rpc-ratelimit is invalid, err %s", err)
+		}
+	}
+
 	/*
 		// test whether chain is okay
 		if chain.Get_Height() == 0 {
@@ -163,6 +182,38 @@ func (r *RPCServer) Run() {
 		log.Fatalln(err)
 	}
 
+	if err := mr.RegisterMethod("getsctransactions", GetSCTransactions_Handler{}, structures.GetSCTransactions_Params{}, structures.GetSCTransactions_Result{}); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := mr.RegisterMethod("gettxblocks", GetTXBlocks_Handler{}, structures.GetTXBlocks_Params{}, structures.GetTXBlocks_Result{}); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := mr.RegisterMethod("getblockminer", GetBlockMiner_Handler{}, structures.GetBlockMiner_Params{}, structures.GetBlockMiner_Result{}); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := mr.RegisterMethod("scdryrun", SC_DryRun_Handler{}, structures.SC_DryRun_Params{}, structures.SC_DryRun_Result{}); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := mr.RegisterMethod("getsc", GetSC_Handler{}, structures.GetSC_Params{}, structures.GetSC_Result{}); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := mr.RegisterMethod("getscstate", GetSCState_Handler{}, structures.GetSCState_Params{}, structures.GetSCState_Result{}); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := mr.RegisterMethod("getscevents", GetSCEvents_Handler{}, structures.GetSCEvents_Params{}, structures.GetSCEvents_Result{}); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := mr.RegisterMethod("tracetransaction", TraceTransaction_Handler{}, structures.TraceTransaction_Params{}, structures.TraceTransaction_Result{}); err != nil {
+		log.Fatalln(err)
+	}
+
 	// create a new mux
 	r.mux = http.NewServeMux()
 
@@ -217,16 +268,19 @@ func (r *RPCServer) Run() {
 	r.mux.Handle("/static/", http.FileServer(http.Dir("./webroot")))
 
 	//r.mux.HandleFunc("/", hello)
-	r.mux.Handle("/json_rpc", mr)
+	r.mux.Handle("/json_rpc", rpc_handler{mr: mr}) // supports batches and rate limits
 
 	// handle nasty http requests
-	r.mux.HandleFunc("/getheight", getheight)
-	r.mux.HandleFunc("/getoutputs.bin", getoutputs) // stream any outputs to server, can make wallet work offline
-	r.mux.HandleFunc("/gettransactions", gettransactions)
-	r.mux.HandleFunc("/sendrawtransaction", SendRawTransaction_Handler)
-	r.mux.HandleFunc("/is_key_image_spent", iskeyimagespent)
+	r.mux.HandleFunc("/getheight", rate_limited("getheight", getheight))
+	r.mux.HandleFunc("/getoutputs.bin", rate_limited("getoutputs.bin", getoutputs)) // stream any outputs to server, can make wallet work offline
+	r.mux.HandleFunc("/gettransactions", rate_limited("gettransactions", gettransactions))
+	r.mux.HandleFunc("/sendrawtransaction", rate_limited("sendrawtransaction", SendRawTransaction_Handler))
+	r.mux.HandleFunc("/is_key_image_spent", rate_limited("is_key_image_spent", iskeyimagespent))
+
+	// push notifications for new blocks, txs, reorgs and block templates
+	r.mux.HandleFunc("/ws", rate_limited("ws", r.ws_server().ServeHTTP))
 
-	if DEBUG_MODE {
+	if DEBUG_MODE && !restricted { // debug endpoints are never exposed on public nodes
 		// r.mux.HandleFunc("/debug/pprof/", pprof.Index)
 
 		// Register pprof handlers individually if required
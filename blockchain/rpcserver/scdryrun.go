@@ -0,0 +1,118 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// runs an SC entrypoint against current chain state without persisting anything
+// returns the result, PRINT output, attempted storage writes and transfers
+import "context"
+
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/structures"
+
+// dry runs execute SC code for anyone asking, public nodes allow far less gas than a tx may use
+const RPC_DRYRUN_GAS_RESTRICTED = 100000
+
+type SC_DryRun_Handler struct{}
+
+func (h SC_DryRun_Handler) ServeJSONRPC(c context.Context, params *fastjson.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p structures.SC_DryRun_Params
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	scid := crypto.Key(crypto.HashHexToHash(p.SCID))
+	max_gas := uint64(0) // maximum allowed by dvm
+
+	// public nodes only run dry runs with an explicit gas limit
+	if restricted {
+		if p.Gas == 0 {
+			return structures.SC_DryRun_Result{Status: "Gas limit is required on restricted nodes"}, nil
+		}
+		max_gas = RPC_DRYRUN_GAS_RESTRICTED
+	}
+	dryrun, err := chain.DryRun_SC(scid, p.EntryPoint, p.Params, p.Value, p.Gas, max_gas, p.Signer)
+	if err != nil {
+		return structures.SC_DryRun_Result{Status: err.Error()}, nil
+	}
+
+	result := structures.SC_DryRun_Result{
+		Result:    sc_variable(dryrun.Result),
+		Output:    dryrun.Output,
+		Committed: dryrun.Committed,
+		Writes:    []structures.SC_Write{},
+		Transfers: []structures.SC_Transfer{},
+		Events:    []structures.SC_Event{},
+		Lines:     dryrun.Lines,
+		Gas_Used:  dryrun.Gas_Used,
+		Status:    "OK",
+	}
+	if dryrun.Error != nil {
+		result.Error = dryrun.Error.Error()
+	}
+
+	for _, atom := range dryrun.Writes {
+		write := structures.SC_Write{
+			SCID:     atom.Key.SCID.String(),
+			Key:      sc_variable(atom.Key.Key),
+			Previous: sc_variable(atom.Prev_Value),
+			Value:    sc_variable(atom.Value),
+		}
+		if atom.Key.Collection != dvm.Invalid {
+			write.Collection = atom.Key.Collection.String()
+			write.Name = atom.Key.Name
+		}
+		result.Writes = append(result.Writes, write)
+	}
+
+	for _, scid := range dryrun.Transfer_SCIDs() {
+		transfers := dryrun.Transfers[scid]
+		transfer := structures.SC_Transfer{
+			SCID:          scid.String(),
+			Balance_Start: transfers.BalanceAtStart,
+			Balance:       dryrun.Balances[scid],
+			Received:      append([]uint64{}, transfers.TransferI.Received...),
+			Sent:          append([]uint64{}, transfers.TransferI.Sent...),
+			External:      []structures.SC_External_Transfer{},
+		}
+		for _, e := range transfers.TransferE {
+			transfer.External = append(transfer.External, structures.SC_External_Transfer{Address: e.Address, Amount: e.Amount})
+		}
+		result.Transfers = append(result.Transfers, transfer)
+	}
+
+	for _, event := range dryrun.Events {
+		result.Events = append(result.Events, sc_event(event))
+	}
+
+	return result, nil
+}
+
+func sc_variable(v dvm.Variable) structures.SC_Variable {
+	return structures.SC_Variable{Type: v.Type.String(), Value: v.Value}
+}
+
+func sc_event(event dvm.SC_Event) structures.SC_Event {
+	result := structures.SC_Event{SCID: event.SCID.String(), Name: event.Name, Args: []structures.SC_Variable{}}
+	for _, arg := range event.Args {
+		result.Args = append(result.Args, sc_variable(arg))
+	}
+	return result
+}
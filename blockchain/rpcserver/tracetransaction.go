@@ -0,0 +1,61 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// returns stored execution trace of an SC tx, requires --sc-trace
+import "context"
+
+import "github.com/intel-go/fastjson"
+import "github.com/osamingo/jsonrpc"
+
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/structures"
+
+type TraceTransaction_Handler struct{}
+
+func (h TraceTransaction_Handler) ServeJSONRPC(c context.Context, params *fastjson.RawMessage) (interface{}, *jsonrpc.Error) {
+	var p structures.TraceTransaction_Params
+	if err := jsonrpc.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	if !chain.Is_SC_Tracing() {
+		return structures.TraceTransaction_Result{Status: structures.SC_TRACE_DISABLED_STATUS}, nil
+	}
+
+	trace, found := chain.Load_SCTrace(nil, crypto.Key(crypto.HashHexToHash(p.TXID)))
+	if !found {
+		return structures.TraceTransaction_Result{Status: "Trace not found, tx is not a valid SC tx or was executed before tracing was enabled"}, nil
+	}
+
+	result := structures.TraceTransaction_Result{
+		Entries:   []structures.SC_Trace_Entry{},
+		Lines:     trace.Lines,
+		Evals:     trace.Evals,
+		Loads:     trace.Loads,
+		Stores:    trace.Stores,
+		Transfers: trace.Transfers,
+		Gas_Used:  trace.Gas_Used,
+		Failure:   trace.Failure,
+		Truncated: trace.Truncated,
+		Status:    "OK",
+	}
+	for _, e := range trace.Entries {
+		result.Entries = append(result.Entries, structures.SC_Trace_Entry{Kind: e.Kind, SCID: e.SCID.String(), Function: e.Function, Line: e.Line, Message: e.Message})
+	}
+	return result, nil
+}
@@ -0,0 +1,322 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package rpcserver
+
+// this file implements /ws endpoint, which pushes new blocks, mempool txs, reorgs and block templates
+// so as pools and wallets do not need to poll getlastblockheader
+import "fmt"
+import "time"
+import "strings"
+import "net/http"
+import "sync"
+
+import "golang.org/x/net/websocket"
+
+import "github.com/deroproject/derosuite/address"
+import "github.com/deroproject/derosuite/blockchain"
+import "github.com/deroproject/derosuite/structures"
+
+const WS_MAX_CONNECTIONS = 256               // websocket connections allowed simultaneously
+const WS_MAX_CONNECTIONS_PER_IP = 8          // websocket connections allowed simultaneously from a single ip
+const WS_EVENT_BUFFER = 256                  // events buffered per connection, if client is slower events are dropped
+const WS_WRITE_TIMEOUT = 10 * time.Second    // client not reading for this long is disconnected
+const WS_PING_INTERVAL = 30 * time.Second    // server sends a websocket ping frame at this interval
+const WS_IDLE_TIMEOUT = 2 * WS_PING_INTERVAL // connection is dropped if nothing could be written or read for this long
+
+// keepalive: server sends a websocket ping control frame every WS_PING_INTERVAL
+// websocket libraries and browsers answer it with a pong frame on their own, clients do not need to send anything
+// every successful write or read moves the idle deadline, so clients which only listen stay connected
+// dead connections are dropped once a write fails or does not complete within WS_WRITE_TIMEOUT
+// clients may also send {"method":"ping"}, which is answered with {"method":"pong"}
+
+// websocket connections in use, total and per ip
+var ws_connections = struct {
+	sync.Mutex
+	total  int
+	per_ip map[string]int
+}{per_ip: map[string]int{}}
+
+// websocket event names
+const (
+	WS_EVENT_NEW_BLOCK      = "new_block"
+	WS_EVENT_NEW_TX         = "new_tx"
+	WS_EVENT_REORG          = "reorg"
+	WS_EVENT_BLOCK_TEMPLATE = "block_template"
+)
+
+// state of a single websocket client
+type ws_client struct {
+	ws            *websocket.Conn
+	subscribed    map[string]bool
+	miner_address *address.Address // block templates are generated for this address
+	reserve_size  uint64
+}
+
+func (r *RPCServer) ws_server() http.Handler {
+	return websocket.Server{Handler: r.ws_handler, Handshake: ws_check_origin}
+}
+
+// origin is parsed the same way as the default handshake, but it must also be the daemon itself
+// so a web page from any other site cannot open /ws from a browser
+// browsers always send origin, clients which do not send it ( pools, wallets ) are not browsers and are allowed
+func ws_check_origin(config *websocket.Config, req *http.Request) (err error) {
+	if req.Header.Get("Origin") == "" {
+		return nil
+	}
+	config.Origin, err = websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if config.Origin == nil {
+		return fmt.Errorf("null origin")
+	}
+	if !strings.EqualFold(config.Origin.Host, req.Host) {
+		return fmt.Errorf("cross origin websocket request from %s", config.Origin)
+	}
+	return nil
+}
+
+func (r *RPCServer) ws_handler(ws *websocket.Conn) {
+	defer ws.Close()
+
+	ip := remote_ip(ws.Request())
+	if !ws_acquire(ip) {
+		websocket.JSON.Send(ws, structures.WS_Notification{Error: "Too many websocket connections"})
+		return
+	}
+	defer ws_release(ip)
+
+	// server read/write timeouts do not apply to long lived connections, idle deadline is moved by every write or read
+	ws.SetDeadline(time.Time{})
+
+	client := ws_client{ws: ws, subscribed: map[string]bool{}}
+	client.alive()
+
+	events := chain.Subscribe(blockchain.EVENT_NEW_BLOCK|blockchain.EVENT_TOPO_REORDER|blockchain.EVENT_TX_ADDED, WS_EVENT_BUFFER)
+	defer chain.Unsubscribe(events)
+
+	requests := make(chan structures.WS_Request)
+	done := make(chan bool) // reader must not block once handler returns
+	defer close(done)
+	go func() {
+		defer close(requests)
+		for {
+			var request structures.WS_Request
+			if err := websocket.JSON.Receive(ws, &request); err != nil {
+				return
+			}
+			client.alive()
+			select {
+			case requests <- request:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(WS_PING_INTERVAL)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Exit_Event:
+			return
+		case <-ping.C:
+			err = client.ping()
+		case request, ok := <-requests:
+			if !ok { // client disconnected
+				return
+			}
+			err = client.handle_request(request)
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			err = client.handle_event(e)
+		}
+
+		if err != nil {
+			logger.Debugf("websocket client %s disconnected err %s", ws.Request().RemoteAddr, err)
+			return
+		}
+	}
+}
+
+// reserves a connection slot for the ip, fails if either global or per ip limit is reached
+func ws_acquire(ip string) bool {
+	ws_connections.Lock()
+	defer ws_connections.Unlock()
+	if ws_connections.total >= WS_MAX_CONNECTIONS || ws_connections.per_ip[ip] >= WS_MAX_CONNECTIONS_PER_IP {
+		return false
+	}
+	ws_connections.total++
+	ws_connections.per_ip[ip]++
+	return true
+}
+
+func ws_release(ip string) {
+	ws_connections.Lock()
+	defer ws_connections.Unlock()
+	ws_connections.total--
+	if ws_connections.per_ip[ip]--; ws_connections.per_ip[ip] <= 0 {
+		delete(ws_connections.per_ip, ip)
+	}
+}
+
+// connection is working, so it must not be dropped as idle
+// net.Conn deadlines may be set while reader goroutine is blocked in read
+func (c *ws_client) alive() {
+	c.ws.SetReadDeadline(time.Now().Add(WS_IDLE_TIMEOUT))
+}
+
+func (c *ws_client) send(msg structures.WS_Notification) error {
+	c.ws.SetWriteDeadline(time.Now().Add(WS_WRITE_TIMEOUT))
+	if err := websocket.JSON.Send(c.ws, msg); err != nil {
+		return err
+	}
+	c.alive()
+	return nil
+}
+
+// sends a websocket ping control frame, pong is answered by client library and discarded while reading
+// Write uses PayloadType as frame type, JSON codec does not, and only handler goroutine writes with either
+func (c *ws_client) ping() error {
+	c.ws.SetWriteDeadline(time.Now().Add(WS_WRITE_TIMEOUT))
+	payload_type := c.ws.PayloadType
+	c.ws.PayloadType = websocket.PingFrame
+	_, err := c.ws.Write(nil)
+	c.ws.PayloadType = payload_type
+	if err != nil {
+		return err
+	}
+	c.alive()
+	return nil
+}
+
+func (c *ws_client) handle_request(request structures.WS_Request) error {
+	reply := structures.WS_Notification{Method: request.Method, Result: "OK"}
+
+	switch request.Method {
+	case "subscribe":
+		if err := c.subscribe(request.Params); err != nil {
+			reply.Result = nil
+			reply.Error = err.Error()
+			return c.send(reply)
+		}
+		if err := c.send(reply); err != nil {
+			return err
+		}
+
+		// miners receive work immediately, instead of waiting for next block
+		if c.subscribed[WS_EVENT_BLOCK_TEMPLATE] {
+			return c.send_block_template()
+		}
+		return nil
+
+	case "unsubscribe":
+		for _, event := range request.Params.Events {
+			delete(c.subscribed, event)
+		}
+	case "ping":
+		reply.Method = "pong"
+	default:
+		reply.Result = nil
+		reply.Error = fmt.Sprintf("Unknown method '%s'", request.Method)
+	}
+	return c.send(reply)
+}
+
+// validates the subscription completely before changing anything
+func (c *ws_client) subscribe(params structures.WS_Subscribe_Params) error {
+	for _, event := range params.Events {
+		switch event {
+		case WS_EVENT_NEW_BLOCK, WS_EVENT_NEW_TX, WS_EVENT_REORG:
+		case WS_EVENT_BLOCK_TEMPLATE:
+			if restricted {
+				return fmt.Errorf("Unknown event '%s'", event)
+			}
+			miner_address, err := address.NewAddress(params.Wallet_Address)
+			if err != nil {
+				return fmt.Errorf("Wallet address could not be parsed")
+			}
+			if params.Reserve_size > 255 || params.Reserve_size < 1 {
+				return fmt.Errorf("Reserve size should be > 0 and < 255")
+			}
+			c.miner_address = miner_address
+			c.reserve_size = params.Reserve_size
+		default:
+			return fmt.Errorf("Unknown event '%s'", event)
+		}
+	}
+
+	for _, event := range params.Events {
+		c.subscribed[event] = true
+	}
+	return nil
+}
+
+func (c *ws_client) handle_event(e blockchain.Event) error {
+	switch e.Type {
+	case blockchain.EVENT_NEW_BLOCK:
+		if c.subscribed[WS_EVENT_NEW_BLOCK] {
+			block_header, err := chain.GetBlockHeader(e.BLID)
+			if err != nil {
+				return nil // block header not available, skip it
+			}
+			if err := c.send(structures.WS_Notification{Event: WS_EVENT_NEW_BLOCK, Result: block_header}); err != nil {
+				return err
+			}
+		}
+		if c.subscribed[WS_EVENT_BLOCK_TEMPLATE] {
+			return c.send_block_template()
+		}
+
+	case blockchain.EVENT_TOPO_REORDER:
+		if c.subscribed[WS_EVENT_REORG] {
+			return c.send(structures.WS_Notification{Event: WS_EVENT_REORG, Result: reorg_result(e)})
+		}
+
+	case blockchain.EVENT_TX_ADDED:
+		if c.subscribed[WS_EVENT_NEW_TX] {
+			result := structures.WS_NewTX_Result{TXID: e.TXID.String()}
+			if tx := chain.Mempool.Mempool_Get_TX(e.TXID); tx != nil {
+				result.Size = uint64(len(tx.Serialize()))
+				result.Fee = tx.RctSignature.Get_TX_Fee()
+			}
+			return c.send(structures.WS_Notification{Event: WS_EVENT_NEW_TX, Result: result})
+		}
+	}
+	return nil
+}
+
+func (c *ws_client) send_block_template() error {
+	return c.send(structures.WS_Notification{Event: WS_EVENT_BLOCK_TEMPLATE, Result: block_template(*c.miner_address, c.reserve_size)})
+}
+
+func reorg_result(e blockchain.Event) (result structures.WS_Reorg_Result) {
+	result.TopoHeight = e.TopoHeight
+	result.Old_Order = []string{}
+	result.New_Order = []string{}
+	for i := range e.Old_Order {
+		result.Old_Order = append(result.Old_Order, e.Old_Order[i].String())
+	}
+	for i := range e.New_Order {
+		result.New_Order = append(result.New_Order, e.New_Order[i].String())
+	}
+	return
+}
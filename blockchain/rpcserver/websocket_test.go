@@ -0,0 +1,135 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+
+package rpcserver
+
+import "fmt"
+import "strings"
+import "testing"
+import "net/http/httptest"
+
+import "golang.org/x/net/websocket"
+
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/blockchain"
+import "github.com/deroproject/derosuite/structures"
+
+func Test_WS_Subscribe(t *testing.T) {
+	client := ws_client{subscribed: map[string]bool{}}
+
+	if err := client.subscribe(structures.WS_Subscribe_Params{Events: []string{WS_EVENT_NEW_BLOCK, "unknown"}}); err == nil {
+		t.Fatalf("unknown event must be rejected")
+	}
+	if len(client.subscribed) != 0 {
+		t.Fatalf("failed subscription must not subscribe partially")
+	}
+
+	if err := client.subscribe(structures.WS_Subscribe_Params{Events: []string{WS_EVENT_BLOCK_TEMPLATE}, Wallet_Address: "invalid", Reserve_size: 8}); err == nil {
+		t.Fatalf("block template subscription without valid address must be rejected")
+	}
+
+	if err := client.subscribe(structures.WS_Subscribe_Params{Events: []string{WS_EVENT_NEW_BLOCK, WS_EVENT_NEW_TX, WS_EVENT_REORG}}); err != nil {
+		t.Fatalf("subscription failed err %s", err)
+	}
+	if !client.subscribed[WS_EVENT_NEW_BLOCK] || !client.subscribed[WS_EVENT_NEW_TX] || !client.subscribed[WS_EVENT_REORG] {
+		t.Fatalf("events not subscribed %+v", client.subscribed)
+	}
+}
+
+func Test_WS_Reorg_Result(t *testing.T) {
+	a, b := crypto.Hash{1}, crypto.Hash{2}
+	result := reorg_result(blockchain.Event{Type: blockchain.EVENT_TOPO_REORDER, TopoHeight: 5, Old_Order: []crypto.Hash{a, b}})
+
+	if result.TopoHeight != 5 || len(result.Old_Order) != 2 || result.Old_Order[1] != b.String() {
+		t.Fatalf("reorg result mismatch %+v", result)
+	}
+	if result.New_Order == nil || len(result.New_Order) != 0 { // must be serialized as empty array instead of null
+		t.Fatalf("removed blocks must have empty new order %+v", result)
+	}
+}
+
+func Test_WS_Check_Origin(t *testing.T) {
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true}, // not a browser
+		{"http://127.0.0.1:20206", true},
+		{"http://evil.example.com", false},
+		{"http://127.0.0.1:8080", false},
+		{"null", false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:20206/ws", nil)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		err := ws_check_origin(&websocket.Config{Version: websocket.ProtocolVersionHybi13}, req)
+		if (err == nil) != test.allowed {
+			t.Fatalf("origin %q allowed expected %v err %v", test.origin, test.allowed, err)
+		}
+	}
+}
+
+func Test_WS_Connection_Limits(t *testing.T) {
+	for i := 0; i < WS_MAX_CONNECTIONS_PER_IP; i++ {
+		if !ws_acquire("1.1.1.1") {
+			t.Fatalf("connection %d refused", i)
+		}
+	}
+	if ws_acquire("1.1.1.1") {
+		t.Fatalf("single ip must not exceed per ip limit")
+	}
+	if !ws_acquire("2.2.2.2") {
+		t.Fatalf("other ip must not be affected by per ip limit")
+	}
+
+	ws_release("1.1.1.1")
+	if !ws_acquire("1.1.1.1") {
+		t.Fatalf("released slot must be reusable")
+	}
+
+	for i := 0; i < WS_MAX_CONNECTIONS_PER_IP; i++ {
+		ws_release("1.1.1.1")
+	}
+	ws_release("2.2.2.2")
+	if ws_connections.total != 0 || len(ws_connections.per_ip) != 0 {
+		t.Fatalf("connections leaked total %d per ip %+v", ws_connections.total, ws_connections.per_ip)
+	}
+
+	for i := 0; i < WS_MAX_CONNECTIONS; i++ {
+		ws_acquire(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+	if ws_acquire("3.3.3.3") {
+		t.Fatalf("global limit must apply")
+	}
+	for i := 0; i < WS_MAX_CONNECTIONS; i++ {
+		ws_release(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+}
+
+// ping is a control frame, client libraries answer it without it reaching the application
+func Test_WS_Ping(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		client := ws_client{ws: ws, subscribed: map[string]bool{}}
+		if client.ping() != nil || client.send(structures.WS_Notification{Event: WS_EVENT_NEW_BLOCK}) != nil {
+			return
+		}
+		var request structures.WS_Request
+		websocket.JSON.Receive(ws, &request) // wait for client to close
+	}))
+	defer server.Close()
+
+	ws, err := websocket.Dial(strings.Replace(server.URL, "http", "ws", 1), "", server.URL)
+	if err != nil {
+		t.Fatalf("websocket dial failed err %s", err)
+	}
+	defer ws.Close()
+
+	var notification structures.WS_Notification
+	if err = websocket.JSON.Receive(ws, &notification); err != nil || notification.Event != WS_EVENT_NEW_BLOCK || notification.Method != "" {
+		t.Fatalf("ping must not reach client as a message %+v err %v", notification, err)
+	}
+}
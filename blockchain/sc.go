@@ -22,12 +22,14 @@ import "sort"
 import "runtime/debug"
 
 import "github.com/deroproject/derosuite/address"
+import "github.com/deroproject/derosuite/config"
 import "github.com/deroproject/derosuite/storage"
 import "github.com/deroproject/derosuite/dvm"
 import "github.com/deroproject/derosuite/crypto"
 import "github.com/deroproject/derosuite/block"
 import "github.com/deroproject/derosuite/transaction"
 
+import "github.com/romana/rlog"
 import "github.com/vmihailenco/msgpack"
 
 // this will process the SC transaction
@@ -35,11 +37,24 @@ import "github.com/vmihailenco/msgpack"
 
 func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *transaction.Transaction, hard_fork_version_current int64) {
 
+	// receipt and trace are stored even if tx fails early or panics, so as failure can be diagnosed
+	var tx_hash crypto.Hash
+	var receipt *SC_Receipt
+	var trace *dvm.Trace
+
 	defer func() {
 		// safety so if anything wrong happens, verification fails
 		if r := recover(); r != nil {
 			logger.Warnf("Recovered while rewinding chain, Stack trace below block_hash ")
 			logger.Warnf("Stack trace  \n%s", debug.Stack())
+			receipt.fail("recovered while processing SC tx %v", r)
+			trace.Fail("recovered while processing SC tx %v", r)
+		}
+		if receipt != nil {
+			chain.store_sc_receipt(dbtx, crypto.Key(tx_hash), receipt)
+		}
+		if trace != nil {
+			chain.store_sc_trace(dbtx, crypto.Key(tx_hash), trace)
 		}
 	}()
 
@@ -49,7 +64,18 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 	}
 
 	bl_hash := bl.GetHash()
-	tx_hash := tx.GetHash()
+	tx_hash = tx.GetHash()
+
+	receipt = &SC_Receipt{}
+	if chain.sc_trace {
+		trace = dvm.New_Trace()
+	}
+
+	// failures are recorded in both receipt and trace
+	fail := func(format string, args ...interface{}) {
+		receipt.fail(format, args...)
+		trace.Fail(format, args...)
+	}
 
 	addri, _ := tx.Extra_map[transaction.TX_EXTRA_ADDRESS].(address.Address)
 
@@ -59,39 +85,24 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 	       return
 	   }*/
 
-	fmt.Printf("Processing TX SC  data %d \n", len(tx.Extra_map[transaction.TX_EXTRA_SCDATA].([]byte)))
-
-	if len(tx.Extra_map[transaction.TX_EXTRA_SCDATA].([]byte)) < 3 {
-		fmt.Printf("Cannot process SCTX, since data is  less than 3 bytes\n")
-		return
-	}
-
-	// lets decode SC transaction from msgpack
-	var sc_tx transaction.SC_Transaction
-	err = msgpack.Unmarshal(tx.Extra_map[transaction.TX_EXTRA_SCDATA].([]byte), &sc_tx)
-	if err != nil {
-		fmt.Printf("SC msgpack unmarshal err %s\n", err)
-		return
-	}
-
-	fmt.Printf("sctx %+v\n", sc_tx)
-
-	// dicard any value provided with the tx and calculate from ring signature
-	sc_tx.Value = 0 // make sure value is zero
+	trace.Log("processing SC tx %s data %d bytes", tx_hash, len(tx.Extra_map[transaction.TX_EXTRA_SCDATA].([]byte)))
 
-	// check if any DERO value  is attached, if yes, attach it
+	// check if any DERO value  is attached, value provided within SC data is discarded
+	attached := uint64(0)
 	for i := 0; i < len(tx.Vout); i++ {
 		var zero crypto.Key
 		if tx.Vout[i].Amount != 0 && tx.Vout[i].Target.(transaction.Txout_to_key).Key == zero { // allow SC amounts to be open
 			// amount has already been verified as genuine by ringct
 
-			sc_tx.Value = tx.Vout[i].Amount
+			attached = tx.Vout[i].Amount
 			break
 
 		}
 	}
+	receipt.Value = attached
 
 	tx_store := dvm.Initialize_TX_store()
+	tx_store.Trace = trace
 
 	// used as value loader from disk
 	// this function is used to load any data required by the SC
@@ -100,8 +111,6 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 		var exists bool
 		keyhash := crypto.Key(crypto.Keccak256(dvm.Serialize_DataKey(key)))
 		result, exists = chain.LoadSCValue(dbtx, key.SCID, keyhash)
-
-		fmt.Printf("Loading from disk %+v  result %+v found status %+v \n", key, result, exists)
 		if exists {
 
 			*found = uint64(1)
@@ -111,7 +120,52 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 
 	tx_store.DiskLoader = diskloader // hook up loading from chain
 
+	installed := false // set once SC code is stored
+
+	// if tx fails before execution, nothing is changed and attached value is refunded to signer
+	// SC whose code is already stored remains installed with zero balance, as if Initialize failed
+	refund_attached := func() {
+		changes := dvm.Initialize_TX_store()
+		if installed {
+			changes.Trace = trace
+			changes.DiskLoader = diskloader
+			changes.Store(dvm.GetBalanceKey(crypto.Key(tx_hash)), dvm.Variable{Type: dvm.Uint64, Value: uint64(0)})
+		}
+		chain.store_changes(dbtx, crypto.Key(tx_hash), changes)
+		chain.store_sc_refund(dbtx, crypto.Key(tx_hash), addri.String(), attached)
+		receipt.Refund = attached
+	}
+
+	if len(tx.Extra_map[transaction.TX_EXTRA_SCDATA].([]byte)) < 3 {
+		fail("cannot process SC tx, since data is less than 3 bytes")
+		refund_attached()
+		return
+	}
+
+	// lets decode SC transaction from msgpack
+	var sc_tx transaction.SC_Transaction
+	err = msgpack.Unmarshal(tx.Extra_map[transaction.TX_EXTRA_SCDATA].([]byte), &sc_tx)
+	if err != nil {
+		fail("SC msgpack unmarshal err %s", err)
+		refund_attached()
+		return
+	}
+
+	// dicard any value provided with the tx and use value calculated from ring signature
+	sc_tx.Value = attached
+
+	receipt.SCID, receipt.EntryPoint = sc_tx.SCID, sc_tx.EntryPoint
+	if len(sc_tx.SC) > 0 {
+		receipt.SCID, receipt.EntryPoint = crypto.Key(tx_hash), "Initialize"
+	}
+
+	// SC to SC calls load code from chain
+	tx_store.SCLoader = func(scid crypto.Key) (dvm.SmartContract, bool) {
+		return chain.ReadSC(dbtx, scid)
+	}
+
 	entrypoint := ""
+	refund := uint64(0) // paid back to signer once changes are stored
 	var scid crypto.Key
 	var sc_parsed dvm.SmartContract
 	execute := false
@@ -123,21 +177,22 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 		sc_parsed, pos, err = dvm.ParseSmartContract(string(sc_tx.SC))
 
 		if err != nil {
-			fmt.Printf("error Parsing sc txid %s err %s pos %s\n", tx_hash, err, pos)
+			fail("error parsing SC txid %s err %s pos %s", tx_hash, err, pos)
+			refund_attached()
 			return
 		}
-
-		fmt.Printf("SC parsed\n%+v\n", sc_parsed)
+		trace.Log("installing SC %s", tx_hash)
 
 		dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, tx_hash[:], PLANET_TX_SC_BYTES, []byte(sc_tx.SC))
 
 		serialized, err := msgpack.Marshal(sc_parsed)
 
 		if err != nil {
-			fmt.Printf("err serial SC err %s\n", err)
+			fail("SC could not be serialized err %s", err)
 		}
 
 		dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, tx_hash[:], PLANET_TX_SC_PROCESSED, serialized)
+		installed = true
 
 		tx_store.DiskLoader = diskloader // hook up loading from chain
 
@@ -148,15 +203,16 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 
 		if _, ok := sc_parsed.Functions[entrypoint]; ok {
 			execute = true
-		} else {
-			fmt.Printf("stored SC  does not contain entrypoint '%s' scid %s \n", entrypoint, scid)
+		} else { // nothing is executed, so SC receives the whole value
+			trace.Log("SC does not contain entrypoint '%s' scid %s", entrypoint, scid)
+			tx_store.Balance(scid)
+			tx_store.ReceiveInternal(scid, sc_tx.Value)
 		}
 
 		// store state changes
 		//chain.store_changes(dbtx, crypto.Key(tx_hash),tx_store)
 
 		// we must also initialize and give the SC 0 balance
-		fmt.Printf(" SMART contract parsed \n")
 
 	} else {
 		// check if scid can be hex decoded
@@ -164,21 +220,26 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 		// load smart contract bytes, if loading failed , dero value is lost
 		sc_parsed_bytes, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, sc_tx.SCID[:], PLANET_TX_SC_PROCESSED)
 		if err != nil {
-			fmt.Printf("No such stored SC found %s\n", sc_tx.SCID)
+			fail("no such stored SC found %s", sc_tx.SCID)
+			refund_attached()
 			return
 		}
 
 		// deserialise
 		err = msgpack.Unmarshal(sc_parsed_bytes, &sc_parsed)
 		if err != nil {
-			fmt.Printf("stored SC (parsed) could not be deserialised scid %s err  %s\n", sc_tx.SCID, err)
+			fail("stored SC (parsed) could not be deserialised scid %s err %s", sc_tx.SCID, err)
+			refund_attached()
 			return
 		}
 
-		if sc_tx.EntryPoint != "Initialize" { // initialize cannot be triggerred again
-			execute = true
-			entrypoint = sc_tx.EntryPoint
+		if sc_tx.EntryPoint == "Initialize" { // initialize cannot be triggerred again
+			fail("entrypoint 'Initialize' cannot be called again scid %s", sc_tx.SCID)
+			refund_attached()
+			return
 		}
+		execute = true
+		entrypoint = sc_tx.EntryPoint
 		scid = sc_tx.SCID
 
 	}
@@ -187,14 +248,26 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 		// if we found the SC in parsed form, check whether entrypoint is found
 		function, ok := sc_parsed.Functions[entrypoint]
 		if !ok {
-			fmt.Printf("stored SC  does not contain entrypoint '%s' scid %s \n", entrypoint, scid)
+			fail("stored SC does not contain entrypoint '%s' scid %s", entrypoint, scid)
+			refund_attached()
 			return
 		}
 
+		value := sc_tx.Value // value received by SC
+		gas_limit := uint64(0)
+		if value, err = sc_reserve_gas(sc_tx.Value, sc_tx.Gas); err != nil {
+			fail("entrypoint '%s' scid %s %s", entrypoint, scid, err)
+			execute = false
+			value = sc_tx.Value
+		} else {
+			gas_limit = sc_tx.Gas
+		}
+		gas_reserved := gas_limit * config.SC_GAS_PRICE
+
 		if len(sc_tx.Params) == 0 { // initialize params if not initialized earlier
 			sc_tx.Params = map[string]string{}
 		}
-		sc_tx.Params["value"] = fmt.Sprintf("%d", sc_tx.Value) // overide value
+		sc_tx.Params["value"] = fmt.Sprintf("%d", value) // overide value
 
 		// we have an entrypoint, now we must setup parameters and dvm
 		// all parameters are in string form to bypass translation issues in middle layers
@@ -203,7 +276,8 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 			if param_value, ok := sc_tx.Params[p.Name]; ok {
 				params[p.Name] = param_value
 			} else { // necessary parameter is missing, bailout
-				fmt.Printf("entrypoint '%s' scid %s  parameter missing '%s' \n", entrypoint, scid, p.Name)
+				fail("entrypoint '%s' scid %s parameter missing '%s'", entrypoint, scid, p.Name)
+				refund_attached()
 				return
 			}
 		}
@@ -212,11 +286,12 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 
 		// setup balance correctly
 		tx_store.Balance(scid) // transfer any value to make sure its not lost
-		tx_store.ReceiveInternal(scid, sc_tx.Value)
+		tx_store.ReceiveInternal(scid, value)
 
 		// setup block hash, height, topoheight correctly
 		state := &dvm.Shared_State{
-			DERO_Received: sc_tx.Value,
+			DERO_Received: value,
+			Gas_Limit:     gas_limit,
 			Store:         tx_store,
 			Chain_inputs: &dvm.Blockchain_Input{
 				BL_HEIGHT:     uint64(chain.Load_Height_for_BL_ID(dbtx, bl_hash)),
@@ -227,38 +302,91 @@ func (chain *Blockchain) Process_SC(dbtx storage.DBTX, bl *block.Block, tx *tran
 				Signer:        addri},
 		}
 
-		result, err := dvm.RunSmartContract(&sc_parsed, entrypoint, state, params)
-
-		fmt.Printf("result value %+v\n", result)
-
-		if err != nil {
-			fmt.Printf("entrypoint '%s' scid %s  err execution '%s' \n", entrypoint, scid, err)
+		var result dvm.Variable
+		if execute {
+			result, err = dvm.RunSmartContract(&sc_parsed, entrypoint, state, params)
+		} else {
+			err = fmt.Errorf("insufficient value for gas")
 		}
 
+		receipt.Return = dvm.Serialize_Variable(result)
+		receipt.Gas_Used = state.Gas_Used
+		receipt.Lines = state.Monitor_lines_interpreted
+
+		// execution errors have already been traced by DVM
 		if err == nil && result.Type == dvm.Uint64 && result.Value.(uint64) == 0 {
 			// confirm the changes
 		} else { // discard all changes
+			if err == nil {
+				fail("entrypoint '%s' scid %s returned %v, changes discarded", entrypoint, scid, result.Value)
+			} else {
+				receipt.fail("%s", err)
+				rlog.Debugf("SC tx %s entrypoint '%s' scid %s failed err %s", tx_hash, entrypoint, scid, err)
+			}
 			tx_store = dvm.Initialize_TX_store()
+			tx_store.Trace = trace
 			tx_store.DiskLoader = diskloader // hook up loading from chain
-			tx_store.Balance(scid)           // transfer any value to make sure its not lost arbitrarily to the network
-			tx_store.ReceiveInternal(scid, sc_tx.Value)
+			if len(sc_tx.SC) > 0 {           // SC remains installed with zero balance, even if Initialize failed
+				tx_store.Store(dvm.GetBalanceKey(scid), dvm.Variable{Type: dvm.Uint64, Value: uint64(0)})
+			}
+			refund = value // value is not lost arbitrarily to the network
 		}
 
+		// used gas is burnt, rest is refunded to signer, whether execution succeeded or not
+		if gas_reserved > 0 {
+			gas_refund := gas_reserved - state.Gas_Used*config.SC_GAS_PRICE
+			trace.Log("entrypoint '%s' scid %s gas used %d refund %d", entrypoint, scid, state.Gas_Used, gas_refund)
+			refund += gas_refund
+		}
 	}
+	receipt.Success = receipt.Error == ""
+	receipt.Refund = refund
+
 	// store state changes
 	chain.store_changes(dbtx, crypto.Key(tx_hash), tx_store)
+	chain.store_sc_refund(dbtx, crypto.Key(tx_hash), addri.String(), refund)
 
 	// chain.Revert_SC(dbtx,crypto.Key(tx_hash),hard_fork_version_current)
+}
 
-	fmt.Printf("SC execution finished amount value %d\n", sc_tx.Value)
+// gas is paid upfront from the attached value, unused gas is refunded after execution
+// value must remain non zero after paying, since SC balance cannot be drained completely
+// gas limit is required, unmetered execution is only available to dry runs estimating gas
+// returns value received by SC
+func sc_reserve_gas(value, gas uint64) (uint64, error) {
+	if gas == 0 {
+		return 0, fmt.Errorf("gas limit required")
+	}
+	if gas > dvm.GAS_LIMIT_MAX || gas >= value/config.SC_GAS_PRICE {
+		return 0, fmt.Errorf("insufficient value %d for gas %d", value, gas)
+	}
+	return value - gas*config.SC_GAS_PRICE, nil
+}
 
-	fmt.Printf("SC processing finished %d\n", len(sc_tx.SC))
+// value attached to an SC tx which did not commit and unused gas are paid back to signer as a spendable output
+// refund is not paid from SC balance, since a balance cannot be drained to zero and there may be no SC at all
+// it is added to the stored changelog, so as output index picks it up like any SC external transfer
+func (chain *Blockchain) store_sc_refund(dbtx storage.DBTX, tx_hash crypto.Key, signer string, amount uint64) {
+	if amount == 0 {
+		return
+	}
+	changelog := chain.Load_SCChangelog(dbtx, tx_hash)
+	if len(changelog) == 0 { // outputs are read from first entry
+		changelog = append(changelog, TX_SC_storage{Refund: true})
+	}
+	changelog[0].TransferE = append(changelog[0].TransferE, dvm.TransferExternal{Address: signer, Amount: amount})
 
+	serialized_change_log, _ := msgpack.Marshal(changelog)
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, tx_hash[:], PLANET_TX_SC_CHANGELOG, serialized_change_log)
 }
 
 // this will revert the SC transaction changes to the DB
 func (chain *Blockchain) Revert_SC(dbtx storage.DBTX, tx_hash crypto.Key, hard_fork_version_current int64) {
 
+	chain.revert_sc_events(dbtx, tx_hash)
+	chain.revert_sc_trace(dbtx, tx_hash)
+	chain.revert_sc_receipt(dbtx, tx_hash)
+
 	changelog := chain.Load_SCChangelog(dbtx, tx_hash)
 	// if we are here everything is ok, lets write the values, in the reverse order
 	if len(changelog) == 0 {
@@ -266,9 +394,18 @@ func (chain *Blockchain) Revert_SC(dbtx storage.DBTX, tx_hash crypto.Key, hard_f
 	}
 	for i := len(changelog) - 1; i >= 0; i-- {
 		change := changelog[i]
-		fmt.Printf("Reverting todb %s %s %s\n", change.SCID, change.Key, change.Previous)
+		if change.Refund {
+			continue
+		}
+		if change.Code {
+			rlog.Tracef(1, "Reverting code todb %s", change.SCID)
+			chain.store_sc_code(dbtx, change.SCID, change.Previous)
+			continue
+		}
+		rlog.Tracef(1, "Reverting todb %s %s %x", change.SCID, change.Key, change.Previous)
 		chain.StoreSCValue(dbtx, change.SCID, change.Key, change.Previous)
 	}
+	chain.queue_sc_events(tx_hash, changelog, true)
 }
 
 func (chain *Blockchain) Load_SCChangelog(dbtx storage.DBTX, tx_hash crypto.Key) (changes []TX_SC_storage) {
@@ -292,6 +429,42 @@ func (chain *Blockchain) Load_SCChangelog(dbtx storage.DBTX, tx_hash crypto.Key)
 	return changes
 }
 
+// data recorded per SC tx besides the changelog ( events, traces, receipts ) is stored in its own planet of the tx
+// it is cleared when SC changes of the tx are reverted, and stored again if tx is executed again
+func (chain *Blockchain) store_tx_planet(dbtx storage.DBTX, tx_hash crypto.Key, planet []byte, v interface{}) {
+	serialized, err := msgpack.Marshal(v)
+	if err != nil {
+		logger.Warnf("%s of tx %s could not be serialized err %s", planet, tx_hash, err)
+		return
+	}
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, tx_hash[:], planet, serialized)
+}
+
+// there is no delete, so an empty value is stored
+func (chain *Blockchain) clear_tx_planet(dbtx storage.DBTX, tx_hash crypto.Key, planet []byte) {
+	if object_data, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, tx_hash[:], planet); err == nil && len(object_data) > 0 {
+		dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, tx_hash[:], planet, []byte{})
+	}
+}
+
+// returns false if nothing is stored or it could not be deserialized
+func (chain *Blockchain) load_tx_planet(dbtx storage.DBTX, tx_hash crypto.Key, planet []byte, v interface{}) bool {
+	var err error
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			return false
+		}
+		defer dbtx.Rollback()
+	}
+
+	object_data, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, tx_hash[:], planet)
+	if err != nil || len(object_data) == 0 {
+		return false
+	}
+	return msgpack.Unmarshal(object_data, v) == nil
+}
+
 // this will store the changes
 // TODO: FIXME this should be integrated with POW for guarantees
 func (chain *Blockchain) store_changes(dbtx storage.DBTX, tx_hash crypto.Key, changes *dvm.TX_Storage) {
@@ -332,9 +505,25 @@ func (chain *Blockchain) store_changes(dbtx storage.DBTX, tx_hash crypto.Key, ch
 		bulk_changes[0].TransferE = append(bulk_changes[0].TransferE, v.TransferE...)
 	}
 
+	// code replacements, in same fixed order
+	keyarray = keyarray[:0]
+	for k := range changes.Code {
+		keyarray = append(keyarray, k)
+	}
+	sort.Slice(keyarray, func(i, j int) bool { return bytes.Compare(keyarray[i][:], keyarray[j][:]) == -1 })
+	for _, k := range keyarray {
+		previous, _ := chain.ReadSC_Code(dbtx, k)
+		bulk_changes = append(bulk_changes, TX_SC_storage{SCID: k, Code: true, Previous: []byte(previous), Current: []byte(changes.Code[k])})
+	}
+
 	// if we are here everything is ok, lets write the values
 	for _, change := range bulk_changes {
-		fmt.Printf("storing todb %s %s %s\n", change.SCID, change.Key, change.Current)
+		if change.Code {
+			rlog.Tracef(1, "storing code todb %s", change.SCID)
+			chain.store_sc_code(dbtx, change.SCID, change.Current)
+			continue
+		}
+		rlog.Tracef(1, "storing todb %s %s %x", change.SCID, change.Key, change.Current)
 		chain.StoreSCValue(dbtx, change.SCID, change.Key, change.Current)
 	}
 
@@ -342,6 +531,10 @@ func (chain *Blockchain) store_changes(dbtx storage.DBTX, tx_hash crypto.Key, ch
 	serialized_change_log, _ := msgpack.Marshal(bulk_changes)
 	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, tx_hash[:], PLANET_TX_SC_CHANGELOG, serialized_change_log)
 
+	chain.store_sc_events(dbtx, tx_hash, changes.Events)
+
+	chain.queue_sc_events(tx_hash, bulk_changes, false)
+
 }
 
 // this will load the value from the chain
@@ -359,7 +552,6 @@ func (chain *Blockchain) LoadSCValue(dbtx storage.DBTX, scid crypto.Key, keyhash
 
 	}
 
-	fmt.Printf("loading fromdb %s %s \n", scid, keyhash)
 	object_data, err := dbtx.LoadObject(SMARTCONTRACT_UNIVERSE, SMARTCONTRACT_UNIVERSE, scid[:], keyhash[:])
 
 	if err != nil {
@@ -434,13 +626,220 @@ func (chain *Blockchain) ReadSCValue(dbtx storage.DBTX, scid crypto.Key, key int
 	}
 
 	value_var, _ := chain.LoadSCValue(dbtx, scid, keyhash)
-	fmt.Printf("read value %+v", value_var)
 	if value_var.Type != dvm.Invalid {
 		value = value_var.Value
 	}
 	return
 }
 
+// hash under which a generic SC key is stored
+func SC_Key_Hash(key dvm.Variable) crypto.Key {
+	return crypto.Key(crypto.Keccak256(dvm.Serialize_DataKey(dvm.DataKey{Key: key})))
+}
+
+// hash under which the SC balance is stored
+func SC_Balance_Hash(scid crypto.Key) crypto.Key {
+	return crypto.Key(crypto.Keccak256(dvm.Serialize_DataKey(dvm.GetBalanceKey(scid))))
+}
+
+// reads the SC source, as it was installed
+func (chain *Blockchain) ReadSC_Code(dbtx storage.DBTX, scid crypto.Key) (code string, found bool) {
+	var err error
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			logger.Warnf("Could NOT load SC code. Error opening TX, err %s", err)
+			return
+		}
+
+		defer dbtx.Rollback()
+	}
+
+	code_bytes, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, scid[:], PLANET_TX_SC_BYTES)
+	if err != nil || len(code_bytes) == 0 {
+		return
+	}
+	return string(code_bytes), true
+}
+
+// reads the SC source as it was at the end of topoheight, code replaced later is undone
+func (chain *Blockchain) ReadSC_Code_At(dbtx storage.DBTX, scid crypto.Key, topoheight int64) (code string, found bool) {
+	var err error
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			logger.Warnf("Could NOT load SC code. Error opening TX, err %s", err)
+			return
+		}
+
+		defer dbtx.Rollback()
+	}
+
+	if code, found = chain.ReadSC_Code(dbtx, scid); !found {
+		return
+	}
+	err = chain.undo_SC_changes(dbtx, topoheight, func(change TX_SC_storage) {
+		if change.SCID == scid && change.Code {
+			code = string(change.Previous)
+		}
+	})
+	return code, err == nil
+}
+
+// replaces SC code in both source and parsed form, storage and balance are not touched
+// code has already been parsed by DVM before it reaches here
+func (chain *Blockchain) store_sc_code(dbtx storage.DBTX, scid crypto.Key, code []byte) {
+	serialized, err := sc_processed(code)
+	if err != nil {
+		panic(fmt.Sprintf("SC %s code could not be parsed err %s", scid, err))
+	}
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, scid[:], PLANET_TX_SC_BYTES, code)
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, scid[:], PLANET_TX_SC_PROCESSED, serialized)
+}
+
+// parsed form of SC code, as stored under PLANET_TX_SC_PROCESSED
+func sc_processed(code []byte) ([]byte, error) {
+	sc_parsed, pos, err := dvm.ParseSmartContract(string(code))
+	if err != nil {
+		return nil, fmt.Errorf("pos %s err %s", pos, err)
+	}
+	return msgpack.Marshal(sc_parsed)
+}
+
+// whether the SC was installed at topoheight
+func (chain *Blockchain) Is_SC_Installed(dbtx storage.DBTX, scid crypto.Key, topoheight int64) bool {
+	if _, found := chain.ReadSC(dbtx, scid); !found {
+		return false
+	}
+	installed := chain.Load_TX_Height(dbtx, crypto.Hash(scid))
+	return installed >= 0 && installed <= topoheight
+}
+
+// historic SC state is rebuilt by undoing every later block, so it is only available this far below top
+const SC_STATE_MAX_REPLAY = 1000
+
+// whether SC state at topoheight can be rebuilt, error if it is too far below top
+func (chain *Blockchain) Check_SC_Replay(dbtx storage.DBTX, topoheight int64) error {
+	if chain.Load_TOPO_HEIGHT(dbtx)-topoheight > SC_STATE_MAX_REPLAY {
+		return fmt.Errorf("historic SC state is only available for the last %d topoheights", SC_STATE_MAX_REPLAY)
+	}
+	return nil
+}
+
+// calls undo for every SC change made by txs ordered after topoheight, newest change first
+// applying change.Previous in this order to current state gives state at topoheight
+func (chain *Blockchain) undo_SC_changes(dbtx storage.DBTX, topoheight int64, undo func(change TX_SC_storage)) error {
+	if err := chain.Check_SC_Replay(dbtx, topoheight); err != nil {
+		return err
+	}
+	for t := chain.Load_TOPO_HEIGHT(dbtx); t > topoheight; t-- {
+		blid, err := chain.Load_Block_Topological_order_at_index(dbtx, t)
+		if err != nil {
+			return err
+		}
+		bl, err := chain.Load_BL_FROM_ID(dbtx, blid)
+		if err != nil {
+			return err
+		}
+		if bl.Major_Version < 4 { // SCs are only processed from hard fork 4, older blocks have no changes
+			break
+		}
+
+		// txs are processed in order, so they are undone in reverse
+		for i := len(bl.Tx_hashes) - 1; i >= 0; i-- {
+			if !chain.IS_TX_Valid(dbtx, blid, bl.Tx_hashes[i]) {
+				continue
+			}
+			changelog := chain.Load_SCChangelog(dbtx, crypto.Key(bl.Tx_hashes[i]))
+			for j := len(changelog) - 1; j >= 0; j-- {
+				undo(changelog[j])
+			}
+		}
+	}
+	return nil
+}
+
+// loads SC values as they were at the end of topoheight, values which did not exist are Invalid
+// topoheight below 0 or above top topoheight gives current values
+func (chain *Blockchain) LoadSCValues_At(dbtx storage.DBTX, scid crypto.Key, keyhashes []crypto.Key, topoheight int64) (values []dvm.Variable, err error) {
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			return
+		}
+		defer dbtx.Rollback()
+	}
+
+	serialized := map[crypto.Key][]byte{}
+	for _, keyhash := range keyhashes {
+		serialized[keyhash], _ = dbtx.LoadObject(SMARTCONTRACT_UNIVERSE, SMARTCONTRACT_UNIVERSE, scid[:], keyhash[:])
+	}
+
+	if topoheight >= 0 {
+		err = chain.undo_SC_changes(dbtx, topoheight, func(change TX_SC_storage) {
+			if _, ok := serialized[change.Key]; ok && change.SCID == scid && !change.Code {
+				serialized[change.Key] = change.Previous
+			}
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	for _, keyhash := range keyhashes {
+		var v dvm.Variable
+		if value, ok := dvm.Deserialize_Variable(serialized[keyhash]).(dvm.Variable); ok {
+			v = value
+		}
+		values = append(values, v)
+	}
+	return
+}
+
+// reconstructs complete SC state at the end of topoheight, as keyhash => value
+// current state is read and changes made by later txs are undone using their changelogs
+// keys are only stored as hashes, callers can hash known keys using SC_Key_Hash to find them
+func (chain *Blockchain) Load_SC_State_At(dbtx storage.DBTX, scid crypto.Key, topoheight int64) (state map[crypto.Key]dvm.Variable, err error) {
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			return
+		}
+		defer dbtx.Rollback()
+	}
+
+	serialized := map[crypto.Key][]byte{}
+	err = dbtx.PrefixScan(SMARTCONTRACT_UNIVERSE, SMARTCONTRACT_UNIVERSE, scid[:], nil, false, func(k, v []byte) bool {
+		if len(k) == 32 {
+			var keyhash crypto.Key
+			copy(keyhash[:], k)
+			serialized[keyhash] = append([]byte{}, v...)
+		}
+		return true
+	})
+	if err != nil {
+		return
+	}
+
+	// keys created later are undone to empty values, keys deleted later reappear
+	err = chain.undo_SC_changes(dbtx, topoheight, func(change TX_SC_storage) {
+		if change.SCID == scid && !change.Code {
+			serialized[change.Key] = change.Previous
+		}
+	})
+	if err != nil {
+		return
+	}
+
+	state = map[crypto.Key]dvm.Variable{}
+	for keyhash, value := range serialized {
+		if v, ok := dvm.Deserialize_Variable(value).(dvm.Variable); ok {
+			state[keyhash] = v
+		}
+	}
+	return
+}
+
 // store the value in the chain
 func (chain *Blockchain) StoreSCValue(dbtx storage.DBTX, scid crypto.Key, keyhash crypto.Key, value []byte) {
 	dbtx.StoreObject(SMARTCONTRACT_UNIVERSE, SMARTCONTRACT_UNIVERSE, scid[:], keyhash[:], value[:])
@@ -455,6 +854,10 @@ type TX_SC_storage struct {
 	Current  []byte     `msgpack:"-"`           // current value // this need not be stored
 
 	TransferE []dvm.TransferExternal `msgpack:"T,omitempty"`
+
+	Code bool `msgpack:"C,omitempty"` // SC code was replaced, Previous and Current hold the source
+
+	Refund bool `msgpack:"R,omitempty"` // entry only carries refund in TransferE, no key was changed
 }
 
 // get public and ephermal key to pay to address
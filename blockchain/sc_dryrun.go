@@ -0,0 +1,168 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+// this file implements read only invocation of SC entrypoints against current chain state
+// nothing is persisted, so it can be used to find out what a tx would do, before sending it
+import "fmt"
+import "bytes"
+import "sort"
+
+import "github.com/deroproject/derosuite/address"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/dvm"
+
+// everything a SC call attempted to do
+type SC_DryRun_Result struct {
+	Result    dvm.Variable // value returned by entrypoint
+	Output    string       // PRINT output
+	Error     error        // execution error if any
+	Committed bool         // whether a real tx would have persisted the changes
+	Writes    []dvm.DataAtom
+	Transfers map[crypto.Key]dvm.SC_Transfers
+	Events    []dvm.SC_Event
+	Balances  map[crypto.Key]uint64 // balance after the transfers
+	Lines     int64                 // lines interpreted
+	Gas_Used  uint64                // gas consumed
+}
+
+// invokes an entrypoint of an installed SC at current topoheight, value is DERO attached
+// signer may be empty, if the SC does not depend on it
+// gas is the gas limit, reserved from value same as Process_SC, 0 runs with full value, so as callers can find out the gas required
+// dry runs are always metered, since anyone may ask for them, max_gas caps the gas limit, 0 allows dvm.GAS_LIMIT_MAX
+// err is only returned if the call could not be setup, execution errors are reported within result
+func (chain *Blockchain) DryRun_SC(scid crypto.Key, entrypoint string, sc_params map[string]string, value uint64, gas uint64, max_gas uint64, signer string) (result SC_DryRun_Result, err error) {
+	dbtx, err := chain.store.BeginTX(false)
+	if err != nil {
+		return
+	}
+	defer dbtx.Rollback()
+
+	defer func() { // balance handling panics if SC state is corrupted
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Recovered while running SC %v", r)
+		}
+	}()
+
+	sc_parsed, found := chain.ReadSC(dbtx, scid)
+	if !found {
+		return result, fmt.Errorf("SC %s not found", scid)
+	}
+	if entrypoint == "Initialize" { // initialize cannot be triggerred again
+		return result, fmt.Errorf("Initialize cannot be invoked")
+	}
+	function, ok := sc_parsed.Functions[entrypoint]
+	if !ok {
+		return result, fmt.Errorf("SC does not contain entrypoint '%s'", entrypoint)
+	}
+
+	if max_gas == 0 || max_gas > dvm.GAS_LIMIT_MAX {
+		max_gas = dvm.GAS_LIMIT_MAX
+	}
+	if gas > max_gas {
+		return result, fmt.Errorf("gas %d is above dry run maximum %d", gas, max_gas)
+	}
+	gas_limit := max_gas
+	if gas > 0 {
+		if value, err = sc_reserve_gas(value, gas); err != nil {
+			return
+		}
+		gas_limit = gas
+	}
+
+	var signer_address address.Address
+	if signer != "" {
+		addr, err := address.NewAddress(signer)
+		if err != nil {
+			return result, fmt.Errorf("signer address could not be parsed err %s", err)
+		}
+		signer_address = *addr
+	}
+
+	// params are handled same as Process_SC
+	params := map[string]interface{}{}
+	for _, p := range function.Params {
+		if p.Name == "value" {
+			params[p.Name] = fmt.Sprintf("%d", value)
+		} else if param_value, ok := sc_params[p.Name]; ok {
+			params[p.Name] = param_value
+		} else {
+			return result, fmt.Errorf("entrypoint '%s' parameter missing '%s'", entrypoint, p.Name)
+		}
+	}
+
+	topoheight := chain.Load_TOPO_HEIGHT(dbtx)
+	blid, err := chain.Load_Block_Topological_order_at_index(dbtx, topoheight)
+	if err != nil {
+		return
+	}
+
+	tx_store := dvm.Initialize_TX_store()
+	tx_store.DiskLoader = func(key dvm.DataKey, found *uint64) (result dvm.Variable) {
+		var exists bool
+		keyhash := crypto.Key(crypto.Keccak256(dvm.Serialize_DataKey(key)))
+		if result, exists = chain.LoadSCValue(dbtx, key.SCID, keyhash); exists {
+			*found = uint64(1)
+		}
+		return
+	}
+
+	tx_store.SCLoader = func(scid crypto.Key) (dvm.SmartContract, bool) {
+		return chain.ReadSC(dbtx, scid)
+	}
+
+	tx_store.Balance(scid)
+	tx_store.ReceiveInternal(scid, value)
+
+	var output bytes.Buffer
+	state := &dvm.Shared_State{
+		Persistance:   false,
+		DERO_Received: value,
+		Gas_Limit:     gas_limit,
+		Store:         tx_store,
+		Output:        &output,
+		Chain_inputs: &dvm.Blockchain_Input{
+			BL_HEIGHT:     uint64(chain.Load_Height_for_BL_ID(dbtx, blid)),
+			BL_TOPOHEIGHT: uint64(topoheight),
+			SCID:          scid,
+			BLID:          crypto.Key(blid),
+			Signer:        signer_address},
+	}
+
+	result.Result, result.Error = dvm.RunSmartContract(&sc_parsed, entrypoint, state, params)
+	result.Committed = result.Error == nil && result.Result.Type == dvm.Uint64 && result.Result.Value.(uint64) == 0
+	result.Output = output.String()
+	result.Writes = tx_store.Atoms
+	result.Transfers = tx_store.Transfers
+	result.Events = tx_store.Events
+	result.Balances = map[crypto.Key]uint64{}
+	for scid := range tx_store.Transfers {
+		result.Balances[scid] = tx_store.Balance(scid)
+	}
+	result.Lines = state.Monitor_lines_interpreted
+	result.Gas_Used = state.Gas_Used
+	return
+}
+
+// SCIDs with transfers in fixed order
+func (r *SC_DryRun_Result) Transfer_SCIDs() (scids []crypto.Key) {
+	for scid := range r.Transfers {
+		scids = append(scids, scid)
+	}
+	sort.Slice(scids, func(i, j int) bool { return bytes.Compare(scids[i][:], scids[j][:]) == -1 })
+	return
+}
@@ -0,0 +1,105 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "strings"
+import "testing"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/config"
+import "github.com/deroproject/derosuite/crypto"
+
+const dryrun_test_sc = `Function Initialize() Uint64
+10 RETURN 0
+End Function
+
+Function Deposit(value Uint64, name String) Uint64
+10 STORE(name, value)
+20 PRINT "deposit %d" value
+30 SEND_DERO_TO_ADDRESS(SIGNER(), value / 2)
+40 RETURN 0
+End Function
+`
+
+func Test_SC_DryRun(t *testing.T) {
+	chain := new_test_chain(t)
+	scid := install_test_sc(t, chain, dryrun_test_sc)
+
+	signer := "dETocsF4EuzXaxLNbDLLWi6xNEzzBJ2He5WSf7He8peuPt4nTyakAFyNuXqrHAGQt1PBSBonCRRj8daUtF7TPXFW42YQkxUQzg"
+	result, err := chain.DryRun_SC(scid, "Deposit", map[string]string{"name": "alice"}, 1000, 0, 0, signer)
+	if err != nil {
+		t.Fatalf("dry run could not be setup err %s", err)
+	}
+	if result.Error != nil || !result.Committed {
+		t.Fatalf("dry run failed err %v result %+v", result.Error, result.Result)
+	}
+	if !strings.Contains(result.Output, "deposit 1000") {
+		t.Fatalf("PRINT output not captured '%s'", result.Output)
+	}
+	if len(result.Writes) != 1 || result.Writes[0].Key.Key.Value != "alice" || result.Writes[0].Value.Value != uint64(1000) {
+		t.Fatalf("unexpected writes %+v", result.Writes)
+	}
+	if len(result.Transfers[scid].TransferE) != 1 || result.Transfers[scid].TransferE[0].Amount != 500 || result.Balances[scid] != 500 {
+		t.Fatalf("unexpected transfers %+v", result.Transfers)
+	}
+
+	if result.Gas_Used == 0 {
+		t.Fatalf("gas used must be reported")
+	}
+
+	// gas is reserved from value, same as a real tx
+	gas := result.Gas_Used
+	result, err = chain.DryRun_SC(scid, "Deposit", map[string]string{"name": "alice"}, (gas+1)*config.SC_GAS_PRICE, gas, 0, signer)
+	if err != nil || !result.Committed || result.Writes[0].Value.Value != config.SC_GAS_PRICE {
+		t.Fatalf("metered dry run must receive value without gas, err %v result %+v", err, result)
+	}
+
+	// limit lower than required gas must fail distinctly
+	result, err = chain.DryRun_SC(scid, "Deposit", map[string]string{"name": "alice"}, (gas+1)*config.SC_GAS_PRICE, gas-1, 0, signer)
+	if err != nil || result.Error != dvm.ErrOutOfGas || result.Committed {
+		t.Fatalf("expected out of gas, err %v result err %v", err, result.Error)
+	}
+
+	if _, err = chain.DryRun_SC(scid, "Deposit", map[string]string{"name": "alice"}, 1000, gas, 0, signer); err == nil {
+		t.Fatalf("value not covering gas must be rejected")
+	}
+
+	// dry runs without gas limit are still limited by max gas
+	result, err = chain.DryRun_SC(scid, "Deposit", map[string]string{"name": "alice"}, 1000, 0, gas-1, signer)
+	if err != nil || result.Error != dvm.ErrOutOfGas {
+		t.Fatalf("expected out of gas with max gas, err %v result err %v", err, result.Error)
+	}
+	if _, err = chain.DryRun_SC(scid, "Deposit", map[string]string{"name": "alice"}, (gas+1)*config.SC_GAS_PRICE, gas, gas-1, signer); err == nil {
+		t.Fatalf("gas limit above max gas must be rejected")
+	}
+
+	// nothing must have been persisted
+	keyhash := crypto.Key(crypto.Keccak256(dvm.Serialize_DataKey(dvm.DataKey{Key: dvm.Variable{Type: dvm.String, Value: "alice"}})))
+	if _, found := chain.LoadSCValue(nil, scid, keyhash); found {
+		t.Fatalf("dry run persisted SC state")
+	}
+
+	if _, err = chain.DryRun_SC(scid, "Initialize", nil, 0, 0, 0, ""); err == nil {
+		t.Fatalf("Initialize must not be invokable")
+	}
+	if _, err = chain.DryRun_SC(scid, "Deposit", nil, 0, 0, 0, ""); err == nil {
+		t.Fatalf("missing params must be reported")
+	}
+	if _, err = chain.DryRun_SC(crypto.Key{1}, "Deposit", nil, 0, 0, 0, ""); err == nil {
+		t.Fatalf("unknown SC must be reported")
+	}
+}
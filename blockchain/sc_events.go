@@ -0,0 +1,126 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+// this file persists events emitted by SCs using EMIT, they are stored per tx alongside the SC changelog
+// events are queried by walking blocks in a topoheight range, so no index is required
+import "fmt"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/storage"
+
+const SC_EVENTS_MAX_RANGE = 1000 // topoheights walked by a single query
+
+// args are stored serialized, since msgpack does not preserve types within interface
+type TX_SC_event struct {
+	SCID crypto.Key `msgpack:"S,omitempty"`
+	Name string     `msgpack:"N,omitempty"`
+	Args [][]byte   `msgpack:"A,omitempty"`
+}
+
+// an event together with where it was emitted
+type SC_Event_Entry struct {
+	TXID       crypto.Hash
+	TopoHeight int64
+	Event      dvm.SC_Event
+}
+
+func (chain *Blockchain) store_sc_events(dbtx storage.DBTX, tx_hash crypto.Key, events []dvm.SC_Event) {
+	if len(events) == 0 {
+		return
+	}
+	var stored []TX_SC_event
+	for _, event := range events {
+		e := TX_SC_event{SCID: event.SCID, Name: event.Name}
+		for _, arg := range event.Args {
+			e.Args = append(e.Args, dvm.Serialize_Variable(arg))
+		}
+		stored = append(stored, e)
+	}
+	chain.store_tx_planet(dbtx, tx_hash, PLANET_TX_SC_EVENTS, stored)
+}
+
+func (chain *Blockchain) revert_sc_events(dbtx storage.DBTX, tx_hash crypto.Key) {
+	chain.clear_tx_planet(dbtx, tx_hash, PLANET_TX_SC_EVENTS)
+}
+
+// events emitted by a tx, in order of emission
+func (chain *Blockchain) Load_SCEvents(dbtx storage.DBTX, tx_hash crypto.Key) (events []dvm.SC_Event) {
+	var stored []TX_SC_event
+	if !chain.load_tx_planet(dbtx, tx_hash, PLANET_TX_SC_EVENTS, &stored) {
+		return
+	}
+	for _, e := range stored {
+		event := dvm.SC_Event{SCID: e.SCID, Name: e.Name}
+		for _, arg := range e.Args {
+			if v, ok := dvm.Deserialize_Variable(arg).(dvm.Variable); ok {
+				event.Args = append(event.Args, v)
+			}
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+// events emitted by SC within topoheight range [start, end], in chain order
+// empty name returns events of all names
+func (chain *Blockchain) Load_SC_Events_Range(dbtx storage.DBTX, scid crypto.Key, name string, start, end int64) (entries []SC_Event_Entry, err error) {
+	if dbtx == nil {
+		dbtx, err = chain.store.BeginTX(false)
+		if err != nil {
+			return
+		}
+		defer dbtx.Rollback()
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	if end-start+1 > SC_EVENTS_MAX_RANGE {
+		return nil, fmt.Errorf("topoheight range cannot exceed %d", SC_EVENTS_MAX_RANGE)
+	}
+	if top := chain.Load_TOPO_HEIGHT(dbtx); end > top {
+		end = top
+	}
+
+	for t := start; t <= end; t++ {
+		blid, err := chain.Load_Block_Topological_order_at_index(dbtx, t)
+		if err != nil {
+			return nil, err
+		}
+		bl, err := chain.Load_BL_FROM_ID(dbtx, blid)
+		if err != nil {
+			return nil, err
+		}
+		if bl.Major_Version < 4 { // SCs are only processed from hard fork 4
+			continue
+		}
+
+		for _, txid := range bl.Tx_hashes {
+			if !chain.IS_TX_Valid(dbtx, blid, txid) {
+				continue
+			}
+			for _, event := range chain.Load_SCEvents(dbtx, crypto.Key(txid)) {
+				if event.SCID == scid && (name == "" || event.Name == name) {
+					entries = append(entries, SC_Event_Entry{TXID: txid, TopoHeight: t, Event: event})
+				}
+			}
+		}
+	}
+	return
+}
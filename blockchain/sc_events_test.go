@@ -0,0 +1,72 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "testing"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+
+const events_test_sc = `Function Initialize() Uint64
+10 RETURN 0
+End Function
+
+Function Deposit(value Uint64, name String) Uint64
+10 EMIT("Deposit", name, value)
+20 RETURN 0
+End Function
+`
+
+func Test_SC_Events(t *testing.T) {
+	chain := new_test_chain(t)
+	scid := install_test_sc(t, chain, events_test_sc)
+
+	result, err := chain.DryRun_SC(scid, "Deposit", map[string]string{"name": "alice"}, 1000, 0, 0, "")
+	if err != nil || !result.Committed {
+		t.Fatalf("dry run failed err %v result err %v", err, result.Error)
+	}
+	if len(result.Events) != 1 || result.Events[0].SCID != scid || result.Events[0].Name != "Deposit" || len(result.Events[0].Args) != 2 {
+		t.Fatalf("unexpected dry run events %+v", result.Events)
+	}
+
+	// events are stored with the changelog and cleared when tx is reverted
+	tx_hash := crypto.Key{7}
+	tx_store := dvm.Initialize_TX_store()
+	tx_store.Events = result.Events
+
+	dbtx := write_test_tx(t, chain)
+
+	chain.store_changes(dbtx, tx_hash, tx_store)
+	events := chain.Load_SCEvents(dbtx, tx_hash)
+	if len(events) != 1 || events[0].Name != "Deposit" ||
+		events[0].Args[0] != (dvm.Variable{Type: dvm.String, Value: "alice"}) || events[0].Args[1] != (dvm.Variable{Type: dvm.Uint64, Value: uint64(1000)}) {
+		t.Fatalf("stored events mismatch %+v", events)
+	}
+
+	chain.Revert_SC(dbtx, tx_hash, 4)
+	if events = chain.Load_SCEvents(dbtx, tx_hash); len(events) != 0 {
+		t.Fatalf("events not reverted %+v", events)
+	}
+
+	if _, err = chain.Load_SC_Events_Range(dbtx, scid, "", 0, SC_EVENTS_MAX_RANGE); err == nil {
+		t.Fatalf("range above limit must fail")
+	}
+	entries, err := chain.Load_SC_Events_Range(dbtx, scid, "", 0, chain.Load_TOPO_HEIGHT(dbtx))
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("unexpected events err %v entries %+v", err, entries)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+// this file persists a receipt for every processed SC tx, so as wallets can show what happened to the DERO sent
+// receipt is stored whether execution succeeded or failed, including failures before execution
+import "fmt"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/storage"
+
+type SC_Receipt struct {
+	SCID       crypto.Key `msgpack:"S,omitempty"` // SC invoked, tx itself for installation
+	EntryPoint string     `msgpack:"E,omitempty"`
+	Value      uint64     `msgpack:"V,omitempty"`  // DERO attached to the tx
+	Refund     uint64     `msgpack:"RF,omitempty"` // DERO paid back to signer, attached value if tx failed and unused gas
+	Success    bool       `msgpack:"OK,omitempty"`
+	Error      string     `msgpack:"ER,omitempty"` // first failure, empty on success
+	Return     []byte     `msgpack:"R,omitempty"`  // serialized value returned by entrypoint, empty if it did not execute
+	Gas_Used   uint64     `msgpack:"G,omitempty"`
+	Lines      int64      `msgpack:"L,omitempty"` // lines interpreted
+}
+
+// first failure is kept since later ones are mostly consequences
+func (r *SC_Receipt) fail(format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.Success = false
+	if r.Error == "" {
+		r.Error = fmt.Sprintf(format, args...)
+	}
+}
+
+// value returned by entrypoint, Invalid if it did not execute
+func (r *SC_Receipt) Returned() (v dvm.Variable) {
+	if value, ok := dvm.Deserialize_Variable(r.Return).(dvm.Variable); ok {
+		v = value
+	}
+	return
+}
+
+func (chain *Blockchain) store_sc_receipt(dbtx storage.DBTX, tx_hash crypto.Key, receipt *SC_Receipt) {
+	chain.store_tx_planet(dbtx, tx_hash, PLANET_TX_SC_RECEIPT, receipt)
+}
+
+func (chain *Blockchain) revert_sc_receipt(dbtx storage.DBTX, tx_hash crypto.Key) {
+	chain.clear_tx_planet(dbtx, tx_hash, PLANET_TX_SC_RECEIPT)
+}
+
+// receipt of the tx execution in the block, in which it is currently valid
+func (chain *Blockchain) Load_SCReceipt(dbtx storage.DBTX, tx_hash crypto.Key) (receipt SC_Receipt, found bool) {
+	found = chain.load_tx_planet(dbtx, tx_hash, PLANET_TX_SC_RECEIPT, &receipt)
+	return
+}
@@ -0,0 +1,139 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "strings"
+import "testing"
+
+import "github.com/vmihailenco/msgpack"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/config"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/transaction"
+
+func Test_SC_Receipt(t *testing.T) {
+	chain := new_test_chain(t)
+
+	tx_hash := crypto.Key{7}
+	receipt := &SC_Receipt{SCID: crypto.Key{1}, EntryPoint: "Withdraw", Value: 100, Gas_Used: 1200, Lines: 5,
+		Return: dvm.Serialize_Variable(dvm.Variable{Type: dvm.Uint64, Value: uint64(1)})}
+	receipt.fail("entrypoint returned %d", 1)
+	receipt.fail("later failure")
+	receipt.Success = receipt.Error == ""
+
+	dbtx := write_test_tx(t, chain)
+
+	if _, found := chain.Load_SCReceipt(dbtx, tx_hash); found {
+		t.Fatalf("receipt must not exist before storing")
+	}
+
+	chain.store_sc_receipt(dbtx, tx_hash, receipt)
+	stored, found := chain.Load_SCReceipt(dbtx, tx_hash)
+	if !found || stored.Success || stored.Error != "entrypoint returned 1" || stored.EntryPoint != "Withdraw" || stored.Value != 100 || stored.Gas_Used != 1200 || stored.Lines != 5 {
+		t.Fatalf("stored receipt mismatch %+v", stored)
+	}
+	if returned := stored.Returned(); returned.Type != dvm.Uint64 || returned.Value.(uint64) != 1 {
+		t.Fatalf("returned value mismatch %+v", returned)
+	}
+
+	chain.Revert_SC(dbtx, tx_hash, 4)
+	if _, found = chain.Load_SCReceipt(dbtx, tx_hash); found {
+		t.Fatalf("receipt not reverted")
+	}
+
+	// receipt of a tx which was not executed has no return value
+	if returned := (&SC_Receipt{}).Returned(); returned.Type != dvm.Invalid {
+		t.Fatalf("empty receipt must return Invalid %+v", returned)
+	}
+}
+
+const receipt_test_sc = `Function Initialize() Uint64
+10 RETURN 0
+End Function
+
+Function Check(amount Uint64) Uint64
+10 IF amount > 5 THEN GOTO 30
+20 RETURN 0
+30 RETURN amount
+End Function
+`
+
+func Test_SC_Receipt_Process(t *testing.T) {
+	chain := new_test_chain(t)
+	scid := install_test_sc(t, chain, receipt_test_sc)
+	dbtx := write_test_tx(t, chain)
+
+	value := 2000 * config.SC_GAS_PRICE
+	process := func(scid crypto.Key, amount string) SC_Receipt {
+		data := sc_test_data(t, transaction.SC_Transaction{SCID: scid, EntryPoint: "Check", Params: map[string]string{"amount": amount}, Gas: 1000})
+		tx, _ := sc_test_tx(t, data, value)
+		process_test_sc_tx(t, chain, dbtx, tx, 4)
+		receipt, found := chain.Load_SCReceipt(dbtx, crypto.Key(tx.GetHash()))
+		if !found {
+			t.Fatalf("receipt not stored for amount %s", amount)
+		}
+		return receipt
+	}
+
+	receipt := process(scid, "1")
+	if !receipt.Success || receipt.Error != "" || receipt.SCID != scid || receipt.EntryPoint != "Check" || receipt.Value != value ||
+		receipt.Gas_Used == 0 || receipt.Lines != 2 || receipt.Returned().Value != uint64(0) {
+		t.Fatalf("successful receipt mismatch %+v", receipt)
+	}
+
+	// non zero return discards changes
+	receipt = process(scid, "7")
+	if receipt.Success || !strings.Contains(receipt.Error, "returned 7") || receipt.Returned().Value != uint64(7) || receipt.Refund == 0 {
+		t.Fatalf("failed receipt mismatch %+v", receipt)
+	}
+
+	// SC without balance panics outside DVM, receipt is still stored by recover
+	sc_parsed, _, _ := dvm.ParseSmartContract(receipt_test_sc)
+	serialized, _ := msgpack.Marshal(sc_parsed)
+	broken := crypto.Key{5}
+	dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, broken[:], PLANET_TX_SC_PROCESSED, serialized)
+	receipt = process(broken, "1")
+	if receipt.Success || !strings.HasPrefix(receipt.Error, "recovered while processing SC tx") || receipt.SCID != broken {
+		t.Fatalf("recovered receipt mismatch %+v", receipt)
+	}
+}
+
+// install failing before Initialize runs keeps SC installed with zero balance, so as it can still be called
+func Test_SC_Receipt_Install_Missing_Param(t *testing.T) {
+	chain := new_test_chain(t)
+	dbtx := write_test_tx(t, chain)
+
+	value := 2000 * config.SC_GAS_PRICE
+	code := "Function Initialize(name String) Uint64\n10 RETURN 0\nEnd Function\n\nFunction Check() Uint64\n10 RETURN 0\nEnd Function\n"
+	tx, _ := sc_test_tx(t, sc_test_data(t, transaction.SC_Transaction{SC: code, Gas: 1000}), value)
+	process_test_sc_tx(t, chain, dbtx, tx, 4)
+	scid := crypto.Key(tx.GetHash())
+
+	if receipt, _ := chain.Load_SCReceipt(dbtx, scid); receipt.Success || !strings.Contains(receipt.Error, "parameter missing") || receipt.Refund != value {
+		t.Fatalf("install receipt mismatch %+v", receipt)
+	}
+	if balance, found := chain.LoadSCValue(dbtx, scid, SC_Balance_Hash(scid)); !found || balance.Value.(uint64) != 0 {
+		t.Fatalf("SC must be installed with zero balance %+v", balance)
+	}
+
+	tx, _ = sc_test_tx(t, sc_test_data(t, transaction.SC_Transaction{SCID: scid, EntryPoint: "Check", Gas: 1000}), value)
+	process_test_sc_tx(t, chain, dbtx, tx, 4)
+	if receipt, _ := chain.Load_SCReceipt(dbtx, crypto.Key(tx.GetHash())); !receipt.Success {
+		t.Fatalf("SC must be callable %+v", receipt)
+	}
+}
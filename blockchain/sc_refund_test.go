@@ -0,0 +1,236 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "testing"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/config"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/transaction"
+
+func Test_SC_Refund(t *testing.T) {
+	chain := new_test_chain(t)
+	scid := install_test_sc(t, chain, "Function Initialize() Uint64\n10 RETURN 0\nEnd Function\n\n")
+	signer := "dETocsF4EuzXaxLNbDLLWi6xNEzzBJ2He5WSf7He8peuPt4nTyakAFyNuXqrHAGQt1PBSBonCRRj8daUtF7TPXFW42YQkxUQzg"
+
+	dbtx := write_test_tx(t, chain)
+
+	// tx which failed before execution has an empty changelog, refund gets an entry of its own
+	tx_hash := crypto.Key{3}
+	chain.store_changes(dbtx, tx_hash, dvm.Initialize_TX_store())
+	chain.store_sc_refund(dbtx, tx_hash, signer, 0)
+	if changelog := chain.Load_SCChangelog(dbtx, tx_hash); len(changelog) != 0 {
+		t.Fatalf("nothing must be refunded without value %+v", changelog)
+	}
+	chain.store_sc_refund(dbtx, tx_hash, signer, 700)
+	changelog := chain.Load_SCChangelog(dbtx, tx_hash)
+	if len(changelog) != 1 || !changelog[0].Refund || len(changelog[0].TransferE) != 1 || changelog[0].TransferE[0].Address != signer || changelog[0].TransferE[0].Amount != 700 {
+		t.Fatalf("refund output missing from changelog %+v", changelog)
+	}
+	chain.Revert_SC(dbtx, tx_hash, 4) // must not touch any key
+
+	// refund is appended to outputs of SC transfers
+	tx_hash = crypto.Key{4}
+	tx_store := dvm.Initialize_TX_store()
+	tx_store.DiskLoader = func(key dvm.DataKey, found *uint64) (result dvm.Variable) {
+		var exists bool
+		if result, exists = chain.LoadSCValue(dbtx, key.SCID, crypto.Key(crypto.Keccak256(dvm.Serialize_DataKey(key)))); exists {
+			*found = 1
+		}
+		return
+	}
+	tx_store.ReceiveInternal(scid, 500)
+	tx_store.SendExternal(scid, signer, 200)
+	chain.store_changes(dbtx, tx_hash, tx_store)
+	chain.store_sc_refund(dbtx, tx_hash, signer, 300)
+
+	changelog = chain.Load_SCChangelog(dbtx, tx_hash)
+	if len(changelog) != 1 || changelog[0].Refund || len(changelog[0].TransferE) != 2 || changelog[0].TransferE[0].Amount != 200 || changelog[0].TransferE[1].Amount != 300 {
+		t.Fatalf("refund output missing from changelog %+v", changelog)
+	}
+	if balance, found := chain.LoadSCValue(dbtx, scid, SC_Balance_Hash(scid)); !found || balance.Value.(uint64) != 300 {
+		t.Fatalf("SC balance must not pay the refund %+v", balance)
+	}
+
+	chain.Revert_SC(dbtx, tx_hash, 4)
+	if balance, found := chain.LoadSCValue(dbtx, scid, SC_Balance_Hash(scid)); !found || balance.Value.(uint64) != 0 {
+		t.Fatalf("SC balance not reverted %+v", balance)
+	}
+}
+
+const refund_test_sc = `Function Initialize() Uint64
+10 RETURN 0
+End Function
+
+Function Named(name String) Uint64
+10 STORE(name, 1)
+20 RETURN 0
+End Function
+
+Function Fail() Uint64
+10 RETURN LOAD("missing")
+End Function
+
+Function Loop() Uint64
+10 GOTO 10
+End Function
+`
+
+// every SC tx which does not commit pays attached value back to signer through output index
+func Test_SC_Refund_Process(t *testing.T) {
+	chain := new_test_chain(t)
+	scid := install_test_sc(t, chain, refund_test_sc)
+	dbtx := write_test_tx(t, chain)
+
+	value := 2000 * config.SC_GAS_PRICE
+	call := func(entrypoint string, params map[string]string) []byte {
+		return sc_test_data(t, transaction.SC_Transaction{SCID: scid, EntryPoint: entrypoint, Params: params, Gas: 1000})
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		gas_all bool // refund is value minus gas of whole limit
+		gas     bool // refund is value minus gas used
+	}{
+		{name: "bad msgpack", data: []byte("not msgpack data")},
+		{name: "missing SC", data: sc_test_data(t, transaction.SC_Transaction{SCID: crypto.Key{9}, EntryPoint: "Named", Gas: 1000})},
+		{name: "missing entrypoint", data: call("Missing", nil)},
+		{name: "missing param", data: call("Named", nil)},
+		{name: "DVM error", data: call("Fail", nil), gas: true},
+		{name: "insufficient gas", data: call("Loop", nil), gas_all: true},
+	}
+
+	for _, test := range tests {
+		tx, signer := sc_test_tx(t, test.data, value)
+		sc_outputs := process_test_sc_tx(t, chain, dbtx, tx, 4)
+		receipt, found := chain.Load_SCReceipt(dbtx, crypto.Key(tx.GetHash()))
+
+		refund := value
+		if test.gas || test.gas_all {
+			refund = value - receipt.Gas_Used*config.SC_GAS_PRICE
+		}
+		if test.gas_all && receipt.Gas_Used != 1000 {
+			t.Fatalf("%s: whole gas limit must be used %+v", test.name, receipt)
+		}
+		if test.gas && receipt.Gas_Used == 0 {
+			t.Fatalf("%s: gas used must be reported %+v", test.name, receipt)
+		}
+		if !found || receipt.Success || receipt.Error == "" || receipt.Value != value || receipt.Refund != refund {
+			t.Fatalf("%s: receipt mismatch expected refund %d %+v", test.name, refund, receipt)
+		}
+
+		output, ok := chain.load_output_index(dbtx, sc_outputs)
+		_, destination := GetEphermalKey(crypto.Key(tx.GetHash()), 0, signer.String())
+		if !ok || output.TXID != tx.GetHash() || output.Amount != refund || output.InKey.Destination != destination {
+			t.Fatalf("%s: refund output mismatch expected %d %+v", test.name, refund, output)
+		}
+		if outputs := sc_test_outputs(t, chain, dbtx, tx, sc_outputs); len(outputs) != 1 {
+			t.Fatalf("%s: only refund must be paid %+v", test.name, outputs)
+		}
+	}
+
+	if balance, _ := chain.LoadSCValue(dbtx, scid, SC_Balance_Hash(scid)); balance.Value.(uint64) != 0 {
+		t.Fatalf("SC must not keep value of failed txs %+v", balance)
+	}
+}
+
+// SC installed without Initialize commits without executing anything, so it keeps the whole attached value
+func Test_SC_Refund_No_Initialize(t *testing.T) {
+	chain := new_test_chain(t)
+	dbtx := write_test_tx(t, chain)
+
+	value := 2000 * config.SC_GAS_PRICE
+	install := transaction.SC_Transaction{SC: "Function Deposit() Uint64\n10 RETURN 0\nEnd Function\n", Gas: 1000}
+	tx, _ := sc_test_tx(t, sc_test_data(t, install), value)
+	sc_outputs := process_test_sc_tx(t, chain, dbtx, tx, 4)
+	scid := crypto.Key(tx.GetHash())
+
+	if receipt, found := chain.Load_SCReceipt(dbtx, scid); !found || !receipt.Success || receipt.Refund != 0 || receipt.Gas_Used != 0 {
+		t.Fatalf("install without Initialize must succeed without refund %+v", receipt)
+	}
+	if balance, found := chain.LoadSCValue(dbtx, scid, SC_Balance_Hash(scid)); !found || balance.Value.(uint64) != value {
+		t.Fatalf("SC must receive attached value %+v", balance)
+	}
+	if outputs := sc_test_outputs(t, chain, dbtx, tx, sc_outputs); len(outputs) != 0 {
+		t.Fatalf("nothing must be paid %+v", outputs)
+	}
+}
+
+// refunds are paid by blocks added through Add_Complete_Block, as outputs the signing wallet can find
+func Test_SC_Refund_Mined(t *testing.T) {
+	chain := new_sc_test_chain(t)
+	w := new_test_wallet(t)
+	mine_blocks_to(t, chain, w.GetAddress(), int(config.MINER_TX_AMOUNT_UNLOCK)+5)
+
+	value := 2000 * config.SC_GAS_PRICE
+	install := wallet_test_sc_tx(t, chain, w, transaction.SC_Transaction{SC: refund_test_sc, Gas: 1000}, value)
+	mine_test_tx(t, chain, w, install)
+	scid := crypto.Key(install.GetHash())
+	if !chain.Is_SC_Installed(nil, scid, chain.Load_TOPO_HEIGHT(nil)) {
+		t.Fatalf("SC not installed")
+	}
+
+	// whether the chain has an output of tx paying amount to the wallet
+	paid := func(tx *transaction.Transaction, amount uint64) bool {
+		dbtx, err := chain.store.BeginTX(false)
+		if err != nil {
+			t.Fatalf("cannot begin TX err %s", err)
+		}
+		defer dbtx.Rollback()
+		for index := uint64(0); ; index++ {
+			output, ok := chain.load_output_index(dbtx, index)
+			if !ok {
+				return false
+			}
+			if output.TXID == tx.GetHash() && output.Amount == amount && w.Is_Output_Ours(output.Tx_Public_Key, output.Index_within_tx, output.InKey.Destination) {
+				return true
+			}
+		}
+	}
+
+	tests := []struct {
+		name  string
+		sc_tx transaction.SC_Transaction
+		gas   bool // refund is value minus gas used
+	}{
+		{name: "missing SC", sc_tx: transaction.SC_Transaction{SCID: crypto.Key{9}, EntryPoint: "Named", Gas: 1000}},
+		{name: "DVM error", sc_tx: transaction.SC_Transaction{SCID: scid, EntryPoint: "Fail", Gas: 1000}, gas: true},
+	}
+
+	for _, test := range tests {
+		tx := wallet_test_sc_tx(t, chain, w, test.sc_tx, value)
+		mine_test_tx(t, chain, w, tx)
+
+		receipt, found := chain.Load_SCReceipt(nil, crypto.Key(tx.GetHash()))
+		refund := value
+		if test.gas {
+			refund = value - receipt.Gas_Used*config.SC_GAS_PRICE
+		}
+		if !found || receipt.Success || receipt.Refund != refund || (test.gas && receipt.Gas_Used == 0) {
+			t.Fatalf("%s: receipt mismatch expected refund %d %+v", test.name, refund, receipt)
+		}
+		if !paid(tx, refund) {
+			t.Fatalf("%s: refund %d not paid to signer", test.name, refund)
+		}
+	}
+
+	if balance, _ := chain.LoadSCValue(nil, scid, SC_Balance_Hash(scid)); balance.Value.(uint64) != value-1000*config.SC_GAS_PRICE {
+		t.Fatalf("SC must only keep value of install minus its gas %+v", balance)
+	}
+}
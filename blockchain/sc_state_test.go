@@ -0,0 +1,102 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "testing"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+
+func Test_SC_State(t *testing.T) {
+	chain := new_test_chain(t)
+	src := dryrun_test_sc
+	scid := install_test_sc(t, chain, src)
+	installed := chain.Load_TOPO_HEIGHT(nil)
+	mine_blocks(t, chain, 1)
+
+	if chain.Is_SC_Installed(nil, scid, installed-1) || !chain.Is_SC_Installed(nil, scid, installed) {
+		t.Fatalf("SC installation topoheight mismatch")
+	}
+	if chain.Is_SC_Installed(nil, crypto.Key{2}, installed) {
+		t.Fatalf("unknown SC must not be installed")
+	}
+	if code, found := chain.ReadSC_Code(nil, scid); !found || code != src {
+		t.Fatalf("SC code mismatch")
+	}
+
+	keyhashes := []crypto.Key{SC_Balance_Hash(scid), SC_Key_Hash(dvm.Variable{Type: dvm.String, Value: "missing"})}
+	for _, topoheight := range []int64{-1, installed} {
+		values, err := chain.LoadSCValues_At(nil, scid, keyhashes, topoheight)
+		if err != nil {
+			t.Fatalf("SC values could not be loaded err %s", err)
+		}
+		if len(values) != 2 || values[0].Type != dvm.Uint64 || values[0].Value.(uint64) != 0 || values[1].Type != dvm.Invalid {
+			t.Fatalf("SC values mismatch at topoheight %d %+v", topoheight, values)
+		}
+	}
+}
+
+func Test_SC_State_At(t *testing.T) {
+	chain := new_test_chain(t)
+	src := dryrun_test_sc
+	scid := install_test_sc(t, chain, src)
+
+	key := SC_Key_Hash(dvm.Variable{Type: dvm.String, Value: "owner"})
+	chain.Lock()
+	dbtx, err := chain.store.BeginTX(true)
+	if err != nil {
+		t.Fatalf("cannot begin TX err %s", err)
+	}
+	chain.StoreSCValue(dbtx, scid, key, dvm.Serialize_Variable(dvm.Variable{Type: dvm.String, Value: "alice"}))
+	err = dbtx.Commit()
+	chain.Unlock()
+	if err != nil {
+		t.Fatalf("cannot commit TX err %s", err)
+	}
+
+	state, err := chain.Load_SC_State_At(nil, scid, chain.Load_TOPO_HEIGHT(nil))
+	if err != nil {
+		t.Fatalf("SC state could not be loaded err %s", err)
+	}
+	if len(state) != 2 || state[key].Value != "alice" || state[SC_Balance_Hash(scid)].Value != uint64(0) {
+		t.Fatalf("SC state mismatch %+v", state)
+	}
+}
+
+// replay depth is measured from top, state further below cannot be loaded
+// chain is kept short, topoheights beyond limit are negative, which is enough for the depth check
+func Test_SC_State_Replay_Limit(t *testing.T) {
+	chain := new_test_chain(t)
+	scid := install_test_sc(t, chain, dryrun_test_sc)
+	mine_blocks(t, chain, 2)
+	top := chain.Load_TOPO_HEIGHT(nil)
+
+	if err := chain.Check_SC_Replay(nil, top-SC_STATE_MAX_REPLAY); err != nil {
+		t.Fatalf("state within limit must be available err %s", err)
+	}
+	if err := chain.Check_SC_Replay(nil, top-SC_STATE_MAX_REPLAY-1); err == nil {
+		t.Fatalf("state beyond limit must not be available")
+	}
+
+	// historic readers are limited
+	if _, err := chain.Load_SC_State_At(nil, scid, top-SC_STATE_MAX_REPLAY-1); err == nil {
+		t.Fatalf("SC state beyond limit must not be loaded")
+	}
+	if _, found := chain.ReadSC_Code_At(nil, scid, top-SC_STATE_MAX_REPLAY-1); found {
+		t.Fatalf("SC code beyond limit must not be loaded")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "testing"
+
+import "github.com/deroproject/derosuite/config"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/transaction"
+
+const gas_test_sc = `Function Initialize() Uint64
+10 RETURN 0
+End Function
+
+Function Deposit(value Uint64) Uint64
+10 STORE("deposit", value)
+20 RETURN 0
+End Function
+`
+
+func Test_SC_Gas(t *testing.T) {
+	chain := new_test_chain(t)
+	scid := install_test_sc(t, chain, gas_test_sc)
+	dbtx := write_test_tx(t, chain)
+
+	balance := func() uint64 {
+		v, _ := chain.LoadSCValue(dbtx, scid, SC_Balance_Hash(scid))
+		return v.Value.(uint64)
+	}
+	deposit := sc_test_data(t, transaction.SC_Transaction{SCID: scid, EntryPoint: "Deposit", Gas: 1000})
+	value := 2000 * config.SC_GAS_PRICE
+
+	// gas is reserved from value, unused gas is refunded
+	tx, _ := sc_test_tx(t, deposit, value)
+	sc_outputs := process_test_sc_tx(t, chain, dbtx, tx, 4)
+	receipt, _ := chain.Load_SCReceipt(dbtx, crypto.Key(tx.GetHash()))
+	gas_refund := (1000 - receipt.Gas_Used) * config.SC_GAS_PRICE
+	if !receipt.Success || receipt.Gas_Used == 0 || receipt.Refund != gas_refund || balance() != value-1000*config.SC_GAS_PRICE {
+		t.Fatalf("metered tx failed receipt %+v balance %d", receipt, balance())
+	}
+	if outputs := sc_test_outputs(t, chain, dbtx, tx, sc_outputs); len(outputs) != 1 || outputs[0] != gas_refund {
+		t.Fatalf("unused gas not refunded %+v", outputs)
+	}
+
+	// gas limit is required
+	tx, _ = sc_test_tx(t, sc_test_data(t, transaction.SC_Transaction{SCID: scid, EntryPoint: "Deposit"}), value)
+	sc_outputs = process_test_sc_tx(t, chain, dbtx, tx, 4)
+	receipt, _ = chain.Load_SCReceipt(dbtx, crypto.Key(tx.GetHash()))
+	if receipt.Success || receipt.Refund != value || receipt.Lines != 0 {
+		t.Fatalf("tx without gas limit must not execute receipt %+v", receipt)
+	}
+	if outputs := sc_test_outputs(t, chain, dbtx, tx, sc_outputs); len(outputs) != 1 || outputs[0] != value {
+		t.Fatalf("value not refunded %+v", outputs)
+	}
+
+	// value must cover gas
+	tx, _ = sc_test_tx(t, deposit, 1000*config.SC_GAS_PRICE)
+	process_test_sc_tx(t, chain, dbtx, tx, 4)
+	if receipt, _ = chain.Load_SCReceipt(dbtx, crypto.Key(tx.GetHash())); receipt.Success || receipt.Refund != 1000*config.SC_GAS_PRICE {
+		t.Fatalf("tx with value not covering gas must not execute receipt %+v", receipt)
+	}
+}
+
+// SC txs mined through the mempool are executed metered, by blocks of hard fork 4
+func Test_SC_Mined(t *testing.T) {
+	chain := new_sc_test_chain(t)
+	w := new_test_wallet(t)
+	mine_blocks_to(t, chain, w.GetAddress(), int(config.MINER_TX_AMOUNT_UNLOCK)+5)
+
+	tx := wallet_test_sc_tx(t, chain, w, transaction.SC_Transaction{SC: gas_test_sc, Gas: 1000}, 2000*config.SC_GAS_PRICE)
+	mine_test_tx(t, chain, w, tx)
+
+	bl, err := chain.Load_BL_FROM_ID(nil, chain.Get_Top_ID())
+	if err != nil || bl.Major_Version != 4 {
+		t.Fatalf("SC tx must be mined in a version 4 block err %v", err)
+	}
+	if !chain.Is_SC_Installed(nil, crypto.Key(tx.GetHash()), chain.Load_TOPO_HEIGHT(nil)) {
+		t.Fatalf("SC must be installed")
+	}
+	if receipt, found := chain.Load_SCReceipt(nil, crypto.Key(tx.GetHash())); !found || !receipt.Success || receipt.Gas_Used == 0 {
+		t.Fatalf("SC install must be executed metered, receipt %+v", receipt)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+// this file persists SC execution traces, they are only stored if daemon runs with --sc-trace
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/storage"
+
+// whether SC execution traces are being stored
+func (chain *Blockchain) Is_SC_Tracing() bool {
+	return chain.sc_trace
+}
+
+func (chain *Blockchain) store_sc_trace(dbtx storage.DBTX, tx_hash crypto.Key, trace *dvm.Trace) {
+	chain.store_tx_planet(dbtx, tx_hash, PLANET_TX_SC_TRACE, trace)
+}
+
+func (chain *Blockchain) revert_sc_trace(dbtx storage.DBTX, tx_hash crypto.Key) {
+	chain.clear_tx_planet(dbtx, tx_hash, PLANET_TX_SC_TRACE)
+}
+
+// trace of the execution of tx within the block, in which it is currently valid
+func (chain *Blockchain) Load_SCTrace(dbtx storage.DBTX, tx_hash crypto.Key) (trace dvm.Trace, found bool) {
+	found = chain.load_tx_planet(dbtx, tx_hash, PLANET_TX_SC_TRACE, &trace)
+	return
+}
@@ -0,0 +1,52 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "testing"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+
+func Test_SC_Trace(t *testing.T) {
+	chain := new_test_chain(t)
+	if chain.Is_SC_Tracing() {
+		t.Fatalf("tracing must be disabled by default")
+	}
+
+	tx_hash := crypto.Key{9}
+	trace := dvm.New_Trace()
+	trace.Log("processing")
+	trace.Fail("entrypoint returned 1")
+	trace.Lines = 7
+
+	dbtx := write_test_tx(t, chain)
+
+	if _, found := chain.Load_SCTrace(dbtx, tx_hash); found {
+		t.Fatalf("trace must not exist before storing")
+	}
+
+	chain.store_sc_trace(dbtx, tx_hash, trace)
+	stored, found := chain.Load_SCTrace(dbtx, tx_hash)
+	if !found || stored.Lines != 7 || stored.Failure != "entrypoint returned 1" || len(stored.Entries) != 2 || stored.Entries[1].Kind != dvm.TRACE_ERROR {
+		t.Fatalf("stored trace mismatch %+v", stored)
+	}
+
+	chain.Revert_SC(dbtx, tx_hash, 4)
+	if _, found = chain.Load_SCTrace(dbtx, tx_hash); found {
+		t.Fatalf("trace not reverted")
+	}
+}
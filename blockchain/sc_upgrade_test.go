@@ -0,0 +1,71 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "testing"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+
+const upgrade_test_new_sc = `Function Version() Uint64
+10 RETURN 2
+End Function
+`
+
+func Test_SC_Upgrade(t *testing.T) {
+	chain := new_test_chain(t)
+	src := dryrun_test_sc
+	scid := install_test_sc(t, chain, src)
+
+	key := SC_Key_Hash(dvm.Variable{Type: dvm.String, Value: "owner"})
+	tx_hash := crypto.Key{8}
+	tx_store := dvm.Initialize_TX_store()
+	tx_store.Code[scid] = upgrade_test_new_sc
+	tx_store.Atoms = append(tx_store.Atoms, dvm.DataAtom{Key: dvm.DataKey{SCID: scid, Key: dvm.Variable{Type: dvm.String, Value: "owner"}},
+		Value: dvm.Variable{Type: dvm.String, Value: "alice"}})
+
+	dbtx := write_test_tx(t, chain)
+
+	// code is replaced, storage is kept
+	chain.store_changes(dbtx, tx_hash, tx_store)
+	if code, _ := chain.ReadSC_Code(dbtx, scid); code != upgrade_test_new_sc {
+		t.Fatalf("SC code not replaced")
+	}
+	if sc, found := chain.ReadSC(dbtx, scid); !found || len(sc.Functions) != 1 || sc.Functions["Version"].Name != "Version" {
+		t.Fatalf("parsed SC not replaced %+v", sc)
+	}
+	if v, found := chain.LoadSCValue(dbtx, scid, key); !found || v.Value != "alice" {
+		t.Fatalf("SC storage lost")
+	}
+
+	// code at current topoheight is the new code
+	if code, found := chain.ReadSC_Code_At(dbtx, scid, chain.Load_TOPO_HEIGHT(dbtx)); !found || code != upgrade_test_new_sc {
+		t.Fatalf("SC code at topoheight mismatch")
+	}
+
+	// reorg restores previous code
+	chain.Revert_SC(dbtx, tx_hash, 4)
+	if code, _ := chain.ReadSC_Code(dbtx, scid); code != src {
+		t.Fatalf("SC code not reverted")
+	}
+	if sc, found := chain.ReadSC(dbtx, scid); !found || len(sc.Functions) != 2 {
+		t.Fatalf("parsed SC not reverted %+v", sc)
+	}
+	if _, found := chain.LoadSCValue(dbtx, scid, key); found {
+		t.Fatalf("SC storage not reverted")
+	}
+}
@@ -0,0 +1,435 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+// this file implements snapshots of the chain at a specific topoheight
+// a snapshot contains only blocks, transactions and their topological order
+// while being imported, blocks are verified against checkpoints checksums and client protocol is replayed over them
+// so output index, key images, tx validity, SC state and tips are all rebuilt locally, nothing derived is trusted
+import "io"
+import "fmt"
+import "hash"
+import "bytes"
+import "bufio"
+import "runtime/debug"
+import "encoding/binary"
+
+import "golang.org/x/crypto/sha3"
+
+import "github.com/deroproject/derosuite/block"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/globals"
+import "github.com/deroproject/derosuite/storage"
+import "github.com/deroproject/derosuite/checkpoints"
+import "github.com/deroproject/derosuite/transaction"
+
+// snapshot file layout
+// magic, version, genesis block hash, topoheight
+// records, each record is universe, galaxy, solar, key, value ( all length prefixed )
+// for every block in topological order, its txs not seen earlier, the block and its topological position
+// terminator record
+// sha3-256 checksum of all the above
+var SNAPSHOT_MAGIC = []byte("DEROSNAP")
+
+const SNAPSHOT_VERSION = 2
+
+const snapshot_record = 1
+const snapshot_end = 0
+
+// snapshot writer, every byte written is also hashed
+type snapshot_writer struct {
+	w *bufio.Writer
+	h hash.Hash
+}
+
+func (s *snapshot_writer) Write(buf []byte) (int, error) {
+	s.h.Write(buf)
+	return s.w.Write(buf)
+}
+
+func (s *snapshot_writer) write_bytes(buf []byte) (err error) {
+	var lbuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lbuf[:], uint64(len(buf)))
+	if _, err = s.Write(lbuf[:n]); err != nil {
+		return
+	}
+	_, err = s.Write(buf)
+	return
+}
+
+func (s *snapshot_writer) record(universe, galaxy, solar, key, value []byte) (err error) {
+	if _, err = s.Write([]byte{snapshot_record}); err != nil {
+		return
+	}
+	for _, field := range [][]byte{universe, galaxy, solar, key, value} {
+		if err = s.write_bytes(field); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// snapshot reader, every byte read is also hashed
+type snapshot_reader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (s *snapshot_reader) ReadByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		s.h.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (s *snapshot_reader) Read(buf []byte) (int, error) {
+	n, err := io.ReadFull(s.r, buf)
+	s.h.Write(buf[:n])
+	return n, err
+}
+
+func (s *snapshot_reader) read_bytes() (buf []byte, err error) {
+	length, err := binary.ReadUvarint(s)
+	if err != nil {
+		return
+	}
+	if length > 256*1024*1024 { // no single object can be this big
+		return nil, fmt.Errorf("snapshot record too big %d bytes", length)
+	}
+	buf = make([]byte, length, length)
+	_, err = s.Read(buf)
+	return
+}
+
+// export a consistent snapshot of the chain till the given topoheight ( inclusive )
+// everything is read within a single read only DB TX, so the chain can keep running
+func (chain *Blockchain) Export_Snapshot(w io.Writer, topoheight int64) (err error) {
+
+	dbtx, err := chain.store.BeginTX(false)
+	if err != nil {
+		logger.Warnf("Could NOT export snapshot. Error opening readable TX, err %s", err)
+		return
+	}
+	defer dbtx.Rollback()
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Warnf("Recovered while exporting snapshot, Stack trace below")
+			logger.Warnf("Stack trace  \n%s", debug.Stack())
+			err = fmt.Errorf("panic while exporting snapshot %v", r)
+		}
+	}()
+
+	current_topoheight := chain.Load_TOPO_HEIGHT(dbtx)
+	if topoheight < 0 || topoheight > current_topoheight {
+		return fmt.Errorf("topoheight %d is out of range, current topoheight %d", topoheight, current_topoheight)
+	}
+
+	if blid, err := chain.Load_Block_Topological_order_at_index(dbtx, topoheight); err == nil && chain.Load_Height_for_BL_ID(dbtx, blid) > chain.Get_Stable_Height() {
+		logger.Warnf("Snapshot topoheight %d is above stable height, it may not be accepted by other nodes", topoheight)
+	}
+
+	sw := &snapshot_writer{w: bufio.NewWriter(w), h: sha3.New256()}
+
+	// header
+	sw.Write(SNAPSHOT_MAGIC)
+	sw.Write([]byte{SNAPSHOT_VERSION})
+	sw.Write(globals.Config.Genesis_Block_Hash[:])
+	sw.Write(itob(uint64(topoheight)))
+
+	tx_done := map[crypto.Hash]bool{}
+	for topo := int64(0); topo <= topoheight; topo++ {
+		blid, err := chain.Load_Block_Topological_order_at_index(dbtx, topo)
+		if err != nil {
+			return err
+		}
+		bl, err := chain.Load_BL_FROM_ID(dbtx, blid)
+		if err != nil {
+			return err
+		}
+
+		// txs are written before the block mining them, miner tx is part of the block itself
+		for _, txid := range bl.Tx_hashes {
+			if tx_done[txid] {
+				continue
+			}
+			tx_done[txid] = true
+
+			if prunable_hash, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, txid[:], PLANET_TX_PRUNED); err == nil && len(prunable_hash) > 0 {
+				// snapshot blocks could never be verified against checkpoints
+				return fmt.Errorf("tx %s has been pruned, snapshots can only be exported from archival chain", txid)
+			}
+			tx_bytes, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, txid[:], PLANET_TX_BLOB)
+			if err != nil {
+				return fmt.Errorf("tx %s could not be loaded err %s", txid, err)
+			}
+			if err = sw.record(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, txid[:], PLANET_TX_BLOB, tx_bytes); err != nil {
+				return err
+			}
+		}
+
+		if err = sw.record(BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, blid[:], PLANET_BLOB, bl.Serialize()); err != nil {
+			return err
+		}
+		if err = sw.record(BLOCKCHAIN_UNIVERSE, GALAXY_TOPOLOGICAL_INDEX, GALAXY_TOPOLOGICAL_INDEX, itob(uint64(topo)), blid[:]); err != nil {
+			return err
+		}
+	}
+
+	// terminate and write checksum, checksum itself is not hashed
+	sw.Write([]byte{snapshot_end})
+	sw.w.Write(sw.h.Sum(nil))
+
+	if err = sw.w.Flush(); err != nil {
+		return err
+	}
+
+	logger.Infof("Snapshot exported at topoheight %d, blocks %d txs %d", topoheight, topoheight+1, len(tx_done))
+	return nil
+}
+
+// import a snapshot to bootstrap a fresh chain
+// the snapshot is written atomically, if anything fails, nothing is written
+// every block checksum must be known to checkpoints, unless checkpoints are disabled
+func (chain *Blockchain) Import_Snapshot(r io.Reader) (err error) {
+	err = chain.import_snapshot(r)
+	if err == nil {
+		chain.Initialise_Chain_From_DB() // reload chain state from the imported data
+	}
+	return
+}
+
+func (chain *Blockchain) import_snapshot(r io.Reader) (err error) {
+	chain.Lock()
+	defer chain.Unlock()
+
+	if chain.Load_TOPO_HEIGHT(nil) != 0 {
+		return fmt.Errorf("snapshot can only be imported in a fresh data dir, current topoheight %d", chain.Load_TOPO_HEIGHT(nil))
+	}
+
+	sr := &snapshot_reader{r: bufio.NewReader(r), h: sha3.New256()}
+
+	header := make([]byte, len(SNAPSHOT_MAGIC)+1+32+8)
+	if _, err = sr.Read(header); err != nil {
+		return fmt.Errorf("error reading snapshot header err %s", err)
+	}
+	if !bytes.Equal(header[:len(SNAPSHOT_MAGIC)], SNAPSHOT_MAGIC) {
+		return fmt.Errorf("not a snapshot file")
+	}
+	header = header[len(SNAPSHOT_MAGIC):]
+	if header[0] != SNAPSHOT_VERSION {
+		return fmt.Errorf("unsupported snapshot version %d", header[0])
+	}
+	if !bytes.Equal(header[1:33], globals.Config.Genesis_Block_Hash[:]) {
+		return fmt.Errorf("snapshot belongs to a different network, genesis %x", header[1:33])
+	}
+	topoheight := int64(binary.BigEndian.Uint64(header[33:]))
+
+	dbtx, err := chain.store.BeginTX(true)
+	if err != nil {
+		logger.Warnf("Could NOT import snapshot. Error opening writable TX, err %s", err)
+		return
+	}
+
+	success := false
+	defer func() {
+		// safety so if anything wrong happens, import fails
+		if r := recover(); r != nil {
+			logger.Warnf("Recovered while importing snapshot, Stack trace below")
+			logger.Warnf("Stack trace  \n%s", debug.Stack())
+			err = fmt.Errorf("panic while importing snapshot %v", r)
+			success = false
+		}
+
+		if success { // snapshot is verified, commit it atomically
+			if err = dbtx.Commit(); err != nil {
+				err = fmt.Errorf("snapshot could not be committed err %s", err)
+				chain.discard_pending_events()
+				return
+			}
+			dbtx.Sync()
+			chain.publish_pending_events() // replayed blocks are now visible, let subscribers know
+		} else {
+			dbtx.Rollback()
+			chain.discard_pending_events() // events queued while replaying blocks were never committed
+		}
+	}()
+
+	if chain.checkpints_disabled {
+		logger.Warnf("Checkpoints are disabled, snapshot blocks will NOT be verified")
+	}
+
+	// only raw txs, blocks and topological order are accepted, anything else is rejected
+	pending := map[crypto.Hash]*block.Block{} // blocks waiting for their topological position
+	next_topo := int64(0)
+	record_count := 0
+	for {
+		tag, err := sr.ReadByte()
+		if err != nil {
+			return fmt.Errorf("error reading snapshot record %d err %s", record_count, err)
+		}
+		if tag == snapshot_end {
+			break
+		}
+		if tag != snapshot_record {
+			return fmt.Errorf("invalid snapshot record %d tag %d", record_count, tag)
+		}
+
+		var fields [5][]byte
+		for i := range fields {
+			if fields[i], err = sr.read_bytes(); err != nil {
+				return fmt.Errorf("error reading snapshot record %d err %s", record_count, err)
+			}
+		}
+		universe, galaxy, solar, key, value := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+		switch {
+		case !bytes.Equal(universe, BLOCKCHAIN_UNIVERSE):
+			return fmt.Errorf("snapshot record %d is not allowed, universe %q", record_count, universe)
+
+		case bytes.Equal(galaxy, GALAXY_TRANSACTION) && bytes.Equal(key, PLANET_TX_BLOB):
+			var tx transaction.Transaction
+			if err = tx.DeserializeHeader(value); err != nil {
+				return fmt.Errorf("snapshot record %d tx could not be deserialized err %s", record_count, err)
+			}
+			if txid := tx.GetHash(); !bytes.Equal(txid[:], solar) {
+				return fmt.Errorf("snapshot record %d tx hash mismatch %s", record_count, txid)
+			}
+			chain.Store_TX(dbtx, &tx)
+
+		case bytes.Equal(galaxy, GALAXY_BLOCK) && bytes.Equal(key, PLANET_BLOB):
+			var bl block.Block
+			if err = bl.Deserialize(value); err != nil {
+				return fmt.Errorf("snapshot record %d block could not be deserialized err %s", record_count, err)
+			}
+			blid := bl.GetHash()
+			if !bytes.Equal(blid[:], solar) {
+				return fmt.Errorf("snapshot record %d block hash mismatch %s", record_count, blid)
+			}
+			pending[blid] = &bl
+
+		case bytes.Equal(galaxy, GALAXY_TOPOLOGICAL_INDEX) && bytes.Equal(solar, GALAXY_TOPOLOGICAL_INDEX):
+			if len(key) != 8 || int64(binary.BigEndian.Uint64(key)) != next_topo || len(value) != 32 {
+				return fmt.Errorf("snapshot record %d has invalid topological order, expected topoheight %d", record_count, next_topo)
+			}
+			var blid crypto.Hash
+			copy(blid[:], value)
+			bl, ok := pending[blid]
+			if !ok {
+				return fmt.Errorf("snapshot block %s at topoheight %d is missing", blid, next_topo)
+			}
+			delete(pending, blid)
+
+			if err = chain.import_snapshot_block(dbtx, bl, next_topo); err != nil {
+				return err
+			}
+			next_topo++
+
+		default:
+			return fmt.Errorf("snapshot record %d is not allowed, galaxy %q planet %q", record_count, galaxy, key)
+		}
+		record_count++
+	}
+
+	expected_checksum := sr.h.Sum(nil)
+	checksum := make([]byte, len(expected_checksum))
+	if _, err = io.ReadFull(sr.r, checksum); err != nil {
+		return fmt.Errorf("error reading snapshot checksum err %s", err)
+	}
+	if !bytes.Equal(checksum, expected_checksum) {
+		return fmt.Errorf("snapshot checksum mismatch expected %x actual %x", expected_checksum, checksum)
+	}
+
+	if next_topo != topoheight+1 {
+		return fmt.Errorf("snapshot topoheight mismatch header %d blocks %d", topoheight, next_topo)
+	}
+	if len(pending) != 0 {
+		return fmt.Errorf("snapshot contains %d blocks without topological position", len(pending))
+	}
+
+	// chain state is derived from the replayed blocks, tips are the blocks which have no future
+	var tips []crypto.Hash
+	top_height := int64(0)
+	for topo := int64(0); topo <= topoheight; topo++ {
+		blid, err := chain.Load_Block_Topological_order_at_index(dbtx, topo)
+		if err != nil {
+			return err
+		}
+		if len(chain.Get_Block_Future(dbtx, blid)) == 0 {
+			tips = append(tips, blid)
+		}
+		if height := chain.Load_Height_for_BL_ID(dbtx, blid); height > top_height {
+			top_height = height
+		}
+	}
+	chain.store_TIPS(dbtx, chain.SortTips(dbtx, tips))
+	chain.Store_TOPO_HEIGHT(dbtx, topoheight)
+	chain.Store_TOP_HEIGHT(dbtx, top_height)
+
+	if chain.index {
+		chain.store_index_topoheight(dbtx, topoheight)
+	}
+
+	success = true
+	logger.Infof("Snapshot imported at topoheight %d, records %d", topoheight, record_count)
+	return nil
+}
+
+// verifies block against checkpoints, stores it and replays client protocol for it at topoheight
+// the same as Add_Complete_Block does, but without any PoW or tx verification
+func (chain *Blockchain) import_snapshot_block(dbtx storage.DBTX, bl *block.Block, topoheight int64) error {
+	blid := bl.GetHash()
+
+	cbl := block.Complete_Block{Bl: bl}
+	for _, txid := range bl.Tx_hashes {
+		tx, err := chain.Load_TX_FROM_ID(dbtx, txid)
+		if err != nil {
+			return fmt.Errorf("snapshot missing tx %s block %s", txid, blid)
+		}
+		cbl.Txs = append(cbl.Txs, tx)
+	}
+
+	if !chain.checkpints_disabled && !checkpoints.IsCheckSumKnown(chain.BlockCheckSum(&cbl)) {
+		return fmt.Errorf("snapshot block %s at topoheight %d is not covered by checkpoints", blid, topoheight)
+	}
+
+	if topoheight == 0 && blid != globals.Config.Genesis_Block_Hash {
+		return fmt.Errorf("snapshot starts with block %s instead of genesis", blid)
+	}
+
+	if chain.Block_Exists(dbtx, blid) {
+		if topoheight != 0 { // genesis is already stored in a fresh chain
+			return fmt.Errorf("snapshot block %s is duplicated at topoheight %d", blid, topoheight)
+		}
+	} else {
+		for _, tip := range bl.Tips { // every tip must have been placed earlier
+			if !chain.Block_Exists(dbtx, tip) {
+				return fmt.Errorf("snapshot block %s at topoheight %d refers to unknown tip %s", blid, topoheight, tip)
+			}
+		}
+		chain.Store_BL(dbtx, bl)
+		if chain.index {
+			chain.index_block(dbtx, bl, blid)
+		}
+	}
+
+	if !chain.process_topo_block(dbtx, blid, topoheight) {
+		return fmt.Errorf("snapshot block %s at topoheight %d could not be processed", blid, topoheight)
+	}
+	return nil
+}
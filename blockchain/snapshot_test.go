@@ -0,0 +1,191 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package blockchain
+
+import "bytes"
+import "bufio"
+import "testing"
+
+import "golang.org/x/crypto/sha3"
+
+import "github.com/deroproject/derosuite/crypto"
+
+// appends a record to an exported snapshot and recomputes its checksum, as a malicious exporter would
+func tamper_snapshot(t *testing.T, snapshot []byte, universe, galaxy, solar, key, value []byte) []byte {
+	var buf bytes.Buffer
+	sw := &snapshot_writer{w: bufio.NewWriter(&buf), h: sha3.New256()}
+	if err := sw.record(universe, galaxy, solar, key, value); err != nil {
+		t.Fatalf("record could not be written err %s", err)
+	}
+	sw.w.Flush()
+
+	tampered := append([]byte{}, snapshot[:len(snapshot)-1-32]...) // drop terminator and checksum
+	tampered = append(tampered, buf.Bytes()...)
+	tampered = append(tampered, snapshot_end)
+	checksum := sha3.Sum256(tampered)
+	return append(tampered, checksum[:]...)
+}
+
+func Test_Snapshot_Export_Import(t *testing.T) {
+	chain := new_test_chain(t)
+	mine_blocks(t, chain, 12)
+
+	var buf bytes.Buffer
+	if err := chain.Export_Snapshot(&buf, chain.Load_TOPO_HEIGHT(nil)+1); err == nil {
+		t.Fatalf("snapshot above current topoheight must fail")
+	}
+	if err := chain.Export_Snapshot(&buf, 7); err != nil {
+		t.Fatalf("snapshot export failed err %s", err)
+	}
+	snapshot := buf.Bytes()
+
+	fresh := new_test_chain(t)
+
+	// simulator blocks are not known to checkpoints
+	if err := fresh.Import_Snapshot(bytes.NewReader(snapshot)); err == nil {
+		t.Fatalf("snapshot import must fail checkpoints verification")
+	}
+
+	fresh.checkpints_disabled = true
+
+	corrupted := append([]byte{}, snapshot...)
+	corrupted[len(corrupted)/2] ^= 1
+	if err := fresh.Import_Snapshot(bytes.NewReader(corrupted)); err == nil {
+		t.Fatalf("corrupted snapshot import must fail")
+	}
+
+	// derived state is never accepted from the snapshot, even with a valid checksum
+	for _, galaxy := range [][]byte{GALAXY_OUTPUT_INDEX, GALAXY_KEYIMAGE, GALAXY_TRANSACTION_VALIDITY, GALAXY_KEYVALUE} {
+		tampered := tamper_snapshot(t, snapshot, BLOCKCHAIN_UNIVERSE, galaxy, galaxy, itob(0), []byte{1})
+		if err := fresh.Import_Snapshot(bytes.NewReader(tampered)); err == nil {
+			t.Fatalf("snapshot with %s record must fail", galaxy)
+		}
+	}
+	tampered := tamper_snapshot(t, snapshot, SMARTCONTRACT_UNIVERSE, SMARTCONTRACT_UNIVERSE, make([]byte, 32), make([]byte, 32), []byte{1})
+	if err := fresh.Import_Snapshot(bytes.NewReader(tampered)); err == nil {
+		t.Fatalf("snapshot with SC state record must fail")
+	}
+	tampered = tamper_snapshot(t, snapshot, BLOCKCHAIN_UNIVERSE, GALAXY_BLOCK, make([]byte, 32), PLANET_MINERTX_REWARD, itob(1))
+	if err := fresh.Import_Snapshot(bytes.NewReader(tampered)); err == nil {
+		t.Fatalf("snapshot with derived block data must fail")
+	}
+	if fresh.Load_TOPO_HEIGHT(nil) != 0 {
+		t.Fatalf("failed imports must not write anything")
+	}
+
+	if err := fresh.Import_Snapshot(bytes.NewReader(snapshot)); err != nil {
+		t.Fatalf("snapshot import failed err %s", err)
+	}
+
+	if fresh.Load_TOPO_HEIGHT(nil) != 7 {
+		t.Fatalf("imported topoheight expected 7 actual %d", fresh.Load_TOPO_HEIGHT(nil))
+	}
+	expected, _ := chain.Load_Block_Topological_order_at_index(nil, 7)
+	if tips := fresh.load_TIPS(nil); len(tips) != 1 || tips[0] != expected {
+		t.Fatalf("imported tips expected %s actual %v", expected, tips)
+	}
+
+	// replayed state must be the same as the state built while syncing
+	src_tx, _ := chain.store.BeginTX(false)
+	defer src_tx.Rollback()
+	dst_tx, _ := fresh.store.BeginTX(false)
+	defer dst_tx.Rollback()
+	for topo := int64(0); topo <= 7; topo++ {
+		blid, _ := chain.Load_Block_Topological_order_at_index(nil, topo)
+		if imported, _ := fresh.Load_Block_Topological_order_at_index(nil, topo); imported != blid {
+			t.Fatalf("topoheight %d block mismatch", topo)
+		}
+		start, end := chain.Get_Block_Output_Index(nil, blid)
+		istart, iend := fresh.Get_Block_Output_Index(nil, blid)
+		if start != istart || end != iend {
+			t.Fatalf("topoheight %d output index mismatch %d-%d imported %d-%d", topo, start, end, istart, iend)
+		}
+		for i := start; i < end; i++ {
+			data, _ := src_tx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_OUTPUT_INDEX, GALAXY_OUTPUT_INDEX, itob(uint64(i)))
+			idata, _ := dst_tx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_OUTPUT_INDEX, GALAXY_OUTPUT_INDEX, itob(uint64(i)))
+			if len(data) == 0 || !bytes.Equal(data, idata) {
+				t.Fatalf("output index %d mismatch", i)
+			}
+		}
+		if chain.Load_Block_Total_Reward(nil, blid) != fresh.Load_Block_Total_Reward(nil, blid) ||
+			chain.Load_Already_Generated_Coins_for_Topo_Index(nil, topo) != fresh.Load_Already_Generated_Coins_for_Topo_Index(nil, topo) {
+			t.Fatalf("topoheight %d reward mismatch", topo)
+		}
+	}
+
+	// exporting the imported chain must give back the same snapshot
+	var reexport bytes.Buffer
+	if err := fresh.Export_Snapshot(&reexport, 7); err != nil {
+		t.Fatalf("snapshot re-export failed err %s", err)
+	}
+	if !bytes.Equal(snapshot, reexport.Bytes()) {
+		t.Fatalf("snapshot re-export differs")
+	}
+
+	if err := fresh.Import_Snapshot(bytes.NewReader(snapshot)); err == nil {
+		t.Fatalf("snapshot import in non fresh chain must fail")
+	}
+}
+
+// events queued while replaying snapshot blocks are only delivered once the import commits
+func Test_Snapshot_Import_Events(t *testing.T) {
+	chain := new_test_chain(t)
+	mine_blocks(t, chain, 4)
+
+	var buf bytes.Buffer
+	if err := chain.Export_Snapshot(&buf, 4); err != nil {
+		t.Fatalf("snapshot export failed err %s", err)
+	}
+	snapshot := buf.Bytes()
+
+	fresh := new_test_chain(t)
+	fresh.checkpints_disabled = true
+	events := fresh.Subscribe(EVENT_SC_CHANGED|EVENT_NEW_BLOCK, 16)
+	defer fresh.Unsubscribe(events)
+
+	// simulator blocks contain no SC txs, so an event is queued as replay of an SC tx would
+	replayed := Event{Type: EVENT_SC_CHANGED, TXID: crypto.Hash{1}}
+
+	fresh.queue_event(replayed)
+	corrupted := append([]byte{}, snapshot...)
+	corrupted[len(corrupted)-1] ^= 1 // checksum mismatch is found after all blocks are replayed
+	if err := fresh.Import_Snapshot(bytes.NewReader(corrupted)); err == nil {
+		t.Fatalf("corrupted snapshot import must fail")
+	}
+	mine_blocks(t, fresh, 1) // publishes whatever is queued
+	if e := <-events; e.Type != EVENT_NEW_BLOCK {
+		t.Fatalf("event queued by failed import was published %+v", e)
+	}
+
+	fresh = new_test_chain(t)
+	fresh.checkpints_disabled = true
+	events = fresh.Subscribe(EVENT_SC_CHANGED, 16)
+	defer fresh.Unsubscribe(events)
+
+	fresh.queue_event(replayed)
+	if err := fresh.Import_Snapshot(bytes.NewReader(snapshot)); err != nil {
+		t.Fatalf("snapshot import failed err %s", err)
+	}
+	select {
+	case e := <-events:
+		if e.TXID != replayed.TXID {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	default:
+		t.Fatalf("event queued by successful import was not published")
+	}
+}
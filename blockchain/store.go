@@ -66,6 +66,7 @@ var GALAXY_KEYVALUE = []byte("GKV") //[]byte("KEYVALUE") // used to store simple
 var TOP_HEIGHT = []byte("TOP_HEIGHT")   // stores current TOP HEIGHT, only stores single value
 var TOPO_HEIGHT = []byte("TOPO_HEIGHT") // stores current TOPO HEIGHT, only stores single value
 var TIPS = []byte("TIPS")               // this stores tips
+var PRUNED_TOPO_HEIGHT = []byte("PRUNED_TOPO_HEIGHT") // all blocks below this topo height have been pruned
 
 // the unique TXID or block ID becomes the solar system , which is common and saves lot of space
 
@@ -105,11 +106,21 @@ var PLANET_TX_BLOB = []byte("BLOB")          // contains serialised  TX , this a
 var PLANET_TX_MINED_IN_BLOCK = []byte("MBL") //[]byte("MINERBLOCK") // which blocks mined this tx, stores topo height of mined block
 var PLANET_TX_MINED = []byte("MIN")          // all blocks where tx is mined in in
 var PLANET_TX_SIZE = []byte("SIZE")
+var PLANET_TX_PRUNED = []byte("PRN") // prunable hash of tx, only present if the prunable data has been discarded
 
 var PLANET_TX_SC_BYTES = []byte("SC")
 var PLANET_TX_SC_PROCESSED = []byte("SCP")
 var PLANET_TX_SC_CHANGELOG = []byte("SCL")
 
+// events emitted by SCs while executing the tx
+var PLANET_TX_SC_EVENTS = []byte("SCE")
+
+// execution trace of SC tx, only stored with --sc-trace
+var PLANET_TX_SC_TRACE = []byte("SCT")
+
+// result of processing SC tx
+var PLANET_TX_SC_RECEIPT = []byte("SCR")
+
 // the universe concept is there, as we bring in smart contracts, we will give each of them a universe to play within
 // while communicating with external universe
 
@@ -334,6 +345,11 @@ func (chain *Blockchain) Store_TX(dbtx storage.DBTX, tx *transaction.Transaction
 	// store size of tx
 	dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, hash[:], PLANET_TX_SIZE, uint64(len(serialized)))
 
+	// if the tx was pruned earlier, it is complete again
+	if prunable_hash, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, hash[:], PLANET_TX_PRUNED); err == nil && len(prunable_hash) > 0 {
+		dbtx.StoreObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, hash[:], PLANET_TX_PRUNED, []byte{})
+	}
+
 	//dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, hash[:], PLANET_TX_MINED_IN_BLOCK, uint64(TopoHeight))
 
 	_ = err
@@ -684,6 +700,18 @@ func (chain *Blockchain) Load_TX_FROM_ID(dbtx storage.DBTX, hash [32]byte) (*tra
 		return nil, err
 	}
 
+	// if the tx has been pruned, only its prunable hash is available
+	if prunable_hash, err := dbtx.LoadObject(BLOCKCHAIN_UNIVERSE, GALAXY_TRANSACTION, hash[:], PLANET_TX_PRUNED); err == nil && len(prunable_hash) == 32 {
+		var phash crypto.Hash
+		copy(phash[:], prunable_hash)
+		err = tx.DeserializeHeaderPruned(tx_data, phash)
+		if err != nil {
+			logger.Printf("fError deserialiing pruned tx, tx id %s len(data) %d data %x", hash[:], len(tx_data), tx_data)
+			return nil, err
+		}
+		return &tx, nil
+	}
+
 	// we should deserialize the block here
 	err = tx.DeserializeHeader(tx_data)
 
@@ -1026,6 +1054,12 @@ func (chain *Blockchain) Store_TOPO_HEIGHT(dbtx storage.DBTX, height int64) {
 	dbtx.StoreUint64(BLOCKCHAIN_UNIVERSE, GALAXY_KEYVALUE, TOPO_HEIGHT, TOPO_HEIGHT, uint64(height))
 }
 
+// read only TX for callers outside the package, which need several values from the same state
+// caller must Rollback it
+func (chain *Blockchain) Begin_Read_TX() (storage.DBTX, error) {
+	return chain.store.BeginTX(false)
+}
+
 // faster bootstrap
 func (chain *Blockchain) Load_TOPO_HEIGHT(dbtx storage.DBTX) (height int64) {
 	var err error
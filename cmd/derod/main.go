@@ -63,7 +63,7 @@ var command_line string = `derod
 DERO : A secure, private blockchain with smart-contracts
 
 Usage:
-  derod [--help] [--version] [--testnet] [--debug]  [--sync-node] [--boltdb | --badgerdb] [--disable-checkpoints] [--socks-proxy=<socks_ip:port>] [--data-dir=<directory>] [--p2p-bind=<0.0.0.0:18089>] [--add-exclusive-node=<ip:port>]... [--add-priority-node=<ip:port>]... 	 [--min-peers=<11>] [--rpc-bind=<127.0.0.1:9999>] [--lowcpuram] [--mining-address=<wallet_address>] [--mining-threads=<cpu_num>] [--node-tag=<unique name>]
+  derod [--help] [--version] [--testnet] [--debug]  [--sync-node] [--boltdb | --badgerdb | --memorydb] [--disable-checkpoints] [--prune] [--index] [--sc-trace] [--mempool-size=<100>] [--socks-proxy=<socks_ip:port>] [--data-dir=<directory>] [--p2p-bind=<0.0.0.0:18089>] [--add-exclusive-node=<ip:port>]... [--add-priority-node=<ip:port>]... 	 [--min-peers=<11>] [--rpc-bind=<127.0.0.1:9999>] [--rpc-restricted] [--rpc-ratelimit=<method=rate:burst,...>] [--lowcpuram] [--mining-address=<wallet_address>] [--mining-threads=<cpu_num>] [--node-tag=<unique name>]
   derod -h | --help
   derod --version
 
@@ -74,10 +74,17 @@ Options:
   --debug       Debug mode enabled, print log messages
   --boltdb      Use boltdb as backend  (default on 64 bit systems)
   --badgerdb    Use Badgerdb as backend (default on 32 bit systems)
+  --memorydb    Use RAM as backend, nothing is persisted (for testing/simulation)
   --disable-checkpoints  Disable checkpoints, work in truly async, slow mode 1 block at a time
+  --prune       Discard range proofs and signatures of old blocks covered by checkpoints, saves disk space
+  --index       Maintain secondary indexes of SC txs, tx blocks and block miners, served over RPC
+  --sc-trace    Store execution trace of every SC tx, served over RPC by tracetransaction
+  --mempool-size=<100>    Mempool size in MB, lowest fee per KB txs are evicted when full
   --socks-proxy=<socks_ip:port>  Use a proxy to connect to network.
   --data-dir=<directory>    Store blockchain data at this location
   --rpc-bind=<127.0.0.1:9999>    RPC listens on this ip:port
+  --rpc-restricted    Hide mining, admin and tracing RPC methods, limit SC dry runs and historic SC state, rate limit by default, use for public facing nodes
+  --rpc-ratelimit=<method=rate:burst,...>    Per IP rate limits in requests per second, eg. default=50:100,getblock=10:20, merged on top of restricted defaults
   --p2p-bind=<0.0.0.0:18089>    p2p server listens on this ip:port, specify port 0 to disable listening server
   --add-exclusive-node=<ip:port>	Connect to specific peer only 
   --add-priority-node=<ip:port>	Maintain persistant connection to specified peer
@@ -141,6 +148,34 @@ func main() {
 
 	params := map[string]interface{}{}
 
+	if globals.Arguments["--memorydb"].(bool) {
+		params["--memorydb"] = true
+		globals.Logger.Warnf("Using memory backend, blockchain will be lost on exit")
+	}
+
+	if globals.Arguments["--prune"].(bool) {
+		params["--prune"] = true
+		globals.Logger.Infof("Pruning enabled, old transactions will not be served to peers in full")
+	}
+
+	if globals.Arguments["--index"].(bool) {
+		params["--index"] = true
+		globals.Logger.Infof("Indexing enabled")
+	}
+
+	if globals.Arguments["--sc-trace"].(bool) {
+		params["--sc-trace"] = true
+		globals.Logger.Infof("SC execution traces will be stored")
+	}
+
+	if globals.Arguments["--mempool-size"] != nil {
+		size, err := strconv.ParseUint(globals.Arguments["--mempool-size"].(string), 10, 64)
+		if err != nil || size == 0 {
+			globals.Logger.Fatalf("--mempool-size should be a positive number of MB")
+		}
+		params["--mempool-size"] = size * 1024 * 1024
+	}
+
 	//params["--disable-checkpoints"] = globals.Arguments["--disable-checkpoints"].(bool)
 	chain, err := blockchain.Blockchain_Start(params)
 
@@ -814,6 +849,9 @@ func main() {
 				supply -= (1000000 * 1000000000000) // remove  premine
 			}
 			fmt.Printf("Network %s Height %d  NW Hashrate %0.03f MH/sec  TH %s Peers %d inc, %d out  MEMPOOL size %d  Total Supply %s DERO \n", globals.Config.Name, chain.Get_Height(), float64(chain.Get_Network_HashRate())/1000000.0, chain.Get_Top_ID(), inc, out, len(chain.Mempool.Mempool_List_TX()), globals.FormatMoney(supply))
+			if chain.Is_Pruning() {
+				fmt.Printf("Pruning enabled, pruned upto topoheight %d\n", chain.Load_Pruned_TopoHeight(nil))
+			}
 
 			// print hardfork status on second line
 			hf_state, _, _, threshold, version, votes, window := chain.Get_HF_info()
@@ -913,6 +951,50 @@ func main() {
 		case command == "bans":
 			p2p.BanList_Print() // print ban list
 
+		case command == "export_snapshot": // exports chain till specific topoheight to a file
+			if len(line_parts) != 3 {
+				fmt.Printf("export_snapshot needs 2 parameters, <topoheight> <filename>\n")
+				break
+			}
+			topoheight, err := strconv.ParseInt(line_parts[1], 10, 64)
+			if err != nil {
+				fmt.Printf("err parsing topoheight %s\n", err)
+				break
+			}
+
+			f, err := os.Create(line_parts[2])
+			if err != nil {
+				globals.Logger.Warnf("error creating snapshot file %s", err)
+				break
+			}
+			err = chain.Export_Snapshot(f, topoheight)
+			f.Close()
+			if err != nil {
+				globals.Logger.Warnf("error exporting snapshot err: %s", err)
+				os.Remove(line_parts[2])
+			} else {
+				globals.Logger.Infof("Successfully exported snapshot at topoheight %d to file %s", topoheight, line_parts[2])
+			}
+
+		case command == "import_snapshot": // bootstraps a fresh chain from a snapshot
+			if len(line_parts) != 2 {
+				fmt.Printf("import_snapshot needs 1 parameter, <filename>\n")
+				break
+			}
+
+			f, err := os.Open(line_parts[1])
+			if err != nil {
+				globals.Logger.Warnf("error opening snapshot file %s", err)
+				break
+			}
+			err = chain.Import_Snapshot(f)
+			f.Close()
+			if err != nil {
+				globals.Logger.Warnf("error importing snapshot err: %s", err)
+			} else {
+				globals.Logger.Infof("Successfully imported snapshot from file %s, topoheight %d", line_parts[1], chain.Load_TOPO_HEIGHT(nil))
+			}
+
 		case strings.ToLower(line) == "checkpoints": // save all knowns block id
 
 			var block_id crypto.Hash
@@ -1017,6 +1099,8 @@ func usage(w io.Writer) {
 	io.WriteString(w, "\t\033[1mpeer_list\033[0m\tPrint peer list\n")
 	io.WriteString(w, "\t\033[1msc_value\033[0m\tPrint sc balance and stored <scid> <key>\n")
 	io.WriteString(w, "\t\033[1msync_info\033[0m\tPrint information about connected peers and their state\n")
+	io.WriteString(w, "\t\033[1mexport_snapshot\033[0m\tExport chain till topoheight to a snapshot file, export_snapshot <topoheight> <filename>\n")
+	io.WriteString(w, "\t\033[1mimport_snapshot\033[0m\tBootstrap a fresh chain from a snapshot file, import_snapshot <filename>\n")
 	io.WriteString(w, "\t\033[1mbye\033[0m\t\tQuit the daemon\n")
 	io.WriteString(w, "\t\033[1mban\033[0m\t\tBan specific ip from making any connections\n")
 	io.WriteString(w, "\t\033[1munban\033[0m\t\tRevoke restrictions on previously banned ips\n")
@@ -1056,6 +1140,8 @@ var completer = readline.NewPrefixCompleter(
 	readline.PcItem("diff"),
 	readline.PcItem("dev_verify_pool"),
 	readline.PcItem("dev_verify_chain_doublespend"),
+	readline.PcItem("export_snapshot"),
+	readline.PcItem("import_snapshot"),
 	readline.PcItem("mempool_flush"),
 	readline.PcItem("mempool_delete_tx"),
 	readline.PcItem("mempool_print"),
@@ -0,0 +1,390 @@
+package main
+
+// this file implements a step debugger for DVM BASIC contracts
+// execution runs in a goroutine, which blocks within Line_Hook whenever a breakpoint is hit or stepping
+// storage is simulated using the RAM store, changes are committed to it only if entrypoint returns 0
+import "fmt"
+import "io"
+import "os"
+import "sort"
+import "strings"
+import "io/ioutil"
+
+import "github.com/chzyer/readline"
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/address"
+
+var debugger_help = `commands
+	run <Function> [param=value ...]   start executing function, value param is filled from 'set value'
+	break <Function> [line]            break at function entry or at line
+	delete <Function> [line]           remove breakpoint
+	breaks                             list breakpoints
+	step | s                           execute single line
+	continue | c                       continue till next breakpoint
+	locals | l                         show local variables
+	list                               show current line
+	storage                            show storage, including uncommitted changes of running function
+	set scid|blid|txid <hex>           simulate blockchain inputs
+	set height|topoheight|value <n>
+	set signer <address>
+	inputs                             show simulated blockchain inputs
+	help
+	exit`
+
+// where execution stopped, or its result
+type debug_event struct {
+	scid     crypto.Key
+	function string
+	ip       uint64
+	locals   map[string]dvm.Variable
+
+	done   bool
+	result dvm.Variable
+	err    error
+}
+
+type debugger struct {
+	sc          dvm.SmartContract
+	source      []string
+	breakpoints map[string]bool // Function or Function:line
+	inputs      dvm.Blockchain_Input
+	value       uint64 // DERO sent with call
+
+	running  bool
+	stepping bool            // only accessed by execution goroutine while running
+	tx_store *dvm.TX_Storage // store of running function
+	current  debug_event
+	events   chan debug_event
+	resume   chan bool // true to stop at next line
+}
+
+func breakpoint_key(function string, ip uint64) string {
+	if ip == 0 {
+		return function
+	}
+	return fmt.Sprintf("%s:%d", function, ip)
+}
+
+// loads SC from file, RAM store is cleared
+func new_debugger(file string) (*debugger, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, pos, err := dvm.ParseSmartContract(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s %s", file, strings.TrimPrefix(pos, "code:"), err)
+	}
+
+	d := &debugger{sc: sc, source: strings.Split(string(data), "\n"), breakpoints: map[string]bool{},
+		events: make(chan debug_event), resume: make(chan bool)}
+	d.inputs = dvm.Blockchain_Input{BL_HEIGHT: 5, BL_TOPOHEIGHT: 9, SCID: crypto.Identity, BLID: crypto.Identity, TXID: crypto.Identity}
+	dvm.Memory_Backend.Keys = map[dvm.DataKey]dvm.Variable{}
+	return d, nil
+}
+
+func debug(file string) error {
+	d, err := new_debugger(file)
+	if err != nil {
+		return err
+	}
+	global_sc = d.sc
+
+	l, err := readline.NewEx(&readline.Config{
+		Prompt:          "\033[92mDVM DEBUG:\033[32m>>>\033[0m ",
+		AutoComplete:    dummy_autocomplete,
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+
+		HistorySearchFold:   true,
+		FuncFilterInputRune: filterInput,
+	})
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	fmt.Printf("%s\n", debugger_help)
+	for {
+		line, err := l.Readline()
+		if err == readline.ErrInterrupt || err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.EqualFold(fields[0], "exit") {
+			break
+		}
+		if err := d.command(fields); err != nil {
+			fmt.Printf("err %s\n", err)
+		}
+	}
+	return nil
+}
+
+func (d *debugger) command(fields []string) error {
+	command := strings.ToLower(fields[0])
+	args := fields[1:]
+
+	switch command {
+	case "help":
+		fmt.Printf("%s\n", debugger_help)
+
+	case "break", "delete":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("usage: %s <Function> [line]", command)
+		}
+		function, ok := d.sc.Functions[args[0]]
+		if !ok {
+			return fmt.Errorf("function \"%s\" not found", args[0])
+		}
+		var ip uint64
+		if len(args) == 2 {
+			if _, err := fmt.Sscan(args[1], &ip); err != nil {
+				return fmt.Errorf("invalid line \"%s\"", args[1])
+			}
+			if _, ok := function.Lines[ip]; !ok {
+				return fmt.Errorf("function \"%s\" does not have line %d", args[0], ip)
+			}
+		}
+		if command == "break" {
+			d.breakpoints[breakpoint_key(args[0], ip)] = true
+		} else {
+			delete(d.breakpoints, breakpoint_key(args[0], ip))
+		}
+
+	case "breaks":
+		var keys []string
+		for k := range d.breakpoints {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("%s\n", k)
+		}
+
+	case "run":
+		if d.running {
+			return fmt.Errorf("already running, continue till it finishes")
+		}
+		if len(args) < 1 {
+			return fmt.Errorf("usage: run <Function> [param=value ...]")
+		}
+		params := map[string]interface{}{"value": fmt.Sprintf("%d", d.value)}
+		for _, arg := range args[1:] {
+			kv := strings.SplitN(arg, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid parameter \"%s\", expected param=value", arg)
+			}
+			params[kv[0]] = kv[1]
+		}
+		d.start(args[0], params)
+		d.wait()
+
+	case "step", "s", "continue", "c":
+		if !d.running {
+			return fmt.Errorf("not running")
+		}
+		d.resume <- command == "step" || command == "s"
+		d.wait()
+
+	case "locals", "l":
+		if !d.running {
+			return fmt.Errorf("not running")
+		}
+		var names []string
+		for name := range d.current.locals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			v := d.current.locals[name]
+			fmt.Printf("%s %s = %v\n", name, v.Type, v.Value)
+		}
+
+	case "list":
+		if !d.running {
+			return fmt.Errorf("not running")
+		}
+		d.print_location()
+
+	case "storage":
+		d.print_storage()
+
+	case "inputs":
+		fmt.Printf("SCID %s\nBLID %s\nTXID %s\nheight %d topoheight %d\nsigner %s\nvalue %d\n", d.inputs.SCID, d.inputs.BLID,
+			d.inputs.TXID, d.inputs.BL_HEIGHT, d.inputs.BL_TOPOHEIGHT, d.inputs.Signer.String(), d.value)
+
+	case "set":
+		if d.running {
+			return fmt.Errorf("inputs cannot be changed while running")
+		}
+		return d.set(args)
+
+	default:
+		return fmt.Errorf("unknown command \"%s\", type help", command)
+	}
+	return nil
+}
+
+func (d *debugger) set(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: set <input> <value>")
+	}
+	switch strings.ToLower(args[0]) {
+	case "scid", "blid", "txid":
+		if len(args[1]) != 64 {
+			return fmt.Errorf("expected 64 hex characters")
+		}
+		key := crypto.HexToKey(args[1])
+		switch strings.ToLower(args[0]) {
+		case "scid":
+			d.inputs.SCID = key
+		case "blid":
+			d.inputs.BLID = key
+		case "txid":
+			d.inputs.TXID = key
+		}
+	case "height", "topoheight", "value":
+		var n uint64
+		if _, err := fmt.Sscan(args[1], &n); err != nil {
+			return fmt.Errorf("invalid number \"%s\"", args[1])
+		}
+		switch strings.ToLower(args[0]) {
+		case "height":
+			d.inputs.BL_HEIGHT = n
+		case "topoheight":
+			d.inputs.BL_TOPOHEIGHT = n
+		case "value":
+			d.value = n
+		}
+	case "signer":
+		addr, err := address.NewAddress(args[1])
+		if err != nil {
+			return err
+		}
+		d.inputs.Signer = *addr
+	default:
+		return fmt.Errorf("unknown input \"%s\"", args[0])
+	}
+	return nil
+}
+
+// starts execution in background, it stops at first breakpoint
+func (d *debugger) start(entrypoint string, params map[string]interface{}) {
+	// SC has a balance in RAM store, so as DERO can be received
+	var found uint64
+	if dvm.Memory_Backend.Load(dvm.GetBalanceKey(d.inputs.SCID), &found); found == 0 {
+		dvm.Memory_Backend.Store(dvm.GetBalanceKey(d.inputs.SCID), dvm.Variable{Type: dvm.Uint64, Value: uint64(0)})
+	}
+
+	d.tx_store = dvm.Initialize_TX_store()
+	d.tx_store.DiskLoader = dvm.Memory_Backend.Load
+	d.tx_store.SCLoader = func(scid crypto.Key) (dvm.SmartContract, bool) {
+		return d.sc, scid == d.inputs.SCID
+	}
+
+	inputs := d.inputs
+	state := &dvm.Shared_State{Chain_inputs: &inputs, Store: d.tx_store, DERO_Received: d.value, Line_Hook: d.hook, Output: os.Stdout}
+
+	d.running = true
+	d.stepping = false
+	go func() {
+		tx_store := d.tx_store
+		event := debug_event{done: true}
+		defer func() {
+			if r := recover(); r != nil { // balance handling panics outside interpreter
+				event.err = fmt.Errorf("Recovered while running SC %v", r)
+			}
+			d.events <- event
+		}()
+		tx_store.Balance(inputs.SCID)
+		tx_store.ReceiveInternal(inputs.SCID, d.value)
+		event.result, event.err = dvm.RunSmartContract(&d.sc, entrypoint, state, params)
+		if event.err == nil && event.result.Type == dvm.Uint64 && event.result.Value.(uint64) == 0 {
+			commit(tx_store)
+		}
+	}()
+}
+
+// called by interpreter before every line
+func (d *debugger) hook(scid crypto.Key, function string, ip uint64, locals map[string]dvm.Variable) {
+	entry := false
+	if f, ok := d.sc.Functions[function]; ok && len(f.LineNumbers) > 0 && f.LineNumbers[0] == ip {
+		entry = d.breakpoints[breakpoint_key(function, 0)]
+	}
+	if d.stepping || entry || d.breakpoints[breakpoint_key(function, ip)] {
+		d.events <- debug_event{scid: scid, function: function, ip: ip, locals: locals}
+		d.stepping = <-d.resume
+	}
+}
+
+// waits till execution stops or finishes
+func (d *debugger) wait() {
+	d.current = <-d.events
+	if !d.current.done {
+		d.print_location()
+		return
+	}
+
+	d.running = false
+	if d.current.err != nil {
+		fmt.Printf("execution failed err %s, changes discarded\n", d.current.err)
+	} else if d.current.result.Type == dvm.Uint64 && d.current.result.Value.(uint64) == 0 {
+		fmt.Printf("returned %v, changes committed\n", d.current.result.Value)
+	} else {
+		fmt.Printf("returned %v, changes discarded\n", d.current.result.Value)
+	}
+}
+
+func (d *debugger) print_location() {
+	source := ""
+	if f, ok := d.sc.Functions[d.current.function]; ok {
+		if n := f.Source_Lines[d.current.ip]; n >= 1 && n <= len(d.source) {
+			source = strings.TrimSpace(d.source[n-1])
+		}
+	}
+	fmt.Printf("%s:%d  %s\n", d.current.function, d.current.ip, source)
+}
+
+// changes are written to RAM store in the same order as blockchain would
+func commit(tx_store *dvm.TX_Storage) {
+	for _, atom := range tx_store.Atoms {
+		dvm.Memory_Backend.Store(atom.Key, atom.Value)
+	}
+	for scid := range tx_store.Transfers {
+		dvm.Memory_Backend.Store(dvm.GetBalanceKey(scid), dvm.Variable{Type: dvm.Uint64, Value: tx_store.Balance(scid)})
+	}
+}
+
+func (d *debugger) print_storage() {
+	print_keys := func(keys map[dvm.DataKey]dvm.Variable) {
+		var lines []string
+		for k, v := range keys {
+			name := fmt.Sprintf("%v", k.Key.Value)
+			if k.Collection != dvm.Invalid {
+				name = fmt.Sprintf("%s %s[%v]", k.Collection, k.Name, k.Key.Value)
+			}
+			lines = append(lines, fmt.Sprintf("%s %s = %v", k.SCID, name, v.Value))
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			fmt.Printf("%s\n", line)
+		}
+	}
+
+	fmt.Printf("committed\n")
+	print_keys(dvm.Memory_Backend.Keys)
+	if d.running {
+		fmt.Printf("uncommitted\n")
+		print_keys(d.tx_store.Keys)
+	}
+}
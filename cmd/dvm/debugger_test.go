@@ -0,0 +1,78 @@
+package main
+
+import "strings"
+import "testing"
+
+// commands are executed in order against factorial.bas, every command is checked for where execution is afterwards
+func Test_Debugger(t *testing.T) {
+	d, err := new_debugger("factorial.bas")
+	if err != nil {
+		t.Fatalf("factorial.bas could not be loaded err %s", err)
+	}
+
+	tests := []struct {
+		command  string
+		err      bool   // command must fail
+		running  bool   // execution is stopped at function:ip, otherwise it finished with result
+		function string // where execution is stopped
+		ip       uint64
+		local    string // local variable checked while stopped
+		value    uint64
+		finished bool // execution finished by command
+		failed   bool // execution finished with error, otherwise with result
+		result   uint64
+	}{
+		{command: "step", err: true},
+		{command: "break Missing", err: true},
+		{command: "break Factorial 11", err: true},
+		{command: "break Factorial 30"},
+		{command: "run Factorial input=4", running: true, function: "Factorial", ip: 30, local: "result", value: 1},
+		{command: "step", running: true, function: "Factorial", ip: 40, local: "result", value: 4},
+		{command: "s", running: true, function: "Factorial", ip: 50, local: "input", value: 3},
+		{command: "continue", running: true, function: "Factorial", ip: 30, local: "input", value: 3},
+		{command: "run Factorial input=2", err: true, running: true, function: "Factorial", ip: 30},
+		{command: "set height 7", err: true, running: true, function: "Factorial", ip: 30},
+		{command: "locals", running: true, function: "Factorial", ip: 30},
+		{command: "delete Factorial 30", running: true, function: "Factorial", ip: 30},
+		{command: "continue", finished: true, result: 24},
+
+		// entry breakpoint stops at every recursive call
+		{command: "break Factorial_recursive"},
+		{command: "run Factorialr input=3", running: true, function: "Factorial_recursive", ip: 10, local: "input", value: 3},
+		{command: "c", running: true, function: "Factorial_recursive", ip: 10, local: "input", value: 2},
+		{command: "delete Factorial_recursive", running: true, function: "Factorial_recursive", ip: 10},
+		{command: "c", finished: true, result: 6},
+
+		{command: "run Factorial_for input=5", finished: true, result: 120},
+		{command: "run Factorial", finished: true, failed: true}, // input is missing
+		{command: "set value x", err: true},
+		{command: "unknown", err: true},
+	}
+
+	for i, test := range tests {
+		err := d.command(strings.Fields(test.command))
+		if (err != nil) != test.err {
+			t.Fatalf("%d %s: unexpected err %v", i, test.command, err)
+		}
+		if d.running != test.running {
+			t.Fatalf("%d %s: running %t expected %t", i, test.command, d.running, test.running)
+		}
+		if test.running {
+			if d.current.function != test.function || d.current.ip != test.ip {
+				t.Fatalf("%d %s: stopped at %s:%d expected %s:%d", i, test.command, d.current.function, d.current.ip, test.function, test.ip)
+			}
+			if test.local != "" && d.current.locals[test.local].Value != test.value {
+				t.Fatalf("%d %s: %s = %v expected %d", i, test.command, test.local, d.current.locals[test.local].Value, test.value)
+			}
+			continue
+		}
+		if test.finished {
+			if !d.current.done || (d.current.err != nil) != test.failed {
+				t.Fatalf("%d %s: unexpected finish %+v", i, test.command, d.current)
+			}
+			if !test.failed && d.current.result.Value != test.result {
+				t.Fatalf("%d %s: result %v expected %d", i, test.command, d.current.result.Value, test.result)
+			}
+		}
+	}
+}
@@ -13,6 +13,16 @@
 	70  RETURN result
 	End Function
 
+/* iterative implementation of factorial using FOR loop */
+	Function Factorial_for(input Uint64) Uint64
+	10  dim result,i as Uint64
+	20  LET result = 1
+	30  FOR i = 2 TO input
+	40  LET result = result * i
+	50  NEXT i
+	60  RETURN result
+	End Function
+
 /* recursive implementation of factorial */
 	Function Factorial_recursive(input Uint64) Uint64
 	10  IF input == 1 THEN GOTO 20 ELSE GOTO 30
@@ -71,6 +71,7 @@ func debug_run(sc *dvm.SmartContract, code string) {
 	state := &dvm.Shared_State{
 		Chain_inputs: &dvm.Blockchain_Input{BL_HEIGHT: 5, BL_TOPOHEIGHT: 9, SCID: crypto.Identity,
 			BLID: crypto.Identity, TXID: crypto.Identity},
+		Output: os.Stdout,
 	}
 
 	_, err = dvm.RunSmartContract(sc, "REPL", state, map[string]interface{}{})
@@ -83,11 +84,46 @@ func debug_run(sc *dvm.SmartContract, code string) {
 
 }
 
+// lints all files, diagnostics are printed as file:line: message
+func lint(files []string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("usage: dvm lint <source file>...")
+	}
+
+	problems := 0
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		for _, d := range dvm.Lint(string(data)) {
+			fmt.Printf("%s:%s\n", file, d)
+			problems++
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+	return nil
+}
+
 func run() error {
 	var data []byte
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		return lint(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		return run_tests(os.Stdout, os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "debug" {
+		if len(os.Args) != 3 {
+			return fmt.Errorf("usage: dvm debug <source file>")
+		}
+		return debug(os.Args[2])
+	}
 	if len(os.Args) > 1 {
 		if strings.HasPrefix(os.Args[1], "-") {
-			return fmt.Errorf("usage: dvm [<source file>]")
+			return fmt.Errorf("usage: dvm [<source file>] | dvm lint <source file>... | dvm debug <source file> | dvm test <source file> <scenario file>")
 		}
 		f, err := os.Open(os.Args[1])
 		if err != nil {
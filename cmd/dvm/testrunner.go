@@ -0,0 +1,270 @@
+package main
+
+// this file implements a test runner for DVM BASIC contracts
+// a scenario is a json file containing calls which are executed in order against the RAM store
+// every call may check return value, storage, SC balance and external transfers after the call
+//	{
+//	  "scid": "optional 64 hex chars",
+//	  "steps": [
+//	    {"name": "install", "function": "Initialize", "signer": "address", "value": 0, "height": 10, "topoheight": 10,
+//	     "params": {"name": "value"}, "return": 0, "error": false,
+//	     "storage": {"key": "value", "#5": 7, "missing": null}, "balance": 0,
+//	     "transfers": [{"address": "address", "amount": 100}]}
+//	  ]
+//	}
+// storage keys are strings, keys starting with # are Uint64, null expects key to not exist
+// changes are committed only if function returns 0, otherwise attached value is refunded to signer, same as blockchain
+// refund is checked as a transfer to signer
+import "fmt"
+import "io"
+import "sort"
+import "bytes"
+import "strconv"
+import "strings"
+import "io/ioutil"
+import "encoding/json"
+
+import "github.com/deroproject/derosuite/dvm"
+import "github.com/deroproject/derosuite/crypto"
+import "github.com/deroproject/derosuite/address"
+
+type test_transfer struct {
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"`
+}
+
+type test_step struct {
+	Name       string                 `json:"name"`
+	Function   string                 `json:"function"`
+	Params     map[string]string      `json:"params"`
+	Signer     string                 `json:"signer"`
+	Value      uint64                 `json:"value"`
+	Height     uint64                 `json:"height"`
+	TopoHeight uint64                 `json:"topoheight"`
+	Return     interface{}            `json:"return"` // not checked if missing
+	Error      bool                   `json:"error"`  // whether call is expected to fail
+	Storage    map[string]interface{} `json:"storage"`
+	Balance    *uint64                `json:"balance"`
+	Transfers  []test_transfer        `json:"transfers"` // checked if present
+}
+
+type test_scenario struct {
+	SCID  string      `json:"scid"`
+	Steps []test_step `json:"steps"`
+}
+
+// lines executed per function
+type coverage map[string]map[uint64]bool
+
+// results and coverage are written to out
+func run_tests(out io.Writer, files []string) error {
+	if len(files) != 2 {
+		return fmt.Errorf("usage: dvm test <source file> <scenario file>")
+	}
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+	sc, pos, err := dvm.ParseSmartContract(string(data))
+	if err != nil {
+		return fmt.Errorf("%s:%s %s", files[0], strings.TrimPrefix(pos, "code:"), err)
+	}
+
+	scenario_data, err := ioutil.ReadFile(files[1])
+	if err != nil {
+		return err
+	}
+	var scenario test_scenario
+	decoder := json.NewDecoder(bytes.NewReader(scenario_data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&scenario); err != nil {
+		return fmt.Errorf("%s: %s", files[1], err)
+	}
+
+	scid := crypto.Identity
+	if scenario.SCID != "" {
+		if len(scenario.SCID) != 64 {
+			return fmt.Errorf("scid must be 64 hex characters")
+		}
+		scid = crypto.HexToKey(scenario.SCID)
+	}
+
+	dvm.Memory_Backend.Keys = map[dvm.DataKey]dvm.Variable{}
+	dvm.Memory_Backend.Store(dvm.GetBalanceKey(scid), dvm.Variable{Type: dvm.Uint64, Value: uint64(0)})
+
+	covered := coverage{}
+	failed := 0
+	for i, step := range scenario.Steps {
+		name := step.Name
+		if name == "" {
+			name = step.Function
+		}
+		if problems := run_step(&sc, scid, step, covered); len(problems) > 0 {
+			failed++
+			fmt.Fprintf(out, "FAIL  %d %s\n", i+1, name)
+			for _, problem := range problems {
+				fmt.Fprintf(out, "      %s\n", problem)
+			}
+		} else {
+			fmt.Fprintf(out, "PASS  %d %s\n", i+1, name)
+		}
+	}
+
+	print_coverage(out, &sc, covered)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", failed, len(scenario.Steps))
+	}
+	return nil
+}
+
+// executes a step and returns all expectations which were not met
+func run_step(sc *dvm.SmartContract, scid crypto.Key, step test_step, covered coverage) (problems []string) {
+	inputs := dvm.Blockchain_Input{SCID: scid, BLID: crypto.Identity, TXID: crypto.Identity, BL_HEIGHT: step.Height, BL_TOPOHEIGHT: step.TopoHeight}
+	if step.Signer != "" {
+		addr, err := address.NewAddress(step.Signer)
+		if err != nil {
+			return []string{fmt.Sprintf("signer address could not be parsed err %s", err)}
+		}
+		inputs.Signer = *addr
+	}
+
+	params := map[string]interface{}{"value": fmt.Sprintf("%d", step.Value)}
+	for k, v := range step.Params {
+		params[k] = v
+	}
+
+	tx_store := dvm.Initialize_TX_store()
+	tx_store.DiskLoader = dvm.Memory_Backend.Load
+	tx_store.SCLoader = func(id crypto.Key) (dvm.SmartContract, bool) {
+		return *sc, id == scid
+	}
+	state := &dvm.Shared_State{Chain_inputs: &inputs, Store: tx_store, DERO_Received: step.Value}
+	state.Line_Hook = func(id crypto.Key, function string, ip uint64, locals map[string]dvm.Variable) {
+		if id != scid {
+			return
+		}
+		if covered[function] == nil {
+			covered[function] = map[uint64]bool{}
+		}
+		covered[function][ip] = true
+	}
+
+	tx_store.Balance(scid)
+	tx_store.ReceiveInternal(scid, step.Value)
+	result, err := dvm.RunSmartContract(sc, step.Function, state, params)
+
+	committed := err == nil && result.Type == dvm.Uint64 && result.Value.(uint64) == 0
+	if committed {
+		commit(tx_store)
+	}
+
+	switch {
+	case step.Error && err == nil:
+		problems = append(problems, fmt.Sprintf("expected error, returned %v", result.Value))
+	case !step.Error && err != nil:
+		problems = append(problems, fmt.Sprintf("execution failed err %s", err))
+	case err == nil && step.Return != nil && !equal_value(step.Return, result.Value):
+		problems = append(problems, fmt.Sprintf("return expected %v actual %v", step.Return, result.Value))
+	}
+
+	var keys []string
+	for k := range step.Storage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := dvm.Variable{Type: dvm.String, Value: k}
+		if strings.HasPrefix(k, "#") {
+			n, err := strconv.ParseUint(k[1:], 10, 64)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("storage key \"%s\" is not a valid Uint64", k))
+				continue
+			}
+			key = dvm.Variable{Type: dvm.Uint64, Value: n}
+		}
+
+		var found uint64
+		actual := dvm.Memory_Backend.Load(dvm.DataKey{SCID: scid, Key: key}, &found)
+		expected := step.Storage[k]
+		switch {
+		case expected == nil && found != 0:
+			problems = append(problems, fmt.Sprintf("storage \"%s\" expected to not exist, actual %v", k, actual.Value))
+		case expected != nil && found == 0:
+			problems = append(problems, fmt.Sprintf("storage \"%s\" expected %v, key does not exist", k, expected))
+		case expected != nil && !equal_value(expected, actual.Value):
+			problems = append(problems, fmt.Sprintf("storage \"%s\" expected %v actual %v", k, expected, actual.Value))
+		}
+	}
+
+	if step.Balance != nil && balance(scid) != *step.Balance {
+		problems = append(problems, fmt.Sprintf("balance expected %d actual %d", *step.Balance, balance(scid)))
+	}
+
+	if step.Transfers != nil {
+		var actual []test_transfer
+		if committed {
+			for _, t := range tx_store.Transfers[scid].TransferE {
+				actual = append(actual, test_transfer{Address: t.Address, Amount: t.Amount})
+			}
+		} else if step.Value > 0 { // value does not reach SC
+			actual = append(actual, test_transfer{Address: step.Signer, Amount: step.Value})
+		}
+		if fmt.Sprintf("%v", actual) != fmt.Sprintf("%v", step.Transfers) {
+			problems = append(problems, fmt.Sprintf("transfers expected %v actual %v", step.Transfers, actual))
+		}
+	}
+	return
+}
+
+func balance(scid crypto.Key) uint64 {
+	var found uint64
+	return dvm.Memory_Backend.Load(dvm.GetBalanceKey(scid), &found).Value.(uint64)
+}
+
+// compares a value from json with a DVM value
+func equal_value(expected interface{}, actual interface{}) bool {
+	switch e := expected.(type) {
+	case json.Number:
+		n, err := strconv.ParseUint(e.String(), 10, 64)
+		return err == nil && actual == n
+	case string:
+		return actual == e
+	}
+	return false
+}
+
+func print_coverage(out io.Writer, sc *dvm.SmartContract, covered coverage) {
+	var names []string
+	for name := range sc.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(out, "coverage\n")
+	for _, name := range names {
+		f := sc.Functions[name]
+		lines, hit := 0, 0
+		var missed []string
+		for _, ip := range f.LineNumbers {
+			if len(f.Lines[ip]) == 0 { // lines with only line number are never executed
+				continue
+			}
+			lines++
+			if covered[name][ip] {
+				hit++
+			} else {
+				missed = append(missed, fmt.Sprintf("%d", ip))
+			}
+		}
+		percent := 100.0
+		if lines > 0 {
+			percent = float64(hit) * 100 / float64(lines)
+		}
+		fmt.Fprintf(out, "%-30s %3d/%-3d lines %6.2f%%", name, hit, lines, percent)
+		if len(missed) > 0 {
+			fmt.Fprintf(out, "  not executed %s", strings.Join(missed, ","))
+		}
+		fmt.Fprintf(out, "\n")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import "os"
+import "bytes"
+import "strings"
+import "testing"
+import "io/ioutil"
+import "path/filepath"
+
+// runs scenario against lottery.bas, returns output and error
+func run_test_scenario(t *testing.T, scenario string) (string, error) {
+	var out bytes.Buffer
+	err := run_tests(&out, []string{"lottery.bas", scenario})
+	return out.String(), err
+}
+
+func Test_Runner_Pass(t *testing.T) {
+	output, err := run_test_scenario(t, "lottery_test.json")
+	if err != nil {
+		t.Fatalf("lottery_test.json failed err %s\n%s", err, output)
+	}
+	for _, expected := range []string{"PASS  1 install\n", "PASS  2 first deposit waits for second player\n", "PASS  3 second deposit pays winner\n",
+		"PASS  4 only owner can withdraw, value is refunded\n", "coverage\n"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("output does not contain %q\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "FAIL") {
+		t.Fatalf("no step must fail\n%s", output)
+	}
+
+	// function name => expected coverage line suffix
+	coverage := map[string]string{
+		"Initialize":        "7/7   lines 100.00%",
+		"Lottery":           "12/12  lines 100.00%",
+		"Withdraw":          "2/4   lines  50.00%  not executed 30,40",
+		"TransferOwnership": "0/4   lines   0.00%  not executed 10,20,30,40",
+	}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if expected, ok := coverage[fields[0]]; ok {
+			if !strings.HasSuffix(line, expected) {
+				t.Fatalf("coverage of %s expected %q actual %q", fields[0], expected, line)
+			}
+			delete(coverage, fields[0])
+		}
+	}
+	if len(coverage) != 0 {
+		t.Fatalf("coverage missing for %v\n%s", coverage, output)
+	}
+}
+
+func Test_Runner_Fail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvmtest")
+	if err != nil {
+		t.Fatalf("cannot create temp dir err %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	scenario := filepath.Join(dir, "failing_test.json")
+	// owner and signer are both empty, so withdraw succeeds although failure is expected
+	data := `{"steps": [
+		{"name": "install", "function": "Initialize", "return": 0},
+		{"name": "deposit", "function": "Lottery", "value": 100, "return": 0, "storage": {"deposit_count": 5, "missing": null}, "balance": 999},
+		{"name": "withdraw", "function": "Withdraw", "params": {"amount": "1"}, "error": true}
+	]}`
+	if err = ioutil.WriteFile(scenario, []byte(data), 0600); err != nil {
+		t.Fatalf("cannot write scenario err %s", err)
+	}
+
+	output, err := run_test_scenario(t, scenario)
+	if err == nil || err.Error() != "2 of 3 steps failed" {
+		t.Fatalf("failing scenario must fail err %v\n%s", err, output)
+	}
+	for _, expected := range []string{"PASS  1 install\n", "FAIL  2 deposit\n", "storage \"deposit_count\" expected 5 actual 1\n",
+		"balance expected 999 actual 100\n", "FAIL  3 withdraw\n", "expected error, returned 0\n", "coverage\n"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("output does not contain %q\n%s", expected, output)
+		}
+	}
+
+	if _, err = run_test_scenario(t, filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatalf("missing scenario must fail")
+	}
+	if err = run_tests(ioutil.Discard, []string{"lottery.bas"}); err == nil {
+		t.Fatalf("scenario file is required")
+	}
+}
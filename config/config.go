@@ -82,6 +82,9 @@ const MAX_MIXIN = 14 // <= 13,   14th will rejected
 // ATLANTIS FEE calculation constants are here
 const FEE_PER_KB = uint64(1000000000) // .001 dero per kb
 
+// SC execution is paid from DERO attached to the SC tx, unused gas is refunded to the signer
+const SC_GAS_PRICE = uint64(10000) // atomic units per unit of gas
+
 // mainnet botstraps at 200 MH
 //const MAINNET_BOOTSTRAP_DIFFICULTY = uint64(200 *  1000* 1000 * BLOCK_TIME)
 const MAINNET_BOOTSTRAP_DIFFICULTY = uint64(200 * 1000 * 1000 * BLOCK_TIME)
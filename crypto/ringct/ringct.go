@@ -147,6 +147,9 @@ type RctSigPrunable struct {
 type RctSig struct {
 	RctSigBase
 	RctSigPrunable
+
+	pruned        bool        // prunable part has been discarded, only its hash is available
+	prunable_hash crypto.Hash // hash of discarded prunable part
 }
 
 func (k *Key64) Serialize() (result []byte) {
@@ -214,7 +217,7 @@ func (r *RctSigBase) BaseHash() (result crypto.Hash) {
 }
 
 func (r *RctSig) SerializePrunable() (result []byte) {
-	if r.sigType == RCTTypeNull {
+	if r.sigType == RCTTypeNull || r.pruned {
 		return
 	}
 	for _, rangeSig := range r.rangeSigs {
@@ -253,10 +256,31 @@ func (r *RctSig) PrunableHash() (result crypto.Hash) {
 	if r.sigType == RCTTypeNull {
 		return
 	}
+	if r.pruned {
+		return r.prunable_hash
+	}
 	result = crypto.Keccak256(r.SerializePrunable())
 	return
 }
 
+// discard the prunable part ( range proofs and mlsag signatures ), only its hash is kept
+// a pruned signature can still be hashed but can never be verified
+func (r *RctSig) Prune() {
+	if r.sigType == RCTTypeNull || r.pruned {
+		return
+	}
+	r.prunable_hash = r.PrunableHash()
+	r.rangeSigs = nil
+	r.BulletSigs = nil
+	r.MlsagSigs = nil
+	r.pruned = true
+}
+
+// whether the prunable part has been discarded
+func (r *RctSig) IsPruned() bool {
+	return r.pruned
+}
+
 // this is the function which should be used by external world
 // if any exceptions occur while handling, we simply return false
 // transaction must be expanded before verification
@@ -272,6 +296,10 @@ func (r *RctSig) Verify() (result bool) {
 		}
 	}()
 
+	if r.pruned { // nothing to verify against
+		return false
+	}
+
 	switch r.sigType {
 	case RCTTypeNull:
 		return false /// this is only possible for miner tx
@@ -457,6 +485,19 @@ func ParseRangeSig(buf io.Reader) (result RangeSig, err error) {
 // parser for ringct signature
 // we need to be extra cautious as almost anything cam come as input
 func ParseRingCtSignature(buf io.Reader, nInputs, nOutputs, nMixin int) (result *RctSig, err error) {
+	return parse_ringct_signature(buf, nInputs, nOutputs, nMixin, false)
+}
+
+// parses a signature whose prunable part has been discarded, prunable hash must be supplied externally
+func ParseRingCtSignaturePruned(buf io.Reader, nInputs, nOutputs int, prunable_hash crypto.Hash) (result *RctSig, err error) {
+	result, err = parse_ringct_signature(buf, nInputs, nOutputs, 0, true)
+	if err == nil && result.sigType != RCTTypeNull {
+		result.prunable_hash = prunable_hash
+	}
+	return
+}
+
+func parse_ringct_signature(buf io.Reader, nInputs, nOutputs, nMixin int, pruned bool) (result *RctSig, err error) {
 	r := new(RctSig)
 	sigType := make([]byte, 1)
 	_, err = buf.Read(sigType)
@@ -538,6 +579,12 @@ func ParseRingCtSignature(buf io.Reader, nInputs, nOutputs, nMixin int) (result
 		}
 	}
 
+	if pruned { // everything below is prunable
+		r.pruned = true
+		result = r
+		return
+	}
+
 	switch r.sigType {
 	case RCTTypeFull, RCTTypeSimple:
 		r.rangeSigs = make([]RangeSig, nOutputs)
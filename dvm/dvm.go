@@ -17,6 +17,7 @@
 package dvm
 
 import "fmt"
+import "io"
 import "text/scanner"
 import "strings"
 import "strconv"
@@ -26,6 +27,7 @@ import "go/ast"
 import "go/parser"
 import "go/token"
 import "math"
+import "sort"
 
 import "runtime/debug"
 import "../crypto"
@@ -43,6 +45,9 @@ const (
 	// this should be depreceated
 	//ID                   // represents a BLID, TXID, etc
 	Address // a DERO address
+
+	Map   // persistent map, only available in SC storage, see dvm_collections.go
+	Array // persistent array, only available in SC storage
 )
 
 var replacer = strings.NewReplacer("< =", "<=", "> =", ">=", "= =", "==", "! =", "!=", "& &", "&&", "| |", "||", "< <", "<<", "> >", ">>", "< >", "!=")
@@ -60,16 +65,17 @@ type Variable struct {
 }
 
 type Function struct {
-	Name        string         `msgpack:"N,omitempty" json:"N,omitempty"`
-	Params      []Variable     `msgpack:"P,omitempty" json:"P,omitempty"`
-	ReturnValue Variable       `msgpack:"R,omitempty" json:"R,omitempty"`
-	Lines       []Line         `msgpack:"L,omitempty" json:"L,omitempty"`
-	LabelMap    map[string]int `msgpack:"M,omitempty" json:"M,omitempty"`
-}
-
-type Line struct {
-	Label string `msgpack:"L,omitempty" json:"L,omitempty'`
-	Code  string `msgpack:"L,omitempty" json:"L,omitempty'`
+	Name        string              `msgpack:"N,omitempty" json:"N,omitempty"`
+	Params      []Variable          `msgpack:"P,omitempty" json:"P,omitempty"`
+	ReturnValue Variable            `msgpack:"R,omitempty" json:"R,omitempty"`
+	Lines       map[uint64][]string `msgpack:"L,omitempty" json:"L,omitempty"` // line number => tokens
+	LineNumbers []uint64            `msgpack:"I,omitempty" json:"I,omitempty"` // line numbers in ascending order, used to fall through
+	Blocks      map[uint64]uint64   `msgpack:"B,omitempty" json:"B,omitempty"` // line => matching line of FOR/NEXT, WHILE/WEND, IF/ELSE/END IF
+
+	// positions within source code, only available after parsing, these are never serialized
+	Source_Line  int            `msgpack:"-" json:"-"` // line of function declaration
+	Source_End   int            `msgpack:"-" json:"-"` // line of End Function
+	Source_Lines map[uint64]int `msgpack:"-" json:"-"` // line number => line in source code
 }
 
 const LIMIT_interpreted_lines = 2000 // testnet has hardcoded limit
@@ -104,7 +110,6 @@ func ParseSmartContract(src_code string) (SC SmartContract, pos string, err erro
 	SC.Functions = map[string]Function{}
 
 	for tok := s.Scan(); tok != scanner.EOF; tok = s.Scan() {
-		pos := s.Position.String()
 		txt := s.TokenText()
 
 		if strings.HasPrefix(txt, ";") || strings.HasPrefix(txt, "REM") { // skip  line, if this is the first word
@@ -126,8 +131,8 @@ func ParseSmartContract(src_code string) (SC SmartContract, pos string, err erro
 		if current_line == int32(s.Position.Line) { // collect a complete line
 			line_tokens = append(line_tokens, txt)
 		} else { // if new line found, process previous line
-			if err = parse_function_line(&SC, &current_function, line_tokens); err != nil {
-				return SC, pos, err
+			if err = parse_function_line(&SC, &current_function, line_tokens, int(current_line)); err != nil {
+				return SC, fmt.Sprintf("%s:%d", s.Filename, current_line), err // report line which failed
 			}
 			line_tokens = line_tokens[:0]
 
@@ -138,8 +143,8 @@ func ParseSmartContract(src_code string) (SC SmartContract, pos string, err erro
 	}
 
 	if len(line_tokens) > 0 { // last line  is processed here
-		if err = parse_function_line(&SC, &current_function, line_tokens); err != nil {
-			return SC, pos, err
+		if err = parse_function_line(&SC, &current_function, line_tokens, int(current_line)); err != nil {
+			return SC, fmt.Sprintf("%s:%d", s.Filename, current_line), err
 		}
 	}
 
@@ -161,6 +166,25 @@ func check_valid_name(name string) bool {
 	return unicode.IsLetter(r)
 }
 
+// name of the type, as used in SC source
+func (t Vtype) String() string {
+	switch t {
+	case Uint64:
+		return "Uint64"
+	case String:
+		return "String"
+	case Blob:
+		return "Blob"
+	case Address:
+		return "Address"
+	case Map:
+		return "Map"
+	case Array:
+		return "Array"
+	}
+	return "Invalid"
+}
+
 func check_valid_type(name string) Vtype {
 	switch name {
 	case "Uint64":
@@ -176,7 +200,8 @@ func check_valid_type(name string) Vtype {
 }
 
 // this will parse 1 line at a time, if there is an error, it is returned
-func parse_function_line(SC *SmartContract, function **Function, line []string) (err error) {
+// source_line is the line within source code, it is only recorded for diagnostics
+func parse_function_line(SC *SmartContract, function **Function, line []string, source_line int) (err error) {
 	pos := 0
 	//fmt.Printf("parsing function line %+v\n", line)
 
@@ -249,38 +274,37 @@ func parse_function_line(SC *SmartContract, function **Function, line []string)
 			f.ReturnValue.Type = return_type
 		}
 
+		f.Lines = map[uint64][]string{}
+		f.Source_Line = source_line
+		f.Source_Lines = map[uint64]int{}
 		*function = &f
 		return nil
 	} else if strings.EqualFold(line[pos], "End") && strings.EqualFold(line[pos+1], "Function") {
+		if err = validate_blocks(*function); err != nil {
+			return fmt.Errorf("function \"%s\" %s", (*function).Name, err)
+		}
+		(*function).Source_End = source_line
 		SC.Functions[(*function).Name] = **function
 		*function = nil
 	} else if strings.EqualFold(line[pos], "Function") {
 		return fmt.Errorf("Nested functions are not allowed")
 	} else {
-		possible_line_number := strings.Fields(line[pos])[0]
-
-		_, err := strconv.ParseUint(possible_line_number, 10, 64)
-		label := ""
+		line_number, err := strconv.ParseUint(line[pos], 10, 64)
 		if err != nil {
-			if strings.Contains(possible_line_number, ":") {
-				if (*function).LabelMap[possible_line_number] != 0 { // duplicate line number
-					return fmt.Errorf("Error: duplicate label within function  \"%s\" ", (*function).Name)
-				}
-				label = strings.TrimSuffix(possible_line_number, ":")
-			}
-		} else {
-			label = possible_line_number
+			return fmt.Errorf("Error parsing line number \"%s\" function \"%s\"", line[pos], (*function).Name)
 		}
-		line_copy := line[pos]
-		this_line := Line{}
-		if label != "" {
-			(*function).LabelMap[label] = pos
-			this_line.Label = label
-			this_line.Code = strings.Join(strings.Fields(line_copy)[1:], " ")
-		} else {
-			this_line.Code = line_copy
+		if line_number == 0 || line_number == math.MaxUint64 {
+			return fmt.Errorf("Error: invalid line number %d function \"%s\"", line_number, (*function).Name)
+		}
+
+		// line numbers must be unique and ascending, so as fall through is well defined
+		if count := len((*function).LineNumbers); count >= 1 && line_number <= (*function).LineNumbers[count-1] {
+			return fmt.Errorf("Error: line number %d must be greater than %d function \"%s\"", line_number, (*function).LineNumbers[count-1], (*function).Name)
 		}
-		(*function).Lines[pos] = this_line
+
+		(*function).LineNumbers = append((*function).LineNumbers, line_number)
+		(*function).Lines[line_number] = append([]string{}, line[pos+1:]...) // tokens are reused by caller
+		(*function).Source_Lines[line_number] = source_line
 	}
 
 	return nil
@@ -335,6 +359,10 @@ func runSmartContract_internal(SC *SmartContract, EntryPoint string, state *Shar
 	// all variables have been collected, start interpreter
 	dvm.ReturnValue = dvm.function.ReturnValue // enforce return value to be of same type
 
+	if dvm.State.Monitor_recursion >= LIMIT_recursion {
+		err = fmt.Errorf("recursion limit %d reached while invoking \"%s\"", LIMIT_recursion, EntryPoint)
+		return
+	}
 	dvm.State.Monitor_recursion++ // higher recursion
 
 	err = dvm.interpret_SmartContract()
@@ -352,8 +380,21 @@ func runSmartContract_internal(SC *SmartContract, EntryPoint string, state *Shar
 func RunSmartContract(SC *SmartContract, EntryPoint string, state *Shared_State, params map[string]interface{}) (result Variable, err error) {
 	// if smart contract does not contain function, trigger exception
 
+	defer func() { // runs last, so as recovered errors are also traced
+		if trace := state.trace(); trace != nil {
+			trace.Lines, trace.Evals, trace.Gas_Used = state.Monitor_lines_interpreted, state.Monitor_ops, state.Gas_Used
+			if err != nil {
+				trace.Fail("%s", err)
+			}
+		}
+	}()
+
 	defer func() {
 		if r := recover(); r != nil {
+			if r == ErrOutOfGas { // out of gas is reported as is, so caller can distinguish it
+				err = ErrOutOfGas
+				return
+			}
 			err = fmt.Errorf("Recovered in function %+v", r)
 			//fmt.Printf("%+v ", err)
 			fmt.Sprintf("%s\n", debug.Stack())
@@ -379,6 +420,10 @@ func RunSmartContract(SC *SmartContract, EntryPoint string, state *Shared_State,
 		return result, fmt.Errorf("Invalid function name, First character must be Capital/Upper Case")
 	}
 
+	if state.Gas_Limit > GAS_LIMIT_MAX {
+		return result, fmt.Errorf("Gas limit %d is above maximum %d", state.Gas_Limit, GAS_LIMIT_MAX)
+	}
+
 	// initialize RND
 	if state.Monitor_recursion == 0 {
 		state.RND = Initialize_RND(state.Chain_inputs.SCID, state.Chain_inputs.BLID, state.Chain_inputs.TXID)
@@ -405,7 +450,8 @@ type Blockchain_Input struct {
 	SCID          crypto.Key      // current smart contract which is executing
 	BLID          crypto.Key      // BLID
 	TXID          crypto.Key      // current TXID under which TX
-	Signer        address.Address // address which signed this
+	Signer        address.Address // address which signed this, empty within CALL_SC
+	Caller        crypto.Key      // SC which called the current SC through CALL_SC, zero if called by TX
 	BL_HEIGHT     uint64          // current chain height under which current tx is valid
 	BL_TOPOHEIGHT uint64          // current block topo height which can be used to  uniquely pinpoint the block
 }
@@ -429,10 +475,18 @@ type Shared_State struct {
 	RND   *RND        // this is initialized only once  while invoking entrypoint
 	Store *TX_Storage // mechanism to access a data store, can discard changes
 
-	Monitor_recursion         int64 // used to control recursion amount 64 calls are more than necessary
+	Output io.Writer // PRINT output goes here besides the trace, if nil it is discarded, only set by tools and dry runs
+
+	Monitor_recursion         int64 // used to control recursion amount, across SCs also, limited to LIMIT_recursion
 	Monitor_lines_interpreted int64 // number of lines interpreted
 	Monitor_ops               int64 // number of ops evaluated, for expressions, variables
 
+	Gas_Limit uint64 // gas available to this call, 0 means unmetered
+	Gas_Used  uint64 // gas consumed so far
+
+	// if set, called before every line is interpreted, used by debugger
+	// locals must not be modified, execution continues once hook returns
+	Line_Hook func(scid crypto.Key, function string, ip uint64, locals map[string]Variable)
 }
 
 type DVM_Interpreter struct {
@@ -441,6 +495,7 @@ type DVM_Interpreter struct {
 	EntryPoint  string
 	function    Function
 	IP          uint64              // current line number
+	IP_index    int                 // index of next line within function.LineNumbers
 	ReturnValue Variable            // Result of current function call
 	Locals      map[string]Variable // all local variables
 
@@ -452,13 +507,35 @@ type DVM_Interpreter struct {
 
 	store *TX_Storage // mechanism to access a data store, can discard changes
 
+	loops map[uint64]for_loop // FOR line => loop being executed
 }
 
+// moves to the next line, newip of 0 falls through to the next line, otherwise jumps to the line number
+// lines containing only a line number are skipped
 func (i *DVM_Interpreter) incrementIP(newip uint64) (line []string, err error) {
+	for {
+		if newip == 0 {
+			if i.IP_index >= len(i.function.LineNumbers) {
+				return nil, fmt.Errorf("function \"%s\" reached end without RETURN", i.function.Name)
+			}
+		} else {
+			index := sort.Search(len(i.function.LineNumbers), func(j int) bool { return i.function.LineNumbers[j] >= newip })
+			if index >= len(i.function.LineNumbers) || i.function.LineNumbers[index] != newip {
+				return nil, fmt.Errorf("function \"%s\" line number %d does not exist", i.function.Name, newip)
+			}
+			i.IP_index = index
+			newip = 0
+		}
 
-	i.State.Monitor_lines_interpreted++ // increment line interpreted
-	i.IP++
-	return
+		i.IP = i.function.LineNumbers[i.IP_index]
+		i.IP_index++
+		i.State.Monitor_lines_interpreted++ // increment line interpreted
+		i.State.Consume_Gas(GAS_LINE)
+
+		if line = i.function.Lines[i.IP]; len(line) > 0 {
+			return
+		}
+	}
 }
 
 // this runs a smart contract function with specific params
@@ -474,6 +551,11 @@ func (i *DVM_Interpreter) interpret_SmartContract() (err error) {
 
 		newIP = 0 // this is necessary otherwise, it will trigger an infinite loop in the case given below
 
+		if i.State.Line_Hook != nil {
+			i.State.Line_Hook(i.State.Chain_inputs.SCID, i.function.Name, i.IP, i.Locals)
+		}
+		i.State.trace().line_executed(i.State.Chain_inputs.SCID, i.function.Name, i.IP, line)
+
 		/*
 			                 * Function SetOwner(value Uint64, newowner String) Uint64
 				10  IF LOAD("owner") == SIGNER() THEN GOTO 30
@@ -483,7 +565,7 @@ func (i *DVM_Interpreter) interpret_SmartContract() (err error) {
 				End Function
 		*/
 
-		if i.State.Monitor_lines_interpreted > LIMIT_interpreted_lines {
+		if i.State.Gas_Limit == 0 && i.State.Monitor_lines_interpreted > LIMIT_interpreted_lines {
 			panic(fmt.Sprintf("%d lines interpreted, reached limit %d", LIMIT_interpreted_lines, LIMIT_interpreted_lines))
 		}
 
@@ -497,8 +579,21 @@ func (i *DVM_Interpreter) interpret_SmartContract() (err error) {
 			newIP, err = i.interpret_LET(line[1:])
 		case strings.EqualFold(line[0], "GOTO"):
 			newIP, err = i.interpret_GOTO(line[1:])
+		case is_block_IF(line):
+			newIP, err = i.interpret_block_IF(line[1:])
 		case strings.EqualFold(line[0], "IF"):
 			newIP, err = i.interpret_IF(line[1:])
+		case strings.EqualFold(line[0], "ELSE"):
+			newIP, err = i.interpret_ELSE(line[1:])
+		case is_END_IF(line): // nothing to do
+		case strings.EqualFold(line[0], "FOR"):
+			newIP, err = i.interpret_FOR(line)
+		case strings.EqualFold(line[0], "NEXT"):
+			newIP, err = i.interpret_NEXT(line[1:])
+		case strings.EqualFold(line[0], "WHILE"):
+			newIP, err = i.interpret_WHILE(line[1:])
+		case strings.EqualFold(line[0], "WEND"):
+			newIP, err = i.interpret_WEND(line[1:])
 		case strings.EqualFold(line[0], "RETURN"):
 			newIP, err = i.interpret_RETURN(line[1:])
 
@@ -559,7 +654,14 @@ func (dvm *DVM_Interpreter) interpret_PRINT(args []string) (newIP uint64, err er
 			}
 		}
 
-		_, err = fmt.Printf(strings.Trim(args[0], "\"")+"\n", params...)
+		message := fmt.Sprintf(strings.Trim(args[0], "\""), params...)
+		dvm.State.trace().print(message)
+
+		output := io.Discard
+		if dvm.State.Output != nil {
+			output = dvm.State.Output
+		}
+		_, err = fmt.Fprintln(output, message)
 	}
 	return
 }
@@ -802,8 +904,9 @@ func (dvm *DVM_Interpreter) eval_identifier(exp ast.Expr) string {
 func (dvm *DVM_Interpreter) eval(exp ast.Expr) interface{} {
 
 	dvm.State.Monitor_ops++ // maintain counter
+	dvm.State.Consume_Gas(GAS_EVAL)
 
-	if dvm.State.Monitor_ops > LIMIT_evals {
+	if dvm.State.Gas_Limit == 0 && dvm.State.Monitor_ops > LIMIT_evals {
 		panic(fmt.Sprintf("%d lines interpreted, evals reached limit %d", dvm.State.Monitor_lines_interpreted, LIMIT_evals))
 	}
 
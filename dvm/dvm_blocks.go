@@ -0,0 +1,333 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements structured control flow, FOR/NEXT, WHILE/WEND and block IF
+//	10 FOR i = 1 TO 10 STEP 2        STEP is optional, loop variable must be DIMed as Uint64
+//	20 NEXT i                        loop variable is optional
+//	30 WHILE i < 20
+//	40 WEND
+//	50 IF i == 21 THEN               block IF, THEN is the last word of the line
+//	60 ELSE                          ELSE is optional
+//	70 END IF
+// blocks are matched at parse time, so unterminated or mismatched blocks are rejected before execution
+// every line still has a line number, so GOTO works as before, even into or out of blocks
+import "fmt"
+import "sort"
+import "strings"
+import "go/parser"
+
+// open block while matching terminators
+type open_block struct {
+	kind     string // FOR, WHILE, IF
+	line     uint64
+	variable string // loop variable of FOR
+	else_ip  uint64 // line of ELSE within block IF, 0 if not seen
+}
+
+// FOR loop being executed
+type for_loop struct {
+	variable string
+	end      uint64
+	step     uint64
+}
+
+// whether the line is a block IF, ie IF expr THEN
+func is_block_IF(line []string) bool {
+	return len(line) >= 3 && strings.EqualFold(line[0], "IF") && strings.EqualFold(line[len(line)-1], "THEN")
+}
+
+// whether the line is END IF
+func is_END_IF(line []string) bool {
+	return len(line) == 2 && strings.EqualFold(line[0], "END") && strings.EqualFold(line[1], "IF")
+}
+
+// splits FOR line into variable, start, end and step expressions, step is empty if not provided
+func split_FOR(line []string) (variable, start, end, step string, err error) {
+	if len(line) < 6 || !strings.EqualFold(line[0], "FOR") || line[2] != "=" {
+		err = fmt.Errorf("Invalid FOR syntax, expected FOR variable = start TO end [STEP step]")
+		return
+	}
+	variable = line[1]
+	if !check_valid_name(variable) {
+		err = fmt.Errorf("FOR variable \"%s\" contains invalid characters", variable)
+		return
+	}
+
+	to_pos, step_pos := -1, len(line)
+	for i := 3; i < len(line); i++ {
+		if strings.EqualFold(line[i], "TO") && to_pos == -1 {
+			to_pos = i
+		}
+		if strings.EqualFold(line[i], "STEP") && to_pos != -1 {
+			step_pos = i
+			break
+		}
+	}
+	if to_pos <= 3 || to_pos+1 >= step_pos || step_pos == len(line)-1 {
+		err = fmt.Errorf("Invalid FOR syntax, expected FOR variable = start TO end [STEP step]")
+		return
+	}
+
+	start = strings.Join(line[3:to_pos], " ")
+	end = strings.Join(line[to_pos+1:step_pos], " ")
+	if step_pos < len(line) {
+		step = strings.Join(line[step_pos+1:], " ")
+	}
+
+	for _, expr := range []string{start, end, step} {
+		if expr == "" {
+			continue
+		}
+		if _, err = parser.ParseExpr(replacer.Replace(expr)); err != nil {
+			err = fmt.Errorf("Invalid FOR expression \"%s\" err %s", expr, err)
+			return
+		}
+	}
+	return
+}
+
+// matches block terminators of a function, filling function.Blocks
+// FOR <=> NEXT, WHILE <=> WEND, IF => ELSE or END IF, ELSE => END IF
+func validate_blocks(function *Function) (err error) {
+	function.Blocks = map[uint64]uint64{}
+
+	var stack []open_block
+	top := func(kind string) (block *open_block) {
+		if len(stack) >= 1 && stack[len(stack)-1].kind == kind {
+			block = &stack[len(stack)-1]
+		}
+		return
+	}
+
+	for _, ip := range function.LineNumbers {
+		line := function.Lines[ip]
+		if len(line) == 0 {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(line[0], "FOR"):
+			variable, _, _, _, err := split_FOR(line)
+			if err != nil {
+				return fmt.Errorf("line %d %s", ip, err)
+			}
+			stack = append(stack, open_block{kind: "FOR", line: ip, variable: variable})
+
+		case strings.EqualFold(line[0], "NEXT"):
+			block := top("FOR")
+			if block == nil {
+				return fmt.Errorf("line %d NEXT without FOR", ip)
+			}
+			if len(line) > 2 || (len(line) == 2 && line[1] != block.variable) {
+				return fmt.Errorf("line %d NEXT does not match FOR at line %d", ip, block.line)
+			}
+			function.Blocks[block.line] = ip
+			function.Blocks[ip] = block.line
+			stack = stack[:len(stack)-1]
+
+		case strings.EqualFold(line[0], "WHILE"):
+			if len(line) < 2 {
+				return fmt.Errorf("line %d WHILE expects an expression", ip)
+			}
+			if _, err = parser.ParseExpr(replacer.Replace(strings.Join(line[1:], " "))); err != nil {
+				return fmt.Errorf("line %d Invalid WHILE expression err %s", ip, err)
+			}
+			stack = append(stack, open_block{kind: "WHILE", line: ip})
+
+		case strings.EqualFold(line[0], "WEND"):
+			block := top("WHILE")
+			if block == nil || len(line) != 1 {
+				return fmt.Errorf("line %d WEND without WHILE", ip)
+			}
+			function.Blocks[block.line] = ip
+			function.Blocks[ip] = block.line
+			stack = stack[:len(stack)-1]
+
+		case is_block_IF(line):
+			stack = append(stack, open_block{kind: "IF", line: ip})
+
+		case strings.EqualFold(line[0], "ELSE"):
+			block := top("IF")
+			if block == nil || block.else_ip != 0 || len(line) != 1 {
+				return fmt.Errorf("line %d ELSE without block IF", ip)
+			}
+			block.else_ip = ip
+			function.Blocks[block.line] = ip
+
+		case strings.EqualFold(line[0], "END"):
+			block := top("IF")
+			if block == nil || !is_END_IF(line) {
+				return fmt.Errorf("line %d END IF without block IF", ip)
+			}
+			if block.else_ip != 0 {
+				function.Blocks[block.else_ip] = ip
+			} else {
+				function.Blocks[block.line] = ip
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if len(stack) != 0 {
+		block := stack[len(stack)-1]
+		return fmt.Errorf("line %d %s block is not terminated", block.line, block.kind)
+	}
+	return nil
+}
+
+// line number following ip, used to skip over blocks
+func (dvm *DVM_Interpreter) next_line(ip uint64) (uint64, error) {
+	lines := dvm.function.LineNumbers
+	index := sort.Search(len(lines), func(i int) bool { return lines[i] > ip })
+	if index >= len(lines) {
+		return 0, fmt.Errorf("function \"%s\" reached end without RETURN", dvm.function.Name)
+	}
+	return lines[index], nil
+}
+
+// matching line of a block, as computed by parser
+func (dvm *DVM_Interpreter) block_target() (uint64, error) {
+	target, ok := dvm.function.Blocks[dvm.IP]
+	if !ok {
+		return 0, fmt.Errorf("line %d block terminator not found", dvm.IP)
+	}
+	return target, nil
+}
+
+// evaluates an expression which must result in Uint64
+func (dvm *DVM_Interpreter) eval_uint64(expression string) (uint64, error) {
+	expr, err := parser.ParseExpr(replacer.Replace(expression))
+	if err != nil {
+		return 0, err
+	}
+	result, ok := dvm.eval(expr).(uint64)
+	if !ok {
+		return 0, fmt.Errorf("expression \"%s\" is not Uint64", expression)
+	}
+	return result, nil
+}
+
+// FOR initializes the loop variable and skips the loop, if it should not run even once
+func (dvm *DVM_Interpreter) interpret_FOR(line []string) (newIP uint64, err error) {
+	next_ip, err := dvm.block_target()
+	if err != nil {
+		return
+	}
+	variable, start_expr, end_expr, step_expr, err := split_FOR(line)
+	if err != nil {
+		return
+	}
+
+	if v, ok := dvm.Locals[variable]; !ok || v.Type != Uint64 {
+		return 0, fmt.Errorf("FOR variable \"%s\" must be DIMed as Uint64", variable)
+	}
+
+	loop := for_loop{variable: variable, step: 1}
+	start, err := dvm.eval_uint64(start_expr)
+	if err != nil {
+		return
+	}
+	if loop.end, err = dvm.eval_uint64(end_expr); err != nil {
+		return
+	}
+	if step_expr != "" {
+		if loop.step, err = dvm.eval_uint64(step_expr); err != nil {
+			return
+		}
+		if loop.step == 0 {
+			return 0, fmt.Errorf("FOR STEP cannot be 0")
+		}
+	}
+
+	dvm.Locals[variable] = Variable{Name: variable, Type: Uint64, Value: start}
+	if dvm.loops == nil {
+		dvm.loops = map[uint64]for_loop{}
+	}
+	dvm.loops[dvm.IP] = loop
+
+	if start > loop.end {
+		return dvm.next_line(next_ip)
+	}
+	return
+}
+
+// NEXT increments the loop variable and loops back, if end has not been crossed
+func (dvm *DVM_Interpreter) interpret_NEXT(line []string) (newIP uint64, err error) {
+	for_ip, err := dvm.block_target()
+	if err != nil {
+		return
+	}
+	loop, ok := dvm.loops[for_ip]
+	if !ok {
+		return 0, fmt.Errorf("NEXT reached without executing FOR at line %d", for_ip)
+	}
+
+	v, ok := dvm.Locals[loop.variable]
+	if !ok || v.Type != Uint64 {
+		return 0, fmt.Errorf("FOR variable \"%s\" must be Uint64", loop.variable)
+	}
+	current := v.Value.(uint64)
+	next := current + loop.step
+	if next < current || next > loop.end { // loop is over, overflow also terminates the loop
+		return
+	}
+	dvm.Locals[loop.variable] = Variable{Name: loop.variable, Type: Uint64, Value: next}
+	return dvm.next_line(for_ip)
+}
+
+// WHILE skips the loop once expression evaluates to 0
+func (dvm *DVM_Interpreter) interpret_WHILE(line []string) (newIP uint64, err error) {
+	wend_ip, err := dvm.block_target()
+	if err != nil {
+		return
+	}
+	result, err := dvm.eval_uint64(strings.Join(line, " "))
+	if err != nil {
+		return
+	}
+	if result == 0 {
+		return dvm.next_line(wend_ip)
+	}
+	return
+}
+
+// WEND loops back to WHILE, which evaluates the expression again
+func (dvm *DVM_Interpreter) interpret_WEND(line []string) (newIP uint64, err error) {
+	return dvm.block_target()
+}
+
+// block IF falls through to THEN part, otherwise skips to ELSE part or END IF
+func (dvm *DVM_Interpreter) interpret_block_IF(line []string) (newIP uint64, err error) {
+	target, err := dvm.block_target()
+	if err != nil {
+		return
+	}
+	result, err := dvm.eval_uint64(strings.Join(line[:len(line)-1], " "))
+	if err != nil {
+		return
+	}
+	if result == 0 {
+		return dvm.next_line(target) // line after ELSE, or after END IF
+	}
+	return
+}
+
+// ELSE is reached at the end of THEN part, which skips to END IF
+func (dvm *DVM_Interpreter) interpret_ELSE(line []string) (newIP uint64, err error) {
+	return dvm.block_target()
+}
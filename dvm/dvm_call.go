@@ -0,0 +1,119 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements calls from one SC to public functions of another SC
+// CALL_SC(scid, "Function", value, args...)  arguments are matched to parameters of the called function by position
+// value DERO is transferred from calling SC to called SC before the call
+// both SCs share the same TX_Storage, so changes made by all SCs commit or are discarded together
+// called SC sees itself as SCID() and the calling SC as CALLER(), other inputs such as TXID() are same as the calling SC
+// SIGNER() is empty within called SC, otherwise any SC could act on behalf of the signer, eg. drain IF SIGNER() == LOAD("owner") checks
+import "fmt"
+import "go/ast"
+import "unicode"
+import "unicode/utf8"
+
+import "../crypto"
+import "../address"
+
+// 64 calls are more than necessary
+const LIMIT_recursion = 64
+
+func (dvm *DVM_Interpreter) call_SC(expr *ast.CallExpr) interface{} {
+	if len(expr.Args) < 3 {
+		panic("CALL_SC function expects atleast 3 parameters, scid, function name and value")
+	}
+
+	scid_eval := dvm.eval(expr.Args[0])
+	name_eval := dvm.eval(expr.Args[1])
+	value_eval := dvm.eval(expr.Args[2])
+
+	scid_str, ok := scid_eval.(string)
+	if !ok || len(scid_str) != 64 {
+		panic("scid must be a valid SCID in hex form")
+	}
+	scid := crypto.HexToKey(scid_str)
+
+	func_name, ok := name_eval.(string)
+	if !ok {
+		panic("function name must be a string")
+	}
+	// only exported functions can be called, Initialize can never be triggerred again
+	if r, _ := utf8.DecodeRuneInString(func_name); r >= unicode.MaxASCII || !unicode.IsUpper(r) || func_name == "Initialize" {
+		panic(fmt.Sprintf("function \"%s\" cannot be called from another SC", func_name))
+	}
+
+	value, ok := value_eval.(uint64)
+	if !ok {
+		panic("value must be valid uint64")
+	}
+
+	dvm.State.Consume_Gas(GAS_CALL)
+
+	sc, found := dvm.State.Store.Load_SC(scid)
+	if !found {
+		panic(fmt.Sprintf("SC %s not found", scid))
+	}
+
+	function_call, ok := sc.Functions[func_name]
+	if !ok {
+		panic(fmt.Sprintf("function \"%s\" is not available in SC %s", func_name, scid))
+	}
+	if len(function_call.Params) != len(expr.Args)-3 {
+		panic(fmt.Sprintf("function \"%s\" called with incorrect number of arguments , expected %d , actual %d", func_name, len(function_call.Params), len(expr.Args)-3))
+	}
+
+	arguments := map[string]interface{}{}
+	for i, p := range function_call.Params {
+		switch p.Type {
+		case Uint64:
+			arguments[p.Name] = fmt.Sprintf("%d", dvm.eval(expr.Args[i+3]).(uint64))
+		case String, Blob, Address:
+			arguments[p.Name] = dvm.eval(expr.Args[i+3]).(string)
+		}
+	}
+
+	caller_inputs := dvm.State.Chain_inputs
+	caller_received := dvm.State.DERO_Received
+
+	dvm.State.Store.Balance(scid) // panics if SC is not installed
+	if value > 0 {
+		dvm.State.Store.SendInternal(caller_inputs.SCID, scid, value)
+	}
+
+	// called SC operates on its own storage and balance
+	callee_inputs := *caller_inputs
+	callee_inputs.SCID = scid
+	callee_inputs.Caller = caller_inputs.SCID
+	callee_inputs.Signer = address.Address{}
+	dvm.State.Chain_inputs = &callee_inputs
+	dvm.State.trace().call("CALL_SC %s %s value %d", scid, func_name, value)
+	dvm.State.DERO_Received = value
+
+	result, err := runSmartContract_internal(&sc, func_name, dvm.State, arguments)
+	if err != nil {
+		panic(err)
+	}
+
+	dvm.State.Chain_inputs = caller_inputs
+	dvm.State.DERO_Received = caller_received
+
+	if function_call.ReturnValue.Type != Invalid {
+		return result.Value
+	}
+	return nil
+}
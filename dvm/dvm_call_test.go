@@ -0,0 +1,172 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+import "strings"
+import "testing"
+
+import "../crypto"
+import "../address"
+
+var call_test_caller = `Function Call(scid String, x Uint64) Uint64
+	10 RETURN CALL_SC(scid, "Add", 100, x)
+	End Function
+
+	Function CallPrivate(scid String) Uint64
+	10 RETURN CALL_SC(scid, "private", 0)
+	End Function
+
+	Function CallMissing(scid String) Uint64
+	10 RETURN CALL_SC(scid, "Missing", 0)
+	End Function
+
+	Function Drain(scid String) Uint64
+	10 RETURN CALL_SC(scid, "Withdraw", 0)
+	End Function
+
+	Function CallCaller(scid String) String
+	10 RETURN CALL_SC(scid, "Caller", 0)
+	End Function`
+
+var call_test_callee = `Function Add(x Uint64) Uint64
+	10 STORE("x", x)
+	20 RETURN x + 1
+	End Function
+
+	Function Loop() Uint64
+	10 RETURN CALL_SC(SCID(), "Loop", 0)
+	End Function
+
+	Function private() Uint64
+	10 RETURN 0
+	End Function
+
+	Function SetOwner() Uint64
+	10 STORE("owner", SIGNER())
+	20 RETURN 0
+	End Function
+
+	Function Withdraw() Uint64
+	10 IF LOAD("owner") == SIGNER() THEN GOTO 30
+	20 RETURN 1
+	30 RETURN 0
+	End Function
+
+	Function Caller() String
+	10 RETURN CALLER()
+	End Function`
+
+// sets up 2 installed SCs with balance 1000 each sharing a TX_Storage
+func call_test_setup(t *testing.T) (state *Shared_State, caller, callee SmartContract, caller_scid, callee_scid crypto.Key) {
+	var err error
+	if caller, _, err = ParseSmartContract(call_test_caller); err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+	if callee, _, err = ParseSmartContract(call_test_callee); err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+	caller_scid, callee_scid = crypto.Key{1}, crypto.Key{2}
+
+	store := Initialize_TX_store()
+	store.DiskLoader = func(key DataKey, found *uint64) (result Variable) {
+		if key.Special && (key.SCID == caller_scid || key.SCID == callee_scid) {
+			*found = 1
+			return Variable{Type: Uint64, Value: uint64(1000)}
+		}
+		return
+	}
+	store.SCLoader = func(scid crypto.Key) (SmartContract, bool) {
+		switch scid {
+		case caller_scid:
+			return caller, true
+		case callee_scid:
+			return callee, true
+		}
+		return SmartContract{}, false
+	}
+
+	state = &Shared_State{Store: store, Chain_inputs: &Blockchain_Input{BL_HEIGHT: 5, BL_TOPOHEIGHT: 9, SCID: caller_scid,
+		BLID: crypto.Identity, TXID: crypto.Identity}}
+	return
+}
+
+func Test_CALL_SC_execution(t *testing.T) {
+	state, caller, _, caller_scid, callee_scid := call_test_setup(t)
+	result, err := RunSmartContract(&caller, "Call", state, map[string]interface{}{"scid": callee_scid.String(), "x": "7"})
+	if err != nil || result.Value != uint64(8) {
+		t.Fatalf("SC call failed err %v result %+v", err, result)
+	}
+	if state.Chain_inputs.SCID != caller_scid {
+		t.Fatalf("SCID not restored after call")
+	}
+	if len(state.Store.Atoms) != 1 || state.Store.Atoms[0].Key.SCID != callee_scid || state.Store.Atoms[0].Value.Value != uint64(7) {
+		t.Fatalf("called SC must write to its own storage %+v", state.Store.Atoms)
+	}
+	if state.Store.Balance(caller_scid) != 900 || state.Store.Balance(callee_scid) != 1100 {
+		t.Fatalf("value not transferred, balances %d %d", state.Store.Balance(caller_scid), state.Store.Balance(callee_scid))
+	}
+
+	state, caller, _, _, callee_scid = call_test_setup(t)
+	if _, err = RunSmartContract(&caller, "CallPrivate", state, map[string]interface{}{"scid": callee_scid.String()}); err == nil {
+		t.Fatalf("unexported functions must not be callable")
+	}
+
+	state, caller, _, _, callee_scid = call_test_setup(t)
+	if _, err = RunSmartContract(&caller, "CallMissing", state, map[string]interface{}{"scid": callee_scid.String()}); err == nil {
+		t.Fatalf("missing functions must be reported")
+	}
+
+	state, caller, _, _, _ = call_test_setup(t)
+	if _, err = RunSmartContract(&caller, "Call", state, map[string]interface{}{"scid": crypto.Key{3}.String(), "x": "7"}); err == nil {
+		t.Fatalf("unknown SC must be reported")
+	}
+
+	// recursion is bounded across SCs
+	state, _, callee, _, callee_scid := call_test_setup(t)
+	state.Chain_inputs.SCID = callee_scid
+	if _, err = RunSmartContract(&callee, "Loop", state, map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), "recursion limit") {
+		t.Fatalf("recursion limit must be enforced")
+	}
+}
+
+// SC A must not pass owner checks of SC B on behalf of whoever signed the TX calling A
+func Test_CALL_SC_signer(t *testing.T) {
+	state, caller, callee, caller_scid, callee_scid := call_test_setup(t)
+	state.Chain_inputs.Signer = *address.NewAddressFromKeys(crypto.Key{5}, crypto.Key{5})
+
+	state.Chain_inputs.SCID = callee_scid
+	if _, err := RunSmartContract(&callee, "SetOwner", state, map[string]interface{}{}); err != nil {
+		t.Fatalf("SetOwner failed err %s", err)
+	}
+	if result, err := RunSmartContract(&callee, "Withdraw", state, map[string]interface{}{}); err != nil || result.Value != uint64(0) {
+		t.Fatalf("owner must pass owner check err %v result %+v", err, result)
+	}
+	if result, err := RunSmartContract(&callee, "Caller", state, map[string]interface{}{}); err != nil || result.Value != "" {
+		t.Fatalf("CALLER must be empty when called by TX err %v result %+v", err, result)
+	}
+
+	state.Chain_inputs.SCID = caller_scid
+	if result, err := RunSmartContract(&caller, "Drain", state, map[string]interface{}{"scid": callee_scid.String()}); err != nil || result.Value != uint64(1) {
+		t.Fatalf("owner check must fail within CALL_SC err %v result %+v", err, result)
+	}
+	if result, err := RunSmartContract(&caller, "CallCaller", state, map[string]interface{}{"scid": callee_scid.String()}); err != nil || result.Value != caller_scid.String() {
+		t.Fatalf("CALLER must be calling SC err %v result %+v", err, result)
+	}
+	if state.Chain_inputs.Signer.String() != address.NewAddressFromKeys(crypto.Key{5}, crypto.Key{5}).String() || state.Chain_inputs.Caller != (crypto.Key{}) {
+		t.Fatalf("signer not restored after call")
+	}
+}
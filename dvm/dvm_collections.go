@@ -0,0 +1,193 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements persistent maps and arrays, they live only in SC storage, not as local variables
+// every element is stored under its own DataKey, so lookups never iterate
+// collection length is stored under the collection key with Invalid Key
+// deleted elements are stored as Invalid, which blockchain persists as an empty value
+//
+// MAP_STORE(name, key, value)   MAP_LOAD(name, key)   MAP_EXISTS(name, key)   MAP_DELETE(name, key)   MAP_LEN(name)
+// ARRAY_APPEND(name, value) returns index   ARRAY_LOAD(name, index)   ARRAY_STORE(name, index, value)
+// ARRAY_DELETE(name, index) moves last element into index   ARRAY_LEN(name)
+//
+// keys and values may be Uint64 or String, an element keeps its type, arrays hold elements of single type
+import "fmt"
+import "go/ast"
+import "strings"
+
+var collection_functions = map[string]int{ // name => number of arguments
+	"MAP_STORE":    3,
+	"MAP_LOAD":     2,
+	"MAP_EXISTS":   2,
+	"MAP_DELETE":   2,
+	"MAP_LEN":      1,
+	"ARRAY_APPEND": 2,
+	"ARRAY_LOAD":   2,
+	"ARRAY_STORE":  3,
+	"ARRAY_DELETE": 2,
+	"ARRAY_LEN":    1,
+}
+
+func is_collection_function(func_name string) bool {
+	_, ok := collection_functions[strings.ToUpper(func_name)]
+	return ok
+}
+
+// converts evaluated value to a storable variable
+func collection_variable(v interface{}) Variable {
+	switch v := v.(type) {
+	case uint64:
+		return Variable{Type: Uint64, Value: v}
+	case string:
+		return Variable{Type: String, Value: v}
+	}
+	panic("collections can only contain Uint64 or String")
+}
+
+func (dvm *DVM_Interpreter) collection_key(collection Vtype, name string, key Variable) DataKey {
+	return DataKey{SCID: dvm.State.Chain_inputs.SCID, Collection: collection, Name: name, Key: key}
+}
+
+// loads an element, deleted elements are not found
+func (dvm *DVM_Interpreter) collection_load(dkey DataKey) (Variable, bool) {
+	dvm.State.Consume_Gas(GAS_LOAD)
+	var found uint64
+	value := dvm.State.Store.Load(dkey, &found)
+	return value, found != 0 && value.Type != Invalid
+}
+
+func (dvm *DVM_Interpreter) collection_store(dkey DataKey, value Variable) {
+	dvm.State.Consume_Gas(GAS_STORE)
+	dvm.State.Store.Store(dkey, value)
+}
+
+func (dvm *DVM_Interpreter) collection_len(collection Vtype, name string) uint64 {
+	if length, found := dvm.collection_load(dvm.collection_key(collection, name, Variable{})); found {
+		return length.Value.(uint64)
+	}
+	return 0
+}
+
+func (dvm *DVM_Interpreter) collection_set_len(collection Vtype, name string, length uint64) {
+	dvm.collection_store(dvm.collection_key(collection, name, Variable{}), Variable{Type: Uint64, Value: length})
+}
+
+// checks array index and returns the element key
+func (dvm *DVM_Interpreter) array_key(name string, index interface{}) (DataKey, uint64) {
+	i, ok := index.(uint64)
+	if !ok {
+		panic("array index must be Uint64")
+	}
+	length := dvm.collection_len(Array, name)
+	if i >= length {
+		panic(fmt.Sprintf("array \"%s\" index %d out of range, length %d", name, i, length))
+	}
+	return dvm.collection_key(Array, name, Variable{Type: Uint64, Value: i}), length
+}
+
+func (dvm *DVM_Interpreter) collection_function(expr *ast.CallExpr, func_name string) interface{} {
+	func_name = strings.ToUpper(func_name)
+	if len(expr.Args) != collection_functions[func_name] {
+		panic(fmt.Sprintf("%s function expects %d parameters", func_name, collection_functions[func_name]))
+	}
+
+	name, ok := dvm.eval(expr.Args[0]).(string)
+	if !ok || name == "" {
+		panic(fmt.Sprintf("%s expects collection name as first parameter", func_name))
+	}
+
+	switch func_name {
+	case "MAP_STORE":
+		dkey := dvm.collection_key(Map, name, collection_variable(dvm.eval(expr.Args[1])))
+		value := collection_variable(dvm.eval(expr.Args[2]))
+		if old_value, found := dvm.collection_load(dkey); !found {
+			dvm.collection_set_len(Map, name, dvm.collection_len(Map, name)+1)
+		} else if old_value.Type != value.Type {
+			panic(fmt.Sprintf("map \"%s\" element is %s, cannot store %s", name, old_value.Type, value.Type))
+		}
+		dvm.collection_store(dkey, value)
+		return nil
+
+	case "MAP_LOAD":
+		value, found := dvm.collection_load(dvm.collection_key(Map, name, collection_variable(dvm.eval(expr.Args[1]))))
+		if !found {
+			panic(fmt.Sprintf("map \"%s\" does not contain key", name))
+		}
+		return value.Value
+
+	case "MAP_EXISTS":
+		if _, found := dvm.collection_load(dvm.collection_key(Map, name, collection_variable(dvm.eval(expr.Args[1])))); found {
+			return uint64(1)
+		}
+		return uint64(0)
+
+	case "MAP_DELETE": // returns 1 if key was deleted
+		dkey := dvm.collection_key(Map, name, collection_variable(dvm.eval(expr.Args[1])))
+		if _, found := dvm.collection_load(dkey); !found {
+			return uint64(0)
+		}
+		dvm.collection_store(dkey, Variable{})
+		dvm.collection_set_len(Map, name, dvm.collection_len(Map, name)-1)
+		return uint64(1)
+
+	case "MAP_LEN":
+		return dvm.collection_len(Map, name)
+
+	case "ARRAY_APPEND": // returns index of new element
+		value := collection_variable(dvm.eval(expr.Args[1]))
+		length := dvm.collection_len(Array, name)
+		if length > 0 {
+			if first, _ := dvm.collection_load(dvm.collection_key(Array, name, Variable{Type: Uint64, Value: uint64(0)})); first.Type != value.Type {
+				panic(fmt.Sprintf("array \"%s\" contains %s, cannot append %s", name, first.Type, value.Type))
+			}
+		}
+		dvm.collection_store(dvm.collection_key(Array, name, Variable{Type: Uint64, Value: length}), value)
+		dvm.collection_set_len(Array, name, length+1)
+		return length
+
+	case "ARRAY_LOAD":
+		dkey, _ := dvm.array_key(name, dvm.eval(expr.Args[1]))
+		value, _ := dvm.collection_load(dkey)
+		return value.Value
+
+	case "ARRAY_STORE":
+		dkey, _ := dvm.array_key(name, dvm.eval(expr.Args[1]))
+		value := collection_variable(dvm.eval(expr.Args[2]))
+		if old_value, _ := dvm.collection_load(dkey); old_value.Type != value.Type {
+			panic(fmt.Sprintf("array \"%s\" contains %s, cannot store %s", name, old_value.Type, value.Type))
+		}
+		dvm.collection_store(dkey, value)
+		return nil
+
+	case "ARRAY_DELETE": // last element takes place of deleted element, so as array remains dense
+		dkey, length := dvm.array_key(name, dvm.eval(expr.Args[1]))
+		last_key := dvm.collection_key(Array, name, Variable{Type: Uint64, Value: length - 1})
+		if dkey != last_key {
+			last, _ := dvm.collection_load(last_key)
+			dvm.collection_store(dkey, last)
+		}
+		dvm.collection_store(last_key, Variable{})
+		dvm.collection_set_len(Array, name, length-1)
+		return nil
+
+	case "ARRAY_LEN":
+		return dvm.collection_len(Array, name)
+	}
+
+	panic("We should never reach here while evaluating collections")
+}
@@ -0,0 +1,131 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+import "bytes"
+import "testing"
+
+import "../crypto"
+
+var collections_test_sc = `Function Maps() Uint64
+	10 MAP_STORE("balances", "alice", 10)
+	20 MAP_STORE("balances", "bob", 20)
+	30 MAP_STORE("balances", "alice", 15)
+	40 IF MAP_LEN("balances") != 2 THEN GOTO 200
+	50 IF MAP_LOAD("balances", "alice") != 15 THEN GOTO 200
+	60 IF MAP_DELETE("balances", "alice") != 1 THEN GOTO 200
+	70 IF MAP_DELETE("balances", "alice") != 0 THEN GOTO 200
+	80 IF MAP_EXISTS("balances", "alice") != 0 THEN GOTO 200
+	90 IF MAP_LEN("balances") != 1 THEN GOTO 200
+	100 MAP_STORE("names", 1, "one")
+	110 IF MAP_LOAD("names", 1) != "one" THEN GOTO 200
+	120 RETURN 0
+	200 RETURN 1
+	End Function
+
+	Function Arrays() Uint64
+	10 IF ARRAY_APPEND("list", "a") != 0 THEN GOTO 200
+	20 IF ARRAY_APPEND("list", "b") != 1 THEN GOTO 200
+	30 IF ARRAY_APPEND("list", "c") != 2 THEN GOTO 200
+	40 ARRAY_STORE("list", 1, "B")
+	50 ARRAY_DELETE("list", 0)
+	60 IF ARRAY_LEN("list") != 2 THEN GOTO 200
+	70 IF ARRAY_LOAD("list", 0) != "c" THEN GOTO 200
+	80 IF ARRAY_LOAD("list", 1) != "B" THEN GOTO 200
+	90 ARRAY_DELETE("list", 1)
+	100 IF ARRAY_LEN("list") != 1 THEN GOTO 200
+	110 RETURN 0
+	200 RETURN 1
+	End Function
+
+	Function MapTypeMismatch() Uint64
+	10 MAP_STORE("m", "k", 1)
+	20 MAP_STORE("m", "k", "x")
+	30 RETURN 0
+	End Function
+
+	Function ArrayTypeMismatch() Uint64
+	10 ARRAY_APPEND("a", 1)
+	20 ARRAY_APPEND("a", "x")
+	30 RETURN 0
+	End Function
+
+	Function ArrayOutOfRange() Uint64
+	10 ARRAY_APPEND("a", 1)
+	20 RETURN ARRAY_LOAD("a", 1)
+	End Function
+
+	Function MapMissing() Uint64
+	10 RETURN MAP_LOAD("m", "k")
+	End Function`
+
+func Test_Collections_execution(t *testing.T) {
+	sc, _, err := ParseSmartContract(collections_test_sc)
+	if err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+
+	tests := []struct {
+		EntryPoint string
+		Fail       bool
+	}{
+		{"Maps", false},
+		{"Arrays", false},
+		{"MapTypeMismatch", true},
+		{"ArrayTypeMismatch", true},
+		{"ArrayOutOfRange", true},
+		{"MapMissing", true},
+	}
+
+	for _, test := range tests {
+		store := Initialize_TX_store()
+		store.DiskLoader = func(DataKey, *uint64) (result Variable) { return }
+		state := &Shared_State{Store: store, Chain_inputs: &Blockchain_Input{BL_HEIGHT: 5, BL_TOPOHEIGHT: 9, SCID: crypto.Identity,
+			BLID: crypto.Identity, TXID: crypto.Identity}}
+
+		result, err := RunSmartContract(&sc, test.EntryPoint, state, map[string]interface{}{})
+		switch {
+		case test.Fail && err == nil:
+			t.Fatalf("\"%s\" expected error", test.EntryPoint)
+		case !test.Fail && (err != nil || result.Value != uint64(0)):
+			t.Fatalf("\"%s\" failed err %v result %+v", test.EntryPoint, err, result)
+		}
+	}
+}
+
+func Test_Serialize_DataKey_Collections(t *testing.T) {
+	key := Variable{Type: String, Value: "k"}
+	keys := []DataKey{
+		{Key: key},
+		{Key: key, Collection: Map, Name: "m"},
+		{Key: key, Collection: Array, Name: "m"},
+		{Key: key, Collection: Map, Name: "mk"},
+		{Collection: Map, Name: "m"},
+		{Key: Variable{Type: String, Value: "\x05\x01mk"}},
+	}
+
+	for i := range keys {
+		if !bytes.Equal(Serialize_DataKey(keys[i]), Serialize_DataKey(keys[i])) {
+			t.Fatalf("serialization not deterministic %+v", keys[i])
+		}
+		for j := range keys {
+			if i != j && bytes.Equal(Serialize_DataKey(keys[i]), Serialize_DataKey(keys[j])) {
+				t.Fatalf("keys %+v and %+v serialize equally", keys[i], keys[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements hashing, hex and string builtins and signature verification
+// hashes are returned as raw 32 byte strings, use HEX() to convert them for display or comparison with hex params
+//
+// KECCAK256(s)   SHA256(s)   HEX(s)   HEXDECODE(s)   STRLEN(s)   SUBSTR(s, offset, length)
+// VERIFY_SIGNATURE(address, message, signature) returns 1 if signature ( 64 raw bytes C || R ) of KECCAK256(message)
+// was generated by spend key of address
+//
+// cost only depends on the size of inputs, so it is same on every node
+import "fmt"
+import "go/ast"
+import "strings"
+import "encoding/hex"
+import "crypto/sha256"
+
+import "../crypto"
+import "../address"
+
+var crypto_functions = map[string]int{ // name => number of arguments
+	"KECCAK256":        1,
+	"SHA256":           1,
+	"HEX":              1,
+	"HEXDECODE":        1,
+	"STRLEN":           1,
+	"SUBSTR":           3,
+	"VERIFY_SIGNATURE": 3,
+}
+
+func is_crypto_function(func_name string) bool {
+	_, ok := crypto_functions[strings.ToUpper(func_name)]
+	return ok
+}
+
+// evaluates a string argument
+func (dvm *DVM_Interpreter) eval_string(func_name string, exp ast.Expr) string {
+	s, ok := dvm.eval(exp).(string)
+	if !ok {
+		panic(fmt.Sprintf("%s expects String parameter", func_name))
+	}
+	return s
+}
+
+func (dvm *DVM_Interpreter) eval_number(func_name string, exp ast.Expr) uint64 {
+	n, ok := dvm.eval(exp).(uint64)
+	if !ok {
+		panic(fmt.Sprintf("%s expects Uint64 parameter", func_name))
+	}
+	return n
+}
+
+func (dvm *DVM_Interpreter) crypto_function(expr *ast.CallExpr, func_name string) interface{} {
+	func_name = strings.ToUpper(func_name)
+	if len(expr.Args) != crypto_functions[func_name] {
+		panic(fmt.Sprintf("%s function expects %d parameters", func_name, crypto_functions[func_name]))
+	}
+
+	switch func_name {
+	case "KECCAK256":
+		s := dvm.eval_string(func_name, expr.Args[0])
+		dvm.State.Consume_Gas(GAS_HASH + GAS_BYTE*uint64(len(s)))
+		hash := crypto.Keccak256([]byte(s))
+		return string(hash[:])
+
+	case "SHA256":
+		s := dvm.eval_string(func_name, expr.Args[0])
+		dvm.State.Consume_Gas(GAS_HASH + GAS_BYTE*uint64(len(s)))
+		hash := sha256.Sum256([]byte(s))
+		return string(hash[:])
+
+	case "HEX":
+		s := dvm.eval_string(func_name, expr.Args[0])
+		dvm.State.Consume_Gas(GAS_BYTE * uint64(len(s)))
+		return hex.EncodeToString([]byte(s))
+
+	case "HEXDECODE":
+		s := dvm.eval_string(func_name, expr.Args[0])
+		dvm.State.Consume_Gas(GAS_BYTE * uint64(len(s)))
+		decoded, err := hex.DecodeString(s)
+		if err != nil {
+			panic(fmt.Sprintf("HEXDECODE invalid hex string err %s", err))
+		}
+		return string(decoded)
+
+	case "STRLEN":
+		return uint64(len(dvm.eval_string(func_name, expr.Args[0])))
+
+	case "SUBSTR": // works on bytes
+		s := dvm.eval_string(func_name, expr.Args[0])
+		offset := dvm.eval_number(func_name, expr.Args[1])
+		length := dvm.eval_number(func_name, expr.Args[2])
+		if offset > uint64(len(s)) || length > uint64(len(s))-offset {
+			panic(fmt.Sprintf("SUBSTR offset %d length %d out of range, string length %d", offset, length, len(s)))
+		}
+		dvm.State.Consume_Gas(GAS_BYTE * length)
+		return s[offset : offset+length]
+
+	case "VERIFY_SIGNATURE":
+		addr_str := dvm.eval_string(func_name, expr.Args[0])
+		message := dvm.eval_string(func_name, expr.Args[1])
+		signature := dvm.eval_string(func_name, expr.Args[2])
+		dvm.State.Consume_Gas(GAS_SIGNATURE + GAS_BYTE*uint64(len(message)))
+
+		addr, err := address.NewAddress(addr_str)
+		if err != nil || len(signature) != 64 {
+			return uint64(0)
+		}
+		var sig crypto.Signature
+		copy(sig.C[:], signature[:32])
+		copy(sig.R[:], signature[32:])
+		if crypto.Signature_Verify(crypto.Key(crypto.Keccak256([]byte(message))), addr.SpendKey, &sig) {
+			return uint64(1)
+		}
+		return uint64(0)
+	}
+
+	panic("We should never reach here while evaluating crypto functions")
+}
@@ -0,0 +1,125 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+import "testing"
+import "crypto/sha256"
+import "encoding/hex"
+
+import "../crypto"
+import "../address"
+
+var crypto_test_sc = `Function Keccak(s String) String
+	10 RETURN HEX(KECCAK256(s))
+	End Function
+
+	Function Sha(s String) String
+	10 RETURN HEX(SHA256(s))
+	End Function
+
+	Function HexRoundTrip(s String) String
+	10 RETURN HEXDECODE(HEX(s))
+	End Function
+
+	Function Slice(s String, offset Uint64, length Uint64) String
+	10 RETURN SUBSTR(s, offset, length)
+	End Function
+
+	Function Len(s String) Uint64
+	10 RETURN STRLEN(s)
+	End Function
+
+	Function Verify(addr String, message String, signature String) Uint64
+	10 RETURN VERIFY_SIGNATURE(addr, message, signature)
+	End Function
+
+	Function BadHex() String
+	10 RETURN HEXDECODE("zz")
+	End Function`
+
+func crypto_test_run(t *testing.T, entrypoint string, params map[string]interface{}) (Variable, error) {
+	sc, _, err := ParseSmartContract(crypto_test_sc)
+	if err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+	state := &Shared_State{Chain_inputs: &Blockchain_Input{BL_HEIGHT: 5, BL_TOPOHEIGHT: 9, SCID: crypto.Identity,
+		BLID: crypto.Identity, TXID: crypto.Identity}}
+	return RunSmartContract(&sc, entrypoint, state, params)
+}
+
+func Test_Crypto_execution(t *testing.T) {
+	keccak := crypto.Keccak256([]byte("hello"))
+	sha := sha256.Sum256([]byte("hello"))
+
+	secret, public := crypto.NewKeyPair()
+	addr := address.NewAddressFromKeys(*public, *public).String()
+	msg_hash := crypto.Key(crypto.Keccak256([]byte("reveal")))
+	var sig crypto.Signature
+	crypto.Signature_Generate(msg_hash, *public, *secret, &sig)
+	signature := string(sig.C[:]) + string(sig.R[:])
+
+	tests := []struct {
+		EntryPoint string
+		Params     map[string]interface{}
+		Result     interface{}
+	}{
+		{"Keccak", map[string]interface{}{"s": "hello"}, hex.EncodeToString(keccak[:])},
+		{"Sha", map[string]interface{}{"s": "hello"}, hex.EncodeToString(sha[:])},
+		{"HexRoundTrip", map[string]interface{}{"s": "\x00\xffab"}, "\x00\xffab"},
+		{"Slice", map[string]interface{}{"s": "hello world", "offset": "6", "length": "5"}, "world"},
+		{"Slice", map[string]interface{}{"s": "hello", "offset": "5", "length": "0"}, ""},
+		{"Slice", map[string]interface{}{"s": "hello", "offset": "4", "length": "2"}, nil},
+		{"Slice", map[string]interface{}{"s": "hello", "offset": "6", "length": "0"}, nil},
+		{"Len", map[string]interface{}{"s": "hello"}, uint64(5)},
+		{"Verify", map[string]interface{}{"addr": addr, "message": "reveal", "signature": signature}, uint64(1)},
+		{"Verify", map[string]interface{}{"addr": addr, "message": "forged", "signature": signature}, uint64(0)},
+		{"Verify", map[string]interface{}{"addr": addr, "message": "reveal", "signature": "short"}, uint64(0)},
+		{"Verify", map[string]interface{}{"addr": "invalid", "message": "reveal", "signature": signature}, uint64(0)},
+		{"BadHex", map[string]interface{}{}, nil},
+	}
+
+	for _, test := range tests {
+		result, err := crypto_test_run(t, test.EntryPoint, test.Params)
+		if test.Result == nil {
+			if err == nil {
+				t.Fatalf("\"%s\" %+v expected error", test.EntryPoint, test.Params)
+			}
+			continue
+		}
+		if err != nil || result.Value != test.Result {
+			t.Fatalf("\"%s\" %+v expected %v actual %v err %v", test.EntryPoint, test.Params, test.Result, result.Value, err)
+		}
+	}
+}
+
+// cost must only depend on input size
+func Test_Crypto_Gas(t *testing.T) {
+	run := func(s string) uint64 {
+		sc, _, _ := ParseSmartContract(crypto_test_sc)
+		state := &Shared_State{Chain_inputs: &Blockchain_Input{SCID: crypto.Identity, BLID: crypto.Identity, TXID: crypto.Identity}}
+		if _, err := RunSmartContract(&sc, "Keccak", state, map[string]interface{}{"s": s}); err != nil {
+			t.Fatalf("execution failed err %s", err)
+		}
+		return state.Gas_Used
+	}
+	if run("aaaa") != run("bbbb") {
+		t.Fatalf("gas differs for inputs of same size")
+	}
+	if run("aaaaaaaa") != run("aaaa")+4*GAS_BYTE { // hash is of fixed size, only hashing costs more
+		t.Fatalf("gas does not grow with input size")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements EMIT, which records structured events so as dApps can observe what happened within a call
+// EMIT("Name", args...)  args must be Uint64 or String, returns 0
+// events are recorded in TX_Storage in order of emission, SC executing EMIT is recorded as source
+// events are persisted only if changes are committed, so a failed call does not emit anything
+import "fmt"
+import "go/ast"
+
+import "../crypto"
+
+const LIMIT_event_args = 16   // arguments in a single event
+const LIMIT_event_size = 1024 // bytes of name and all arguments in a single event
+
+type SC_Event struct {
+	SCID crypto.Key // SC which emitted the event
+	Name string
+	Args []Variable
+}
+
+func (dvm *DVM_Interpreter) emit(expr *ast.CallExpr) interface{} {
+	if len(expr.Args) < 1 {
+		panic("EMIT function expects atleast 1 parameter, event name")
+	}
+	if len(expr.Args) > LIMIT_event_args+1 {
+		panic(fmt.Sprintf("EMIT function accepts atmost %d arguments after event name", LIMIT_event_args))
+	}
+
+	event := SC_Event{SCID: dvm.State.Chain_inputs.SCID, Name: dvm.eval_string("EMIT", expr.Args[0])}
+	if len(event.Name) == 0 {
+		panic("EMIT event name cannot be empty")
+	}
+
+	size := len(event.Name)
+	for _, arg := range expr.Args[1:] {
+		switch v := dvm.eval(arg).(type) {
+		case uint64:
+			size += 8
+			event.Args = append(event.Args, Variable{Type: Uint64, Value: v})
+		case string:
+			size += len(v)
+			event.Args = append(event.Args, Variable{Type: String, Value: v})
+		default:
+			panic("EMIT arguments must be Uint64 or String")
+		}
+	}
+	if size > LIMIT_event_size {
+		panic(fmt.Sprintf("EMIT event size %d exceeds limit %d", size, LIMIT_event_size))
+	}
+
+	dvm.State.Consume_Gas(GAS_EMIT + GAS_BYTE*uint64(size))
+	dvm.State.Store.Events = append(dvm.State.Store.Events, event)
+	return uint64(0)
+}
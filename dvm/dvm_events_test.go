@@ -0,0 +1,83 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+import "testing"
+import "strings"
+
+import "../crypto"
+
+var events_test_sc = `Function Transfer(to String, amount Uint64) Uint64
+	10 EMIT("Transfer", to, amount)
+	20 EMIT("Done")
+	30 RETURN 0
+	End Function
+
+	Function BadArg() Uint64
+	10 DIM x as Uint64
+	20 EMIT(5)
+	30 RETURN 0
+	End Function
+
+	Function TooLarge(s String) Uint64
+	10 EMIT("Big", s)
+	20 RETURN 0
+	End Function`
+
+func Test_Emit_execution(t *testing.T) {
+	sc, _, err := ParseSmartContract(events_test_sc)
+	if err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+
+	run := func(entrypoint string, params map[string]interface{}) (*Shared_State, error) {
+		state := &Shared_State{Store: Initialize_TX_store(), Chain_inputs: &Blockchain_Input{BL_HEIGHT: 5, BL_TOPOHEIGHT: 9,
+			SCID: crypto.Identity, BLID: crypto.Identity, TXID: crypto.Identity}}
+		_, err := RunSmartContract(&sc, entrypoint, state, params)
+		return state, err
+	}
+
+	state, err := run("Transfer", map[string]interface{}{"to": "bob", "amount": "77"})
+	if err != nil {
+		t.Fatalf("Transfer failed err %s", err)
+	}
+	events := state.Store.Events
+	if len(events) != 2 || events[0].Name != "Transfer" || events[1].Name != "Done" || len(events[1].Args) != 0 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if events[0].SCID != crypto.Identity || len(events[0].Args) != 2 ||
+		events[0].Args[0] != (Variable{Type: String, Value: "bob"}) || events[0].Args[1] != (Variable{Type: Uint64, Value: uint64(77)}) {
+		t.Fatalf("unexpected event args %+v", events[0])
+	}
+	if state.Gas_Used < 2*GAS_EMIT+len_gas("Transfer"+"bob")+8+len_gas("Done") {
+		t.Fatalf("EMIT not charged, gas used %d", state.Gas_Used)
+	}
+
+	if state, err = run("BadArg", nil); err == nil || len(state.Store.Events) != 0 {
+		t.Fatalf("event with non string name must fail")
+	}
+	if _, err = run("TooLarge", map[string]interface{}{"s": strings.Repeat("x", LIMIT_event_size)}); err == nil {
+		t.Fatalf("event above size limit must fail")
+	}
+	if _, err = run("TooLarge", map[string]interface{}{"s": strings.Repeat("x", 100)}); err != nil {
+		t.Fatalf("event within size limit failed err %s", err)
+	}
+}
+
+func len_gas(s string) uint64 {
+	return GAS_BYTE * uint64(len(s))
+}
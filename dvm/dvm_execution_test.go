@@ -20,7 +20,7 @@ import "fmt"
 import "reflect"
 import "testing"
 
-//import "github.com/deroproject/derosuite/crypto"
+import "../crypto"
 
 var execution_tests = []struct {
 	Name       string
@@ -1755,3 +1755,227 @@ func Test_GOTO_execution(t *testing.T) {
 		}
 	}
 }
+
+// ensure FOR/NEXT, WHILE/WEND and block IF work, and unmatched blocks are rejected while parsing
+var execution_tests_blocks = []struct {
+	Name       string
+	Code       string
+	EntryPoint string
+	Args       map[string]interface{}
+	Perr       error    // parse error
+	Eerr       error    // execute error
+	result     Variable // execution result
+}{
+	{
+		"valid  function  testing  FOR NEXT ",
+		`Function TestRun(a1 Uint64) Uint64
+		 10 dim i, s as Uint64
+		 20 FOR i = 1 TO a1
+		 30 LET s = s + i
+		 40 NEXT i
+		 50 return  s
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "10"},
+		nil,
+		nil,
+		Variable{Type: Uint64, Value: uint64(55)},
+	}, {
+		"valid  function  testing  FOR NEXT STEP, loop not entered",
+		`Function TestRun(a1 Uint64) Uint64
+		 10 dim i, s as Uint64
+		 20 FOR i = 1 TO a1 STEP 2
+		 30 LET s = s + i
+		 40 NEXT
+		 50 FOR i = 5 TO 4
+		 60 LET s = s + 1000
+		 70 NEXT i
+		 80 return  s
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "10"},
+		nil,
+		nil,
+		Variable{Type: Uint64, Value: uint64(25)},
+	}, {
+		"valid  function  testing  WHILE WEND with nested block IF ELSE",
+		`Function TestRun(a1 Uint64) Uint64
+		 10 dim odd, even as Uint64
+		 20 WHILE a1 > 0
+		 30 IF a1 % 2 == 1 THEN
+		 40 LET odd = odd + 1
+		 50 ELSE
+		 60 LET even = even + 1
+		 70 END IF
+		 80 LET a1 = a1 - 1
+		 90 WEND
+		 100 return  odd * 100 + even
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "5"},
+		nil,
+		nil,
+		Variable{Type: Uint64, Value: uint64(302)},
+	}, {
+		"valid  function  testing  block IF without ELSE and line number GOTO",
+		`Function TestRun(a1 Uint64) Uint64
+		 10 IF a1 == 1 THEN
+		 20 GOTO 50
+		 30 END IF
+		 40 return  0
+		 50 return  1
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "1"},
+		nil,
+		nil,
+		Variable{Type: Uint64, Value: uint64(1)},
+	}, {
+		"invalid  function  testing  FOR variable not DIMed",
+		`Function TestRun(a1 Uint64) Uint64
+		 20 FOR i = 1 TO a1
+		 40 NEXT i
+		 50 return  0
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "10"},
+		nil,
+		fmt.Errorf("dummy"),
+		Variable{Type: Uint64, Value: uint64(0)},
+	}, {
+		"invalid  function  testing  FOR without NEXT",
+		`Function TestRun(a1 Uint64) Uint64
+		 10 dim i as Uint64
+		 20 FOR i = 1 TO a1
+		 50 return  0
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "10"},
+		fmt.Errorf("dummy"),
+		nil,
+		Variable{},
+	}, {
+		"invalid  function  testing  NEXT variable mismatch",
+		`Function TestRun(a1 Uint64) Uint64
+		 10 dim i,j as Uint64
+		 20 FOR i = 1 TO a1
+		 40 NEXT j
+		 50 return  0
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "10"},
+		fmt.Errorf("dummy"),
+		nil,
+		Variable{},
+	}, {
+		"invalid  function  testing  WEND without WHILE",
+		`Function TestRun(a1 Uint64) Uint64
+		 40 WEND
+		 50 return  0
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "10"},
+		fmt.Errorf("dummy"),
+		nil,
+		Variable{},
+	}, {
+		"invalid  function  testing  interleaved blocks",
+		`Function TestRun(a1 Uint64) Uint64
+		 10 WHILE a1 > 0
+		 20 IF a1 == 1 THEN
+		 30 WEND
+		 40 END IF
+		 50 return  0
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "10"},
+		fmt.Errorf("dummy"),
+		nil,
+		Variable{},
+	}, {
+		"invalid  function  testing  duplicate ELSE",
+		`Function TestRun(a1 Uint64) Uint64
+		 20 IF a1 == 1 THEN
+		 30 ELSE
+		 35 ELSE
+		 40 END IF
+		 50 return  0
+                 End Function
+                 `,
+		"TestRun",
+		map[string]interface{}{"a1": "10"},
+		fmt.Errorf("dummy"),
+		nil,
+		Variable{},
+	},
+}
+
+// run the test
+func Test_BLOCKS_execution(t *testing.T) {
+	for _, test := range execution_tests_blocks {
+		sc, _, err := ParseSmartContract(test.Code)
+		switch {
+		case test.Perr == nil && err == nil:
+		case test.Perr != nil && err != nil:
+			continue
+		default:
+			t.Fatalf("Error while parsing smart contract \"%s\"\nExpected %v\nActual %v\n", test.Name, test.Perr, err)
+		}
+
+		state := &Shared_State{Chain_inputs: &Blockchain_Input{}}
+		result, err := RunSmartContract(&sc, test.EntryPoint, state, test.Args)
+		switch {
+		case test.Eerr == nil && err == nil:
+			if !reflect.DeepEqual(result, test.result) {
+				t.Fatalf("Error while executing smart contract \"%s\"\nExpected result %v\nActual result %v\n", test.Name, test.result, result)
+			}
+		case test.Eerr != nil && err != nil: // pass
+		case test.Eerr == nil && err != nil:
+			fallthrough
+		case test.Eerr != nil && err == nil:
+			t.Fatalf("Error while executing smart contract \"%s\"\nExpected %s\nActual %s\n", test.Name, test.Eerr, err)
+		}
+	}
+}
+
+// debugger sees every line before it executes, along with locals
+func Test_Line_Hook(t *testing.T) {
+	sc, _, err := ParseSmartContract(`Function Main() Uint64
+	10 DIM x as Uint64
+	20 LET x = 5
+	30 GOTO 50
+	40 LET x = 6
+	50 RETURN x
+	End Function`)
+	if err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+
+	var lines []uint64
+	var x_at_return interface{}
+	state := &Shared_State{Chain_inputs: &Blockchain_Input{}}
+	state.Line_Hook = func(scid crypto.Key, function string, ip uint64, locals map[string]Variable) {
+		lines = append(lines, ip)
+		if ip == 50 {
+			x_at_return = locals["x"].Value
+		}
+	}
+	if _, err = RunSmartContract(&sc, "Main", state, nil); err != nil {
+		t.Fatalf("execution failed err %s", err)
+	}
+	if !reflect.DeepEqual(lines, []uint64{10, 20, 30, 50}) || x_at_return != uint64(5) {
+		t.Fatalf("unexpected lines %v x %v", lines, x_at_return)
+	}
+	if sc.Functions["Main"].Source_Lines[50] != 6 {
+		t.Fatalf("source line not recorded %v", sc.Functions["Main"].Source_Lines)
+	}
+}
@@ -19,6 +19,7 @@ package dvm
 import "go/ast"
 import "strings"
 
+import "../crypto"
 import "../address"
 
 // this files defines  external functions which can be called in DVM
@@ -157,8 +158,20 @@ func (dvm *DVM_Interpreter) Handle_Internal_Function(expr *ast.CallExpr, func_na
 		if len(expr.Args) != 0 {
 			panic("SIGNER function expects 0 parameters")
 		}
+		if dvm.State.Chain_inputs.Caller != (crypto.Key{}) { // signer is not available to called SC
+			return true, ""
+		}
 		return true, dvm.State.Chain_inputs.Signer.String()
 
+	case strings.EqualFold(func_name, "CALLER"): // SCID of calling SC, empty if called directly by TX
+		if len(expr.Args) != 0 {
+			panic("CALLER function expects 0 parameters")
+		}
+		if dvm.State.Chain_inputs.Caller == (crypto.Key{}) {
+			return true, ""
+		}
+		return true, dvm.State.Chain_inputs.Caller.String()
+
 	case strings.EqualFold(func_name, "IS_ADDRESS_VALID"): // checks whether the address is valid DERO address
 		if len(expr.Args) != 1 {
 			panic("IS_ADDRESS_VALID function expects 1 parameters")
@@ -199,6 +212,21 @@ func (dvm *DVM_Interpreter) Handle_Internal_Function(expr *ast.CallExpr, func_na
 			return true, ""
 		}
 
+	case is_crypto_function(func_name):
+		return true, dvm.crypto_function(expr, func_name)
+
+	case is_collection_function(func_name):
+		return true, dvm.collection_function(expr, func_name)
+
+	case strings.EqualFold(func_name, "CALL_SC"):
+		return true, dvm.call_SC(expr)
+
+	case strings.EqualFold(func_name, "EMIT"):
+		return true, dvm.emit(expr)
+
+	case strings.EqualFold(func_name, "UPDATE_SC_CODE"):
+		return true, dvm.update_SC_code(expr)
+
 	case strings.EqualFold(func_name, "SEND_DERO_TO_ADDRESS"):
 		if len(expr.Args) != 2 {
 			panic("SEND_DERO_TO_ADDRESS function expects 2 parameters")
@@ -219,6 +247,7 @@ func (dvm *DVM_Interpreter) Handle_Internal_Function(expr *ast.CallExpr, func_na
 			return true, amount_eval
 		}
 
+		dvm.State.Consume_Gas(GAS_SEND)
 		dvm.State.Store.SendExternal(dvm.State.Chain_inputs.SCID, addr_eval.(string), amount_eval.(uint64)) // add record for external transfer
 
 		return true, amount_eval
@@ -231,6 +260,7 @@ func (dvm *DVM_Interpreter) Handle_Internal_Function(expr *ast.CallExpr, func_na
 // the load/store functions are sandboxed and thus cannot affect any other SC storage
 // loads  a variable from store
 func (dvm *DVM_Interpreter) Load(key Variable) interface{} {
+	dvm.State.Consume_Gas(GAS_LOAD)
 
 	var found uint64
 
@@ -240,6 +270,8 @@ func (dvm *DVM_Interpreter) Load(key Variable) interface{} {
 
 // whether a variable exists in store or not
 func (dvm *DVM_Interpreter) Exists(key Variable) uint64 {
+	dvm.State.Consume_Gas(GAS_LOAD)
+
 	var found uint64
 
 	dvm.State.Store.Load(DataKey{SCID: dvm.State.Chain_inputs.SCID, Key: key}, &found)
@@ -247,5 +279,6 @@ func (dvm *DVM_Interpreter) Exists(key Variable) uint64 {
 }
 
 func (dvm *DVM_Interpreter) Store(key Variable, value Variable) {
+	dvm.State.Consume_Gas(GAS_STORE)
 	dvm.State.Store.Store(DataKey{SCID: dvm.State.Chain_inputs.SCID, Key: key}, value)
 }
@@ -0,0 +1,52 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements gas metering, every statement, eval, storage access and transfer has a cost
+// caller provides a gas limit, crossing it aborts execution with ErrOutOfGas
+// if gas limit is 0, execution is unmetered and bounded by LIMIT_interpreted_lines and LIMIT_evals as before
+// unmetered execution is only used by tools and tests, SC txs and dry runs are always metered
+import "errors"
+
+const (
+	GAS_LINE  = 10   // every line interpreted
+	GAS_EVAL  = 1    // every expression node evaluated
+	GAS_LOAD  = 100  // LOAD and EXISTS
+	GAS_STORE = 500  // STORE
+	GAS_SEND  = 1000 // SEND_DERO_TO_ADDRESS
+	GAS_CALL  = 1000 // CALL_SC, called SC pays for its own lines from same gas
+	GAS_EMIT  = 200  // EMIT, plus GAS_BYTE per byte of name and arguments
+
+	GAS_UPDATE_CODE = 10000 // UPDATE_SC_CODE, plus GAS_BYTE per byte of code
+
+	GAS_BYTE      = 1    // every byte hashed, hex converted or copied
+	GAS_HASH      = 100  // KECCAK256 and SHA256, plus GAS_BYTE per byte
+	GAS_SIGNATURE = 5000 // VERIFY_SIGNATURE, plus GAS_BYTE per byte of message
+)
+
+const GAS_LIMIT_MAX = 10000000 // no call can use more gas than this
+
+var ErrOutOfGas = errors.New("out of gas")
+
+// charges gas, used gas is tracked even if execution is unmetered, so as it can be estimated
+func (state *Shared_State) Consume_Gas(gas uint64) {
+	state.Gas_Used += gas
+	if state.Gas_Limit != 0 && state.Gas_Used > state.Gas_Limit {
+		state.Gas_Used = state.Gas_Limit
+		panic(ErrOutOfGas)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+import "fmt"
+import "testing"
+
+import "github.com/deroproject/derosuite/crypto"
+
+var gas_test_sc = `Function Loop(count Uint64) Uint64
+	10 DIM i as Uint64
+	20 LET i = 0
+	30 IF i >= count THEN GOTO 60
+	40 LET i = i + 1
+	50 GOTO 30
+	60 RETURN i
+	End Function`
+
+func gas_test_run(t *testing.T, count uint64, gas_limit uint64) (*Shared_State, Variable, error) {
+	sc, _, err := ParseSmartContract(gas_test_sc)
+	if err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+	state := &Shared_State{Gas_Limit: gas_limit, Chain_inputs: &Blockchain_Input{BL_HEIGHT: 5, BL_TOPOHEIGHT: 9, SCID: crypto.Identity,
+		BLID: crypto.Identity, TXID: crypto.Identity}}
+	result, err := RunSmartContract(&sc, "Loop", state, map[string]interface{}{"count": fmt.Sprintf("%d", count)})
+	return state, result, err
+}
+
+func Test_Gas_execution(t *testing.T) {
+	// unmetered execution still tracks gas
+	state, result, err := gas_test_run(t, 10, 0)
+	if err != nil || result.Value.(uint64) != 10 {
+		t.Fatalf("unmetered execution failed err %v result %+v", err, result)
+	}
+	gas_10 := state.Gas_Used
+	if gas_10 == 0 {
+		t.Fatalf("gas used not tracked")
+	}
+
+	// more work costs more gas
+	state, _, err = gas_test_run(t, 20, 0)
+	if err != nil || state.Gas_Used <= gas_10 {
+		t.Fatalf("gas used must grow with work, %d <= %d", state.Gas_Used, gas_10)
+	}
+
+	// exact limit is enough
+	if state, _, err = gas_test_run(t, 10, gas_10); err != nil || state.Gas_Used != gas_10 {
+		t.Fatalf("execution within gas limit failed err %v gas used %d", err, state.Gas_Used)
+	}
+
+	if state, _, err = gas_test_run(t, 10, gas_10-1); err != ErrOutOfGas || state.Gas_Used != gas_10-1 {
+		t.Fatalf("expected out of gas, err %v gas used %d", err, state.Gas_Used)
+	}
+
+	// metered execution is not bound by line limit
+	if _, result, err = gas_test_run(t, 5000, GAS_LIMIT_MAX); err != nil || result.Value.(uint64) != 5000 {
+		t.Fatalf("metered execution failed err %v", err)
+	}
+
+	if _, _, err = gas_test_run(t, 10, GAS_LIMIT_MAX+1); err == nil {
+		t.Fatalf("gas limit above maximum must be rejected")
+	}
+}
@@ -0,0 +1,489 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements static analysis of DVM BASIC contracts
+// parser only rejects syntax errors line by line, so SC authors must otherwise rely on 100 % coverage
+// lint follows control flow of every function and reports
+// unreachable lines, GOTO to missing lines, variables used before DIM, functions which can reach end without RETURN,
+// LET with expression of different type and calls to unknown functions
+import "fmt"
+import "sort"
+import "strconv"
+import "strings"
+import "go/ast"
+import "go/token"
+import "go/parser"
+
+// a problem found by lint, Line is the line within source code
+type Diagnostic struct {
+	Line     int
+	Function string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Function == "" {
+		return fmt.Sprintf("%d: %s", d.Line, d.Message)
+	}
+	return fmt.Sprintf("%d: function \"%s\" %s", d.Line, d.Function, d.Message)
+}
+
+// builtins handled by Handle_Internal_Function and their return type, Invalid if type is not known statically
+// must be kept in sync with Handle_Internal_Function
+var lint_builtins = map[string]Vtype{
+	"MAJOR_VERSION":        Uint64,
+	"LOAD":                 Invalid,
+	"EXISTS":               Uint64,
+	"STORE":                Invalid,
+	"RANDOM":               Uint64,
+	"SCID":                 String,
+	"BLID":                 String,
+	"TXID":                 String,
+	"BLOCK_HEIGHT":         Uint64,
+	"BLOCK_TOPOHEIGHT":     Uint64,
+	"SIGNER":               String,
+	"CALLER":               String,
+	"IS_ADDRESS_VALID":     Uint64,
+	"ADDRESS_RAW":          String,
+	"SEND_DERO_TO_ADDRESS": Uint64,
+	"CALL_SC":              Invalid,
+	"MAP_STORE":            Invalid,
+	"MAP_LOAD":             Invalid,
+	"MAP_EXISTS":           Uint64,
+	"MAP_DELETE":           Uint64,
+	"MAP_LEN":              Uint64,
+	"ARRAY_APPEND":         Uint64,
+	"ARRAY_LOAD":           Invalid,
+	"ARRAY_STORE":          Invalid,
+	"ARRAY_DELETE":         Invalid,
+	"ARRAY_LEN":            Uint64,
+	"KECCAK256":            String,
+	"SHA256":               String,
+	"HEX":                  String,
+	"HEXDECODE":            String,
+	"STRLEN":               Uint64,
+	"SUBSTR":               String,
+	"VERIFY_SIGNATURE":     Uint64,
+	"EMIT":                 Uint64,
+	"UPDATE_SC_CODE":       Uint64,
+}
+
+// variables known to be DIMed, nil means all variables, used as start value while solving
+type var_set map[string]bool
+
+func (s var_set) intersect(other var_set) var_set {
+	if s == nil {
+		return other
+	}
+	if other == nil {
+		return s
+	}
+	result := var_set{}
+	for name := range s {
+		if other[name] {
+			result[name] = true
+		}
+	}
+	return result
+}
+
+func (s var_set) equal(other var_set) bool {
+	if (s == nil) != (other == nil) || len(s) != len(other) {
+		return false
+	}
+	for name := range s {
+		if !other[name] {
+			return false
+		}
+	}
+	return true
+}
+
+type linter struct {
+	sc          *SmartContract
+	function    *Function
+	types       map[string]Vtype // type of every parameter and DIMed variable
+	diagnostics []Diagnostic
+}
+
+// lints complete source code, parse errors are reported as single diagnostic
+func Lint(src_code string) (diagnostics []Diagnostic) {
+	sc, pos, err := ParseSmartContract(src_code)
+	if err != nil {
+		d := Diagnostic{Message: err.Error()}
+		if parts := strings.Split(pos, ":"); len(parts) >= 2 {
+			d.Line, _ = strconv.Atoi(parts[1])
+		}
+		return []Diagnostic{d}
+	}
+
+	var functions []*Function
+	for name := range sc.Functions {
+		f := sc.Functions[name]
+		functions = append(functions, &f)
+	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Source_Line < functions[j].Source_Line })
+
+	for _, f := range functions {
+		l := linter{sc: &sc, function: f, types: map[string]Vtype{}}
+		l.lint()
+		diagnostics = append(diagnostics, l.diagnostics...)
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].Line < diagnostics[j].Line })
+	return
+}
+
+func (l *linter) report(ip uint64, format string, args ...interface{}) {
+	line := l.function.Source_Line
+	if ip != 0 {
+		line = l.function.Source_Lines[ip]
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{Line: line, Function: l.function.Name, Message: fmt.Sprintf(format, args...)})
+}
+
+// line following ip, false if ip is the last line
+func (l *linter) next(ip uint64) (uint64, bool) {
+	lines := l.function.LineNumbers
+	index := sort.Search(len(lines), func(i int) bool { return lines[i] > ip })
+	if index >= len(lines) {
+		return 0, false
+	}
+	return lines[index], true
+}
+
+// lines which may execute after ip, fall_through is set if execution can continue past the last line
+func (l *linter) successors(ip uint64) (succ []uint64, fall_through bool) {
+	line := l.function.Lines[ip]
+	add_next := func(ip uint64) {
+		if next, ok := l.next(ip); ok {
+			succ = append(succ, next)
+		} else {
+			fall_through = true
+		}
+	}
+	add_target := func(target string) {
+		n, err := strconv.ParseUint(target, 0, 64)
+		if _, ok := l.function.Lines[n]; err != nil || !ok {
+			l.report(ip, "GOTO line %s does not exist", target)
+			return
+		}
+		succ = append(succ, n)
+	}
+
+	switch {
+	case len(line) == 0:
+		add_next(ip)
+	case strings.EqualFold(line[0], "RETURN"):
+	case strings.EqualFold(line[0], "GOTO"):
+		if len(line) != 2 {
+			l.report(ip, "GOTO contains 1 mandatory line number as argument")
+			return
+		}
+		add_target(line[1])
+	case is_block_IF(line):
+		add_next(ip)
+		add_next(l.function.Blocks[ip]) // line after ELSE or END IF
+	case strings.EqualFold(line[0], "IF"):
+		n := len(line)
+		if n >= 7 && strings.EqualFold(line[n-6], "THEN") && strings.EqualFold(line[n-5], "GOTO") && strings.EqualFold(line[n-3], "ELSE") && strings.EqualFold(line[n-2], "GOTO") {
+			add_target(line[n-4])
+			add_target(line[n-1])
+		} else if n >= 4 && strings.EqualFold(line[n-3], "THEN") && strings.EqualFold(line[n-2], "GOTO") {
+			add_target(line[n-1])
+			add_next(ip)
+		} else {
+			l.report(ip, "Invalid IF syntax")
+		}
+	case strings.EqualFold(line[0], "ELSE"), strings.EqualFold(line[0], "WEND"):
+		succ = append(succ, l.function.Blocks[ip])
+	case strings.EqualFold(line[0], "FOR"), strings.EqualFold(line[0], "WHILE"):
+		add_next(ip)
+		add_next(l.function.Blocks[ip]) // loop may not run at all
+	case strings.EqualFold(line[0], "NEXT"):
+		add_next(l.function.Blocks[ip]) // loop back
+		add_next(ip)
+	default:
+		add_next(ip)
+	}
+	return
+}
+
+// expressions evaluated by the line, along with variables which are read or written
+func (l *linter) line_expressions(ip uint64) (expressions []string, variables []string, dims []string) {
+	line := l.function.Lines[ip]
+	if len(line) == 0 {
+		return
+	}
+	switch {
+	case strings.EqualFold(line[0], "DIM"):
+		if len(line) <= 3 || !strings.EqualFold(line[len(line)-2], "as") {
+			l.report(ip, "Invalid DIM syntax")
+			return
+		}
+		data_type := check_valid_type(line[len(line)-1])
+		for _, name := range line[1 : len(line)-2] {
+			if name != "," {
+				dims = append(dims, name)
+				if _, ok := l.types[name]; !ok {
+					l.types[name] = data_type
+				}
+			}
+		}
+	case strings.EqualFold(line[0], "LET"):
+		if len(line) <= 3 || line[2] != "=" {
+			l.report(ip, "Invalid LET syntax")
+			return
+		}
+		variables = append(variables, line[1])
+		expressions = append(expressions, strings.Join(line[3:], " "))
+	case strings.EqualFold(line[0], "GOTO"), strings.EqualFold(line[0], "ELSE"), strings.EqualFold(line[0], "WEND"), is_END_IF(line):
+	case is_block_IF(line):
+		expressions = append(expressions, strings.Join(line[1:len(line)-1], " "))
+	case strings.EqualFold(line[0], "IF"):
+		for i := range line {
+			if strings.EqualFold(line[i], "THEN") {
+				expressions = append(expressions, strings.Join(line[1:i], " "))
+				break
+			}
+		}
+	case strings.EqualFold(line[0], "FOR"):
+		variable, start, end, step, err := split_FOR(line)
+		if err != nil {
+			l.report(ip, "%s", err)
+			return
+		}
+		variables = append(variables, variable)
+		expressions = append(expressions, start, end)
+		if step != "" {
+			expressions = append(expressions, step)
+		}
+	case strings.EqualFold(line[0], "NEXT"):
+		variables = append(variables, line[1:]...)
+	case strings.EqualFold(line[0], "WHILE"), strings.EqualFold(line[0], "RETURN"):
+		if len(line) > 1 {
+			expressions = append(expressions, strings.Join(line[1:], " "))
+		}
+	case strings.EqualFold(line[0], "PRINT"), strings.EqualFold(line[0], "PRINTF"):
+		for i := 2; i < len(line); i++ {
+			if line[i] != "," {
+				variables = append(variables, line[i])
+			}
+		}
+	default:
+		expressions = append(expressions, strings.Join(line, " "))
+	}
+	return
+}
+
+func (l *linter) lint() {
+	f := l.function
+	if len(f.LineNumbers) == 0 {
+		l.report(0, "has no lines and reaches end without RETURN")
+		return
+	}
+
+	for _, p := range f.Params {
+		l.types[p.Name] = p.Type
+	}
+
+	// collect everything about lines once, so as every problem is reported once
+	type line_info struct {
+		succ         []uint64
+		fall_through bool
+		expressions  []ast.Expr
+		variables    []string
+		dims         []string
+	}
+	info := map[uint64]*line_info{}
+	for _, ip := range f.LineNumbers {
+		li := &line_info{}
+		li.succ, li.fall_through = l.successors(ip)
+		var expressions []string
+		expressions, li.variables, li.dims = l.line_expressions(ip)
+		for _, e := range expressions {
+			expr, err := parser.ParseExpr(replacer.Replace(e))
+			if err != nil {
+				l.report(ip, "invalid expression \"%s\"", e)
+				continue
+			}
+			li.expressions = append(li.expressions, expr)
+		}
+		info[ip] = li
+	}
+
+	// find reachable lines
+	reachable := map[uint64]bool{f.LineNumbers[0]: true}
+	pending := []uint64{f.LineNumbers[0]}
+	for len(pending) > 0 {
+		ip := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, next := range info[ip].succ {
+			if !reachable[next] {
+				reachable[next] = true
+				pending = append(pending, next)
+			}
+		}
+	}
+
+	// variables DIMed on every path reaching a line
+	params := var_set{}
+	for _, p := range f.Params {
+		params[p.Name] = true
+	}
+	in := map[uint64]var_set{f.LineNumbers[0]: params}
+	for changed := true; changed; {
+		changed = false
+		for _, ip := range f.LineNumbers {
+			if _, ok := in[ip]; !ok { // not reached yet
+				continue
+			}
+			out := var_set{}
+			for name := range in[ip] {
+				out[name] = true
+			}
+			for _, name := range info[ip].dims {
+				out[name] = true
+			}
+			for _, next := range info[ip].succ {
+				merged := in[next].intersect(out)
+				if _, ok := in[next]; !ok || !merged.equal(in[next]) {
+					in[next] = merged
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, ip := range f.LineNumbers {
+		li := info[ip]
+		if !reachable[ip] {
+			// ELSE and END IF are not reached, if THEN part returns, that is not a problem
+			if line := f.Lines[ip]; len(line) == 0 || !(strings.EqualFold(line[0], "ELSE") || is_END_IF(line)) {
+				l.report(ip, "line %d is unreachable", ip)
+			}
+			continue
+		}
+		if li.fall_through {
+			l.report(ip, "line %d can reach end of function without RETURN", ip)
+		}
+
+		used := append([]string{}, li.variables...)
+		for _, expr := range li.expressions {
+			used = append(used, l.identifiers(expr)...)
+			l.check_calls(ip, expr)
+		}
+		reported := map[string]bool{}
+		for _, name := range used {
+			if !in[ip][name] && !reported[name] {
+				reported[name] = true
+				l.report(ip, "variable \"%s\" is used before DIM", name)
+			}
+		}
+
+		if line := f.Lines[ip]; len(line) > 0 && strings.EqualFold(line[0], "LET") && len(li.expressions) == 1 {
+			target, expr_type := l.types[line[1]], l.infer(li.expressions[0])
+			if target != Invalid && expr_type != Invalid && is_string_type(target) != is_string_type(expr_type) {
+				l.report(ip, "LET \"%s\" is %s but expression is %s", line[1], target, expr_type)
+			}
+		}
+	}
+}
+
+// Address and Blob are strings at runtime
+func is_string_type(t Vtype) bool {
+	return t == String || t == Address || t == Blob
+}
+
+// variables read by expression, function names are not variables
+func (l *linter) identifiers(expr ast.Expr) (names []string) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			for _, arg := range n.Args {
+				names = append(names, l.identifiers(arg)...)
+			}
+			return false
+		case *ast.Ident:
+			names = append(names, n.Name)
+		}
+		return true
+	})
+	return
+}
+
+func (l *linter) check_calls(ip uint64, expr ast.Expr) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		name, ok := call.Fun.(*ast.Ident)
+		if !ok {
+			l.report(ip, "invalid function call")
+			return true
+		}
+		if _, ok := lint_builtins[strings.ToUpper(name.Name)]; ok {
+			return true
+		}
+		if function, ok := l.sc.Functions[name.Name]; !ok {
+			l.report(ip, "call to unknown function \"%s\"", name.Name)
+		} else if len(function.Params) != len(call.Args) {
+			l.report(ip, "function \"%s\" called with %d arguments, expected %d", name.Name, len(call.Args), len(function.Params))
+		}
+		return true
+	})
+}
+
+// type of expression, Invalid if it cannot be known statically
+func (l *linter) infer(expr ast.Expr) Vtype {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return l.infer(e.X)
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.INT:
+			return Uint64
+		case token.STRING:
+			return String
+		}
+	case *ast.Ident:
+		return l.types[e.Name]
+	case *ast.UnaryExpr:
+		return Uint64
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case token.ADD: // strings can be concatenated
+			if t := l.infer(e.X); t != Invalid {
+				return t
+			}
+			return l.infer(e.Y)
+		default: // arithmetic and comparisons result in Uint64
+			return Uint64
+		}
+	case *ast.CallExpr:
+		if name, ok := e.Fun.(*ast.Ident); ok {
+			if t, ok := lint_builtins[strings.ToUpper(name.Name)]; ok {
+				return t
+			}
+			if function, ok := l.sc.Functions[name.Name]; ok {
+				return function.ReturnValue.Type
+			}
+		}
+	}
+	return Invalid
+}
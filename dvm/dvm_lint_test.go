@@ -0,0 +1,95 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+import "strings"
+import "testing"
+
+var lint_tests = []struct {
+	Name string
+	Code string
+	Line int    // line of expected diagnostic, 0 if code must be clean
+	Text string // part of expected message
+}{
+	{Name: "clean", Code: `Function Clean(a Uint64) Uint64
+	10 DIM i, sum as Uint64
+	20 FOR i = 1 TO a
+	30 LET sum = sum + i
+	40 NEXT i
+	50 IF sum > 10 THEN
+	60 RETURN 1
+	70 ELSE
+	80 STORE("sum", sum)
+	90 END IF
+	100 RETURN 0
+	End Function`},
+	{Name: "unreachable", Line: 3, Text: "line 20 is unreachable", Code: `Function F() Uint64
+	10 RETURN 0
+	20 RETURN 1
+	End Function`},
+	{Name: "missing goto", Line: 2, Text: "GOTO line 50 does not exist", Code: `Function F(a Uint64) Uint64
+	10 IF a == 1 THEN GOTO 50
+	20 RETURN 1
+	End Function`},
+	{Name: "missing if goto", Line: 2, Text: "GOTO line 50 does not exist", Code: `Function F(a Uint64) Uint64
+	10 IF a == 1 THEN GOTO 20 ELSE GOTO 50
+	20 RETURN 1
+	End Function`},
+	{Name: "not dimmed", Line: 2, Text: "variable \"x\" is used before DIM", Code: `Function F() Uint64
+	10 RETURN x
+	End Function`},
+	{Name: "dimmed on one path only", Line: 4, Text: "variable \"x\" is used before DIM", Code: `Function F(a Uint64) Uint64
+	10 IF a == 1 THEN GOTO 30
+	20 DIM x as Uint64
+	30 RETURN x
+	End Function`},
+	{Name: "fall through", Line: 2, Text: "can reach end of function without RETURN", Code: `Function F() Uint64
+	10 STORE("a", 1)
+	End Function`},
+	{Name: "let type", Line: 3, Text: "LET \"s\" is String but expression is Uint64", Code: `Function F() Uint64
+	10 DIM s as String
+	20 LET s = 1 + 2
+	30 RETURN 0
+	End Function`},
+	{Name: "let function type", Line: 3, Text: "LET \"n\" is Uint64 but expression is String", Code: `Function F() Uint64
+	10 DIM n as Uint64
+	20 LET n = SIGNER()
+	30 RETURN 0
+	End Function`},
+	{Name: "unknown call", Line: 2, Text: "call to unknown function \"G\"", Code: `Function F() Uint64
+	10 RETURN G()
+	End Function`},
+	{Name: "parse error", Line: 3, Text: "line number", Code: `Function F() Uint64
+	10 RETURN 0
+	5 RETURN 0
+	End Function`},
+}
+
+func Test_Lint(t *testing.T) {
+	for _, test := range lint_tests {
+		diagnostics := Lint(test.Code)
+		if test.Line == 0 {
+			if len(diagnostics) != 0 {
+				t.Fatalf("\"%s\" expected no diagnostics, actual %+v", test.Name, diagnostics)
+			}
+			continue
+		}
+		if len(diagnostics) != 1 || diagnostics[0].Line != test.Line || !strings.Contains(diagnostics[0].Message, test.Text) {
+			t.Fatalf("\"%s\" expected line %d \"%s\", actual %+v", test.Name, test.Line, test.Text, diagnostics)
+		}
+	}
+}
@@ -30,6 +30,9 @@ type DataKey struct {
 	SCID    crypto.Key // tx which created the the contract or contract ID
 	Key     Variable
 	Special bool // whether the value is generic or special , special is used to store DERO value
+
+	Collection Vtype  // Map or Array if key is an element of a collection, Invalid otherwise
+	Name       string // name of collection, Key is Invalid for the record holding collection length
 }
 
 type DataAtom struct {
@@ -63,6 +66,14 @@ type TX_Storage struct {
 	Keys       map[DataKey]Variable // this keeps the in-transit DB updates, just in case we have to discard instantly
 
 	Transfers map[crypto.Key]SC_Transfers // all transfers ( internal/external )
+
+	SCLoader func(crypto.Key) (SmartContract, bool) // loads SC code, used by SC to SC calls
+
+	Events []SC_Event // emitted by all SCs, in order
+
+	Code map[crypto.Key]string // code replaced using UPDATE_SC_CODE
+
+	Trace *Trace // execution trace, nil if not tracing
 }
 
 var DVM_STORAGE_BACKEND DVM_Storage_Loader // this variable can be hijacked at runtime to offer different stores such as RAM/file/DB etc
@@ -74,15 +85,19 @@ type DVM_Storage_Loader interface {
 
 // initialize tx store
 func Initialize_TX_store() (tx_store *TX_Storage) {
-	tx_store = &TX_Storage{Keys: map[DataKey]Variable{}, Transfers: map[crypto.Key]SC_Transfers{}}
+	tx_store = &TX_Storage{Keys: map[DataKey]Variable{}, Transfers: map[crypto.Key]SC_Transfers{}, Code: map[crypto.Key]string{}}
 	return
 }
 
 // this will load the variable, and if the key is found
 func (tx_store *TX_Storage) Load(dkey DataKey, found_value *uint64) (value Variable) {
+	value = tx_store.load(dkey, found_value)
+	tx_store.Trace.load(dkey, value, *found_value != 0)
+	return
+}
 
-	fmt.Printf("Loading %+v   \n", dkey)
-
+// loads without tracing, used internally while storing
+func (tx_store *TX_Storage) load(dkey DataKey, found_value *uint64) (value Variable) {
 	*found_value = 0
 	// if it was modified in current TX, use it
 	if result, ok := tx_store.Keys[dkey]; ok {
@@ -109,11 +124,10 @@ func (tx_store *TX_Storage) Load(dkey DataKey, found_value *uint64) (value Varia
 
 // store variable
 func (tx_store *TX_Storage) Store(dkey DataKey, v Variable) {
-
-	fmt.Printf("Storing request %+v   : %+v\n", dkey, v)
+	tx_store.Trace.store(dkey, v)
 
 	var found uint64
-	old_value := tx_store.Load(dkey, &found)
+	old_value := tx_store.load(dkey, &found)
 
 	var atom DataAtom
 	atom.Key = dkey
@@ -131,8 +145,7 @@ func (tx_store *TX_Storage) Store(dkey DataKey, v Variable) {
 
 // store variable
 func (tx_store *TX_Storage) SendExternal(sender_scid crypto.Key, addr_str string, amount uint64) {
-
-	fmt.Printf("Transfer to  external address   : %+v\n", addr_str)
+	tx_store.Trace.transfer("%s sends %d to external address %s", sender_scid, amount, addr_str)
 
 	tx_store.Balance(sender_scid) // load from disk if required
 	transfer := tx_store.Transfers[sender_scid]
@@ -144,6 +157,7 @@ func (tx_store *TX_Storage) SendExternal(sender_scid crypto.Key, addr_str string
 
 // if TXID is not already loaded, load it
 func (tx_store *TX_Storage) ReceiveInternal(scid crypto.Key, amount uint64) {
+	tx_store.Trace.transfer("%s receives %d", scid, amount)
 
 	tx_store.Balance(scid) // load from disk if required
 	transfer := tx_store.Transfers[scid]
@@ -153,6 +167,7 @@ func (tx_store *TX_Storage) ReceiveInternal(scid crypto.Key, amount uint64) {
 }
 
 func (tx_store *TX_Storage) SendInternal(sender_scid crypto.Key, receiver_scid crypto.Key, amount uint64) {
+	tx_store.Trace.transfer("%s sends %d to %s", sender_scid, amount, receiver_scid)
 
 	//sender side
 	{
@@ -257,6 +272,12 @@ func Serialize_DataKey(dkey DataKey) (ser []byte) {
 	if !dkey.Special { // special will not have generic marker, this protects from number of attacks
 		ser = append(ser, GENERIC) // add generic marker
 	}
+	if dkey.Collection != Invalid { // collection type and length prefixed name, so as keys of different collections never collide
+		var length [binary.MaxVarintLen64]byte
+		ser = append(ser, byte(dkey.Collection))
+		ser = append(ser, length[:binary.PutUvarint(length[:], uint64(len(dkey.Name)))]...)
+		ser = append(ser, []byte(dkey.Name)...)
+	}
 	ser = append(ser, Serialize_Variable(dkey.Key)...) // add object type
 
 	return ser
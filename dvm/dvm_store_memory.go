@@ -16,8 +16,6 @@
 
 package dvm
 
-import "fmt"
-
 // this file implements a RAM store backend for testing purposes
 
 type Memory_Storage struct {
@@ -47,7 +45,6 @@ func (mem_store *Memory_Storage) Load(dkey DataKey, found_value *uint64) (value
 // store variable
 func (mem_store *Memory_Storage) Store(dkey DataKey, v Variable) {
 
-	fmt.Printf("Storing %+v   : %+v\n", dkey, v)
 	var found uint64
 	old_value := mem_store.Load(dkey, &found)
 
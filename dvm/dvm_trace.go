@@ -0,0 +1,154 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements execution traces, which replace printing to stdout while executing SCs
+// a trace records lines executed, loads, stores, transfers, SC calls and failure reason of a single execution
+// trace is attached to TX_Storage, if it is nil nothing is recorded and tracing costs nothing
+// entries only depend on SC, params and chain state, so every node produces the same trace
+import "fmt"
+import "strings"
+
+import "../crypto"
+
+// kinds of trace entries
+const (
+	TRACE_LINE     = "line"
+	TRACE_LOAD     = "load"
+	TRACE_STORE    = "store"
+	TRACE_TRANSFER = "transfer"
+	TRACE_CALL     = "call"
+	TRACE_PRINT    = "print" // output of PRINT
+	TRACE_INFO     = "info"
+	TRACE_ERROR    = "error"
+)
+
+const LIMIT_trace_entries = 10000 // entries beyond this are dropped, counters are still maintained
+
+type Trace_Entry struct {
+	Kind     string     `msgpack:"K,omitempty"`
+	SCID     crypto.Key `msgpack:"S,omitempty"` // SC executing when entry was recorded
+	Function string     `msgpack:"F,omitempty"`
+	Line     uint64     `msgpack:"L,omitempty"`
+	Message  string     `msgpack:"M,omitempty"`
+}
+
+type Trace struct {
+	Entries   []Trace_Entry `msgpack:"E,omitempty"`
+	Lines     int64         `msgpack:"LI,omitempty"` // lines interpreted
+	Evals     int64         `msgpack:"EV,omitempty"` // expressions evaluated
+	Loads     uint64        `msgpack:"LO,omitempty"`
+	Stores    uint64        `msgpack:"ST,omitempty"`
+	Transfers uint64        `msgpack:"TR,omitempty"` // internal and external transfers
+	Gas_Used  uint64        `msgpack:"G,omitempty"`
+	Failure   string        `msgpack:"FA,omitempty"` // why execution failed or changes were discarded, empty on success
+	Truncated bool          `msgpack:"TU,omitempty"` // some entries were dropped due to LIMIT_trace_entries
+
+	scid     crypto.Key // current position, attached to entries
+	function string
+	line     uint64
+}
+
+func New_Trace() *Trace {
+	return &Trace{}
+}
+
+func (t *Trace) add(kind string, format string, args ...interface{}) {
+	if len(t.Entries) >= LIMIT_trace_entries {
+		t.Truncated = true
+		return
+	}
+	t.Entries = append(t.Entries, Trace_Entry{Kind: kind, SCID: t.scid, Function: t.function, Line: t.line, Message: fmt.Sprintf(format, args...)})
+}
+
+// records a message outside of interpreter, such as while setting up execution
+func (t *Trace) Log(format string, args ...interface{}) {
+	if t != nil {
+		t.add(TRACE_INFO, format, args...)
+	}
+}
+
+// records failure reason, first failure is kept since later ones are mostly consequences
+func (t *Trace) Fail(format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	t.add(TRACE_ERROR, format, args...)
+	if t.Failure == "" {
+		t.Failure = fmt.Sprintf(format, args...)
+	}
+}
+
+// interpreter is about to execute a line
+func (t *Trace) line_executed(scid crypto.Key, function string, ip uint64, line []string) {
+	if t != nil {
+		t.scid, t.function, t.line = scid, function, ip
+		t.add(TRACE_LINE, "%s", strings.Join(line, " "))
+	}
+}
+
+func (t *Trace) load(dkey DataKey, value Variable, found bool) {
+	if t != nil {
+		t.Loads++
+		t.add(TRACE_LOAD, "%s = %v found %t", trace_key(dkey), value.Value, found)
+	}
+}
+
+func (t *Trace) store(dkey DataKey, value Variable) {
+	if t != nil {
+		t.Stores++
+		t.add(TRACE_STORE, "%s = %v", trace_key(dkey), value.Value)
+	}
+}
+
+func (t *Trace) transfer(format string, args ...interface{}) {
+	if t != nil {
+		t.Transfers++
+		t.add(TRACE_TRANSFER, format, args...)
+	}
+}
+
+func (t *Trace) call(format string, args ...interface{}) {
+	if t != nil {
+		t.add(TRACE_CALL, format, args...)
+	}
+}
+
+func (t *Trace) print(message string) {
+	if t != nil {
+		t.add(TRACE_PRINT, "%s", message)
+	}
+}
+
+// key in readable form, with SCID since SCs may access other SCs balances
+func trace_key(dkey DataKey) string {
+	switch {
+	case dkey.Special:
+		return fmt.Sprintf("%s balance", dkey.SCID)
+	case dkey.Collection != Invalid:
+		return fmt.Sprintf("%s %s %s[%v]", dkey.SCID, dkey.Collection, dkey.Name, dkey.Key.Value)
+	}
+	return fmt.Sprintf("%s %v", dkey.SCID, dkey.Key.Value)
+}
+
+// trace of the running execution, nil if not tracing
+func (state *Shared_State) trace() *Trace {
+	if state.Store == nil {
+		return nil
+	}
+	return state.Store.Trace
+}
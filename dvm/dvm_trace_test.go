@@ -0,0 +1,111 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+import "testing"
+
+import "../crypto"
+
+var trace_test_sc = `Function Deposit(name String, value Uint64) Uint64
+	10 STORE(name, LOAD("total") + value)
+	20 STORE("total", LOAD("total") + value)
+	30 RETURN 0
+	End Function
+
+	Function Fail() Uint64
+	10 RETURN LOAD("missing")
+	End Function
+
+	Function Print(value Uint64) Uint64
+	10 PRINT "value %d" value
+	20 RETURN 0
+	End Function`
+
+func Test_Trace(t *testing.T) {
+	sc, _, err := ParseSmartContract(trace_test_sc)
+	if err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+
+	run := func(entrypoint string, params map[string]interface{}) (*Trace, error) {
+		tx_store := Initialize_TX_store()
+		tx_store.Trace = New_Trace()
+		tx_store.DiskLoader = func(key DataKey, found *uint64) Variable {
+			if key.Key.Value == "total" {
+				*found = 1
+				return Variable{Type: Uint64, Value: uint64(5)}
+			}
+			return Variable{}
+		}
+		state := &Shared_State{Store: tx_store, Chain_inputs: &Blockchain_Input{SCID: crypto.Identity, BLID: crypto.Identity, TXID: crypto.Identity}}
+		_, err := RunSmartContract(&sc, entrypoint, state, params)
+		return tx_store.Trace, err
+	}
+
+	trace, err := run("Deposit", map[string]interface{}{"name": "alice", "value": "10"})
+	if err != nil {
+		t.Fatalf("Deposit failed err %s", err)
+	}
+	if trace.Lines != 3 || trace.Evals == 0 || trace.Loads != 2 || trace.Stores != 2 || trace.Failure != "" || trace.Gas_Used == 0 {
+		t.Fatalf("unexpected trace counters %+v", trace)
+	}
+
+	var lines []uint64
+	for _, e := range trace.Entries {
+		if e.Kind == TRACE_LINE {
+			lines = append(lines, e.Line)
+		}
+		if e.Kind == TRACE_STORE && (e.Function != "Deposit" || e.Line == 0) {
+			t.Fatalf("store not attributed to line %+v", e)
+		}
+	}
+	if len(lines) != 3 || lines[0] != 10 || lines[2] != 30 {
+		t.Fatalf("unexpected traced lines %+v", lines)
+	}
+
+	if trace, err = run("Fail", nil); err == nil || trace.Failure == "" || trace.Entries[len(trace.Entries)-1].Kind != TRACE_ERROR {
+		t.Fatalf("failure not traced %+v", trace)
+	}
+
+	// PRINT goes to the trace, not to stdout
+	if trace, err = run("Print", map[string]interface{}{"value": "7"}); err != nil {
+		t.Fatalf("Print failed err %s", err)
+	}
+	printed := false
+	for _, e := range trace.Entries {
+		if e.Kind == TRACE_PRINT {
+			printed = e.Message == "value 7" && e.Function == "Print" && e.Line == 10
+		}
+	}
+	if !printed {
+		t.Fatalf("PRINT not traced %+v", trace.Entries)
+	}
+
+	// entries are limited, counters are not
+	trace = New_Trace()
+	for i := 0; i <= LIMIT_trace_entries; i++ {
+		trace.store(DataKey{Key: Variable{Type: Uint64, Value: uint64(i)}}, Variable{Type: Uint64, Value: uint64(i)})
+	}
+	if len(trace.Entries) != LIMIT_trace_entries || !trace.Truncated || trace.Stores != LIMIT_trace_entries+1 {
+		t.Fatalf("trace not truncated entries %d stores %d", len(trace.Entries), trace.Stores)
+	}
+
+	// nil trace records nothing
+	var disabled *Trace
+	disabled.Log("nothing")
+	disabled.Fail("nothing")
+}
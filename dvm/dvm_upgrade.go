@@ -0,0 +1,58 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+// this file implements UPDATE_SC_CODE, which lets an SC replace its own code while keeping its storage and balance
+// UPDATE_SC_CODE(code)  code is complete DVM BASIC source, returns 0, execution fails if code cannot be parsed
+// SC must check by itself who is allowed to upgrade it, eg. IF SIGNER() != LOAD("owner") THEN GOTO 100
+// UPDATE_SC_CODE fails within SCs called through CALL_SC, since SIGNER() is empty there and such a check cannot be made
+// new code is applied only if changes are committed, the running function continues with the old code
+// SCs called later within the same tx using CALL_SC see the new code
+import "fmt"
+import "go/ast"
+
+import "../crypto"
+
+func (dvm *DVM_Interpreter) update_SC_code(expr *ast.CallExpr) interface{} {
+	if len(expr.Args) != 1 {
+		panic("UPDATE_SC_CODE function expects 1 parameter")
+	}
+	if dvm.State.Chain_inputs.Caller != (crypto.Key{}) {
+		panic("UPDATE_SC_CODE cannot be used by an SC called from another SC")
+	}
+	code := dvm.eval_string("UPDATE_SC_CODE", expr.Args[0])
+	dvm.State.Consume_Gas(GAS_UPDATE_CODE + GAS_BYTE*uint64(len(code)))
+
+	if _, pos, err := ParseSmartContract(code); err != nil {
+		panic(fmt.Sprintf("UPDATE_SC_CODE code could not be parsed pos %s err %s", pos, err))
+	}
+
+	dvm.State.Store.Code[dvm.State.Chain_inputs.SCID] = code
+	return uint64(0)
+}
+
+// loads SC code, code replaced within the tx takes precedence over code on disk
+func (tx_store *TX_Storage) Load_SC(scid crypto.Key) (sc SmartContract, found bool) {
+	if code, ok := tx_store.Code[scid]; ok {
+		sc, _, err := ParseSmartContract(code) // already checked by UPDATE_SC_CODE
+		return sc, err == nil
+	}
+	if tx_store.SCLoader == nil {
+		panic("SC loader is not ready")
+	}
+	return tx_store.SCLoader(scid)
+}
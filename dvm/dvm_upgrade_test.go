@@ -0,0 +1,97 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package dvm
+
+import "strings"
+import "testing"
+
+import "../crypto"
+import "../address"
+
+var upgrade_test_sc = `Function Upgrade(code String) Uint64
+	10 IF SIGNER() != LOAD("owner") THEN GOTO 40
+	20 UPDATE_SC_CODE(code)
+	30 RETURN 0
+	40 RETURN 1
+	End Function
+
+	Function UpgradeUnchecked(code String) Uint64
+	10 UPDATE_SC_CODE(code)
+	20 RETURN 0
+	End Function
+
+	Function UpgradeVia(code String) Uint64
+	10 RETURN CALL_SC(SCID(), "UpgradeUnchecked", 0, code)
+	End Function`
+
+const upgrade_test_new_sc = `Function Version() Uint64
+	10 RETURN 2
+	End Function`
+
+func Test_Update_SC_Code(t *testing.T) {
+	sc, _, err := ParseSmartContract(upgrade_test_sc)
+	if err != nil {
+		t.Fatalf("Error while parsing smart contract err %s", err)
+	}
+
+	scid := crypto.Key{5}
+	var owner address.Address // zero keys, same as signer
+	run := func(entrypoint, code string) (*TX_Storage, Variable, error) {
+		tx_store := Initialize_TX_store()
+		tx_store.DiskLoader = func(key DataKey, found *uint64) Variable {
+			*found = 1
+			if key.Special { // balance
+				return Variable{Type: Uint64, Value: uint64(0)}
+			}
+			return Variable{Type: String, Value: owner.String()}
+		}
+		tx_store.SCLoader = func(id crypto.Key) (SmartContract, bool) {
+			return sc, id == scid
+		}
+		state := &Shared_State{Store: tx_store, Chain_inputs: &Blockchain_Input{SCID: scid, BLID: crypto.Identity, TXID: crypto.Identity}}
+		result, err := RunSmartContract(&sc, entrypoint, state, map[string]interface{}{"code": code})
+		return tx_store, result, err
+	}
+
+	tx_store, result, err := run("Upgrade", upgrade_test_new_sc)
+	if err != nil || result.Value != uint64(0) {
+		t.Fatalf("Upgrade failed err %v result %+v", err, result)
+	}
+	if tx_store.Code[scid] != upgrade_test_new_sc {
+		t.Fatalf("code not recorded %+v", tx_store.Code)
+	}
+
+	// SC loaded later within the same tx must see new code
+	if upgraded, found := tx_store.Load_SC(scid); !found || len(upgraded.Functions) != 1 || upgraded.Functions["Version"].Name != "Version" {
+		t.Fatalf("upgraded code not loaded %+v", upgraded)
+	}
+	if other, found := tx_store.Load_SC(crypto.Key{6}); found {
+		t.Fatalf("unknown SC loaded %+v", other)
+	}
+
+	if tx_store, _, err = run("Upgrade", "Function Broken("); err == nil || len(tx_store.Code) != 0 {
+		t.Fatalf("code which cannot be parsed must fail")
+	}
+
+	// signer can be checked only when SC is called by tx
+	if tx_store, result, err = run("UpgradeUnchecked", upgrade_test_new_sc); err != nil || result.Value != uint64(0) || tx_store.Code[scid] != upgrade_test_new_sc {
+		t.Fatalf("Upgrade by tx failed err %v result %+v", err, result)
+	}
+	if tx_store, _, err = run("UpgradeVia", upgrade_test_new_sc); err == nil || !strings.Contains(err.Error(), "called from another SC") || len(tx_store.Code) != 0 {
+		t.Fatalf("Upgrade through CALL_SC must fail err %v", err)
+	}
+}
@@ -59,6 +59,7 @@ func fill_common(common *Common_Struct) {
 		common.Cumulative_Difficulty = chain.Load_Block_Cumulative_Difficulty(nil, high_block).String()
 	}
 	common.Top_Version = uint64(chain.Get_Current_Version_at_Height(int64(common.Height))) // this must be taken from the hardfork
+	common.PrunedTopoHeight = chain.Load_Pruned_TopoHeight(nil)
 
 }
 
@@ -76,6 +77,7 @@ func fill_common_skip_topoheight(common *Common_Struct) {
 		common.Cumulative_Difficulty = chain.Load_Block_Cumulative_Difficulty(nil, high_block).String()
 	}
 	common.Top_Version = uint64(chain.Get_Current_Version_at_Height(int64(common.Height))) // this must be taken from the hardfork
+	common.PrunedTopoHeight = chain.Load_Pruned_TopoHeight(nil)
 
 }
 
@@ -88,6 +90,7 @@ func (connection *Connection) Update(common *Common_Struct) {
 		atomic.StoreInt64(&connection.StableHeight, common.StableHeight) // satify race detector GOD
 	}
 	atomic.StoreInt64(&connection.TopoHeight, common.TopoHeight) // satify race detector GOD
+	atomic.StoreInt64(&connection.PrunedTopoHeight, common.PrunedTopoHeight)
 
 	//connection.Top_ID = common.Top_ID
 	connection.Cumulative_Difficulty = common.Cumulative_Difficulty
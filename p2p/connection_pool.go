@@ -70,6 +70,8 @@ type Connection struct {
 	StableHeight int64 // last stable height
 	TopoHeight   int64 // topo height, current topo height, this is the only thing we require for syncing
 
+	PrunedTopoHeight int64 // peer cannot serve complete blocks below this topo height, 0 if peer is archival
+
 	LastObjectRequestTime int64  // when was the last item placed in object list
 	BytesIn               uint64 // total bytes in
 	BytesOut              uint64 // total bytes out
@@ -80,6 +82,7 @@ type Connection struct {
 	Port              uint32            // port advertised by other end as its server,if it's 0 server cannot accept connections
 	Peer_ID           uint64            // Remote peer id
 	Lowcpuram         bool              // whether the peer has low cpu ram
+	Pruned_Responses  bool              // whether the peer understands pruned blocks in object responses, see FLAG_PRUNED
 	SyncNode          bool              // whether the peer has been added to command line as sync node
 	Top_Version       uint64            // current hard fork version supported by peer
 	TXpool_cache      map[uint64]uint32 // used for ultra blocks in miner mode,cache where we keep TX which have been broadcasted to this peer
@@ -405,6 +408,27 @@ func Random_Connection(height int64) (c *Connection) {
 	return nil
 }
 
+// returns a random archival connection with successful handshake, which can serve complete blocks
+// skip connection is never returned
+func Archival_Connection(skip *Connection) (c *Connection) {
+
+	var clist []*Connection
+
+	connection_map.Range(func(k, value interface{}) bool {
+		v := value.(*Connection)
+		if v != skip && atomic.LoadUint32(&v.State) != HANDSHAKE_PENDING && atomic.LoadInt64(&v.PrunedTopoHeight) == 0 {
+			clist = append(clist, v)
+		}
+		return true
+	})
+
+	if len(clist) > 0 {
+		return clist[rand.Int()%len(clist)]
+	}
+
+	return nil
+}
+
 // this returns count of peers in both directions
 func Peer_Direction_Count() (Incoming uint64, Outgoing uint64) {
 
@@ -663,6 +687,10 @@ func trigger_sync() {
 		//connection.Lock()   recursive mutex are not suported
 		// only choose highest available peers for syncing
 		if atomic.LoadUint32(&connection.State) != HANDSHAKE_PENDING && topoheight <= atomic.LoadInt64(&connection.TopoHeight) { // skip pre-handshake connections
+			// pruned peers cannot give us the blocks we are missing, find an archival peer
+			if chain.Load_TOPO_HEIGHT(nil) < atomic.LoadInt64(&connection.PrunedTopoHeight) {
+				continue
+			}
 			// check whether we are lagging with this connection
 			//connection.Lock()
 			islagging := chain.IsLagging(connection.CDIFF.Load().(*big.Int)) // we only use cdiff to see if we need to resync
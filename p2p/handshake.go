@@ -56,6 +56,7 @@ func (connection *Connection) Send_Handshake(request bool) {
 	if globals.Arguments["--lowcpuram"].(bool) == false {
 		handshake.Flags = append(handshake.Flags, FLAG_LOWCPURAM) // add low cpu ram flag
 	}
+	handshake.Flags = append(handshake.Flags, FLAG_PRUNED) // we understand pruned blocks in object responses
 
 	//scan our peer list and send peers which have been recently communicated
 	handshake.PeerList = get_peer_list()
@@ -158,6 +159,8 @@ func (connection *Connection) Handle_Handshake(buf []byte) {
 			switch k {
 			case FLAG_LOWCPURAM:
 				connection.Lowcpuram = true
+			case FLAG_PRUNED:
+				connection.Pruned_Responses = true
 
 				//connection.logger.Debugf("Miner flag \"%s\" from peer", k)
 			default:
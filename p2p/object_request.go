@@ -104,6 +104,15 @@ func (connection *Connection) Handle_ObjectRequest(buf []byte) {
 					//rlog.Tracef(1, "ERR Cannot load tx from DB\n")
 					return
 				}
+				if tx.IsPruned() { // we cannot serve this block, tell the peer so it finds an archival peer
+					if !connection.Pruned_Responses { // older peer would reject the block, it requests again from another peer once request times out
+						rlog.Tracef(1, "cannot serve pruned block %x to peer without pruned flag %s", request.Block_list[i], connection.logid)
+						return
+					}
+					cbl.Txs = nil
+					cbl.Pruned = true
+					break
+				}
 				cbl.Txs = append(cbl.Txs, tx.Serialize()) // append all the txs
 			}
 		}
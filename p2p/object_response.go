@@ -27,10 +27,10 @@ import "time"
 import "github.com/romana/rlog"
 import "github.com/vmihailenco/msgpack"
 
-//import "github.com/deroproject/derosuite/crypto"
 //import "github.com/deroproject/derosuite/globals"
 
 import "github.com/deroproject/derosuite/block"
+import "github.com/deroproject/derosuite/crypto"
 import "github.com/deroproject/derosuite/errormsg"
 import "github.com/deroproject/derosuite/transaction"
 
@@ -65,6 +65,8 @@ func (connection *Connection) Handle_ObjectResponse(buf []byte) {
 		rlog.Warnf("we got %d response for %d requests %s %s", len(response.CBlocks), len(expected.BLID), connection.logid)
 	}
 
+	var pruned []crypto.Hash // blocks the peer could not serve in full
+
 	for i := 0; i < len(response.CBlocks); i++ { // process incoming full blocks
 		var cbl block.Complete_Block // parse incoming block and deserialize it
 		var bl block.Block
@@ -87,6 +89,12 @@ func (connection *Connection) Handle_ObjectResponse(buf []byte) {
 		// give the chain some more time to respond
 		atomic.StoreInt64(&connection.LastObjectRequestTime, time.Now().Unix())
 
+		// peer has pruned the block, it is not malicious, we must get the block from an archival peer
+		if response.CBlocks[i].Pruned {
+			pruned = append(pruned, expected.BLID[i])
+			continue
+		}
+
 		// check whether the object was requested one
 
 		// complete the txs
@@ -114,4 +122,13 @@ func (connection *Connection) Handle_ObjectResponse(buf []byte) {
 
 	}
 
+	if len(pruned) > 0 {
+		if archival := Archival_Connection(connection); archival != nil {
+			rlog.Debugf("peer has pruned %d blocks, requesting them from archival peer %s %s", len(pruned), archival.logid, connection.logid)
+			archival.Send_ObjectRequest(pruned, nil)
+		} else {
+			rlog.Debugf("peer has pruned %d blocks and no archival peer is connected, they will be requested on next sync %s", len(pruned), connection.logid)
+		}
+	}
+
 }
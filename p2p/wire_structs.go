@@ -50,9 +50,12 @@ type Common_Struct struct {
 	Cumulative_Difficulty string `msgpack:"CDIFF"`
 	//	Top_ID                [32]byte `msgpack:"TOP"` // 32 bytes of Top block
 	Top_Version uint64 `msgpack:"HF"` // this basically represents the hard fork version
+
+	PrunedTopoHeight int64 `msgpack:"PTHEIGHT,omitempty"` // blocks below this topoheight cannot be served in full, 0 for archival nodes
 }
 
 const FLAG_LOWCPURAM string = "LOWCPURAM"
+const FLAG_PRUNED string = "PRUNED" // peer understands Complete_Block.Pruned, pruned blocks are never sent to other peers
 
 // at start, client sends handshake and server will respond to handshake
 type Handshake_Struct struct {
@@ -118,8 +121,9 @@ type Object_Response_struct struct {
 }
 
 type Complete_Block struct {
-	Block []byte   `msgpack:"BLOCK"`
-	Txs   [][]byte `msgpack:"TXS"`
+	Block  []byte   `msgpack:"BLOCK"`
+	Txs    [][]byte `msgpack:"TXS"`
+	Pruned bool     `msgpack:"PRUNED,omitempty"` // txs have been pruned, block must be requested from an archival peer, only sent to peers with FLAG_PRUNED
 }
 
 type Notify_New_Objects_Struct struct {
@@ -78,6 +78,11 @@ func (b *BadgerDBStore) Init(params map[string]interface{}) (err error) {
 	}
 	b.DB = db
 
+	if err = badger_check_layout(db); err != nil {
+		db.Close()
+		logger.Fatalf("Cannot open badgerdb store err %s", err)
+	}
+
 	// if simulation, delete the file , so as it gets cleaned up automcatically
 	if params["--simulator"] == true {
 		os.RemoveAll(current_path)
@@ -89,6 +94,48 @@ func (b *BadgerDBStore) Init(params map[string]interface{}) (err error) {
 	return nil
 }
 
+// version of the key layout, see badger_bucket
+// version 1 length prefixes bucket names, unversioned databases have concatenated bucket names
+const BADGER_LAYOUT_VERSION = 1
+
+// shorter than any bucket key, which has atleast 3 length bytes, so it cannot collide
+var badger_layout_key = []byte("v")
+
+// a new database gets the current layout version, an existing database must already have it
+// unversioned databases cannot be read with the current layout and must be resynced
+func badger_check_layout(db *badger.DB) error {
+	return db.Update(func(tx *badger.Txn) error {
+		item, err := tx.Get(badger_layout_key)
+		if err == nil {
+			value, err := item.Value()
+			if err != nil {
+				return err
+			}
+			if len(value) != 8 || binary.BigEndian.Uint64(value) != BADGER_LAYOUT_VERSION {
+				return fmt.Errorf("badger key layout version %x is not supported, expected %d, delete database and resync", value, BADGER_LAYOUT_VERSION)
+			}
+			return nil
+		}
+		if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := tx.NewIterator(opts)
+		it.Rewind()
+		empty := !it.Valid()
+		it.Close()
+		if !empty {
+			return fmt.Errorf("badger database uses the old key layout, delete database and resync")
+		}
+
+		var version [8]byte
+		binary.BigEndian.PutUint64(version[:], BADGER_LAYOUT_VERSION)
+		return tx.Set(badger_layout_key, version[:])
+	})
+}
+
 func (b *BadgerDBStore) Shutdown() (err error) {
 	logger.Infof("Shutting badgerdb store")
 	if b.DB != nil {
@@ -145,25 +192,28 @@ func Duplicate(input []byte) []byte {
 	return dup
 }
 
-func (b *BadgerTXWrapper) StoreObject(universe_name []byte, galaxy_name []byte, solar_name []byte, key []byte, data []byte) (err error) {
-	fullkey := make([]byte, 0, len(universe_name)+len(galaxy_name)+len(solar_name)+len(key))
+// badger does not have buckets, so every bucket name is prefixed by its length and concatenated
+// length prefix keeps buckets apart, otherwise bucket "a" would contain keys of bucket "ab"
+// changing this layout requires a new BADGER_LAYOUT_VERSION
+func badger_bucket(universe_name []byte, galaxy_name []byte, solar_name []byte) []byte {
+	bucket := make([]byte, 0, 3*binary.MaxVarintLen64+len(universe_name)+len(galaxy_name)+len(solar_name))
+	for _, name := range [][]byte{universe_name, galaxy_name, solar_name} {
+		var length [binary.MaxVarintLen64]byte
+		bucket = append(bucket, length[:binary.PutUvarint(length[:], uint64(len(name)))]...)
+		bucket = append(bucket, name...)
+	}
+	return bucket
+}
 
-	fullkey = append(fullkey, universe_name...)
-	fullkey = append(fullkey, galaxy_name...)
-	fullkey = append(fullkey, solar_name...)
-	fullkey = append(fullkey, key...)
+func (b *BadgerTXWrapper) StoreObject(universe_name []byte, galaxy_name []byte, solar_name []byte, key []byte, data []byte) (err error) {
+	fullkey := append(badger_bucket(universe_name, galaxy_name, solar_name), key...)
 	return b.tx.Set(fullkey, Duplicate(data))
 
 }
 
 func (b *BadgerTXWrapper) LoadObject(universe_name []byte, galaxy_name []byte, solar_name []byte, key []byte) (data []byte, err error) {
 
-	fullkey := make([]byte, 0, len(universe_name)+len(galaxy_name)+len(solar_name)+len(key))
-
-	fullkey = append(fullkey, universe_name...)
-	fullkey = append(fullkey, galaxy_name...)
-	fullkey = append(fullkey, solar_name...)
-	fullkey = append(fullkey, key...)
+	fullkey := append(badger_bucket(universe_name, galaxy_name, solar_name), key...)
 
 	item, err := b.tx.Get(fullkey)
 	if err == badger.ErrKeyNotFound {
@@ -183,7 +233,7 @@ func (b *BadgerTXWrapper) StoreUint64(universe_bucket []byte, galaxy_bucket []by
 
 }
 
-//  this function loads the data as 64 byte integer
+// this function loads the data as 64 byte integer
 func (b *BadgerTXWrapper) LoadUint64(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte, key []byte) (uint64, error) {
 	object_data, err := b.LoadObject(universe_bucket, galaxy_bucket, solar_bucket, key)
 	if err != nil {
@@ -201,3 +251,130 @@ func (b *BadgerTXWrapper) LoadUint64(universe_bucket []byte, galaxy_bucket []byt
 	value := binary.BigEndian.Uint64(object_data)
 	return value, nil
 }
+
+// badger does not have buckets, all keys are stored as bucket+key, see badger_bucket
+// so a bucket is nothing but a prefix, and cursor is restricted to keys having this prefix
+// since badger iterators are unidirectional, 2 iterators are used, one for each direction
+type BadgerCursor struct {
+	tx      *badger.Txn
+	bucket  []byte           // length prefixed universe+galaxy+solar
+	forward *badger.Iterator // created on demand
+	reverse *badger.Iterator // created on demand
+	current []byte           // full key where cursor is positioned, nil if not positioned
+	active  *badger.Iterator // iterator which is positioned at current key
+}
+
+func (b *BadgerTXWrapper) Cursor(universe_name []byte, galaxy_name []byte, solar_name []byte) (DBCursor, error) {
+	return &BadgerCursor{tx: b.tx, bucket: badger_bucket(universe_name, galaxy_name, solar_name)}, nil
+}
+
+func (b *BadgerTXWrapper) PrefixScan(universe_name []byte, galaxy_name []byte, solar_name []byte, prefix []byte, reverse bool, callback func(key []byte, value []byte) bool) error {
+	cursor, err := b.Cursor(universe_name, galaxy_name, solar_name)
+	if err != nil {
+		return err
+	}
+	return prefix_scan(cursor, prefix, reverse, callback)
+}
+
+func (c *BadgerCursor) get_iterator(reverse bool) *badger.Iterator {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchSize = 10
+	if reverse {
+		if c.reverse == nil {
+			opts.Reverse = true
+			c.reverse = c.tx.NewIterator(opts)
+		}
+		return c.reverse
+	}
+	if c.forward == nil {
+		c.forward = c.tx.NewIterator(opts)
+	}
+	return c.forward
+}
+
+// extract current key/value from the iterator, if it is still within the bucket
+func (c *BadgerCursor) fetch(it *badger.Iterator) (key []byte, value []byte) {
+	if !it.ValidForPrefix(c.bucket) {
+		c.current, c.active = nil, nil
+		return nil, nil
+	}
+
+	item := it.Item()
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		logger.Warnf("Error while reading value during iteration, err %s", err)
+		c.current, c.active = nil, nil
+		return nil, nil
+	}
+
+	c.current = item.KeyCopy(nil)
+	c.active = it
+	return Duplicate(c.current[len(c.bucket):]), value
+}
+
+func (c *BadgerCursor) First() (key []byte, value []byte) {
+	it := c.get_iterator(false)
+	it.Seek(c.bucket)
+	return c.fetch(it)
+}
+
+func (c *BadgerCursor) Last() (key []byte, value []byte) {
+	it := c.get_iterator(true)
+	upper := prefix_upper_bound(c.bucket)
+	if upper == nil {
+		it.Rewind()
+		return c.fetch(it)
+	}
+	it.Seek(upper) // reverse seek finds largest key <= upper
+	if it.Valid() && string(it.Item().Key()) == string(upper) {
+		it.Next()
+	}
+	return c.fetch(it)
+}
+
+func (c *BadgerCursor) Seek(seek []byte) (key []byte, value []byte) {
+	fullkey := make([]byte, 0, len(c.bucket)+len(seek))
+	fullkey = append(fullkey, c.bucket...)
+	fullkey = append(fullkey, seek...)
+
+	it := c.get_iterator(false)
+	it.Seek(fullkey)
+	return c.fetch(it)
+}
+
+func (c *BadgerCursor) Next() (key []byte, value []byte) {
+	return c.step(false)
+}
+
+func (c *BadgerCursor) Prev() (key []byte, value []byte) {
+	return c.step(true)
+}
+
+// move one step in specific direction, if direction has changed the other iterator is positioned at current key
+func (c *BadgerCursor) step(reverse bool) (key []byte, value []byte) {
+	if c.current == nil {
+		return nil, nil
+	}
+	it := c.get_iterator(reverse)
+	if c.active != it {
+		it.Seek(c.current)
+		if !it.Valid() || string(it.Item().Key()) != string(c.current) { // current key is skipped as it was already returned
+			return c.fetch(it)
+		}
+	}
+	it.Next()
+	return c.fetch(it)
+}
+
+// iterators must be closed before TX is discarded
+func (c *BadgerCursor) Close() {
+	if c.forward != nil {
+		c.forward.Close()
+		c.forward = nil
+	}
+	if c.reverse != nil {
+		c.reverse.Close()
+		c.reverse = nil
+	}
+	c.current, c.active = nil, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+
+package storage
+
+import "os"
+import "testing"
+import "io/ioutil"
+import "encoding/binary"
+
+import "github.com/dgraph-io/badger"
+
+// badger databases using another key layout must be refused
+func Test_Badger_Layout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger_layout_test")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir err %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	open := func() *badger.DB {
+		opts := badger.DefaultOptions
+		opts.Dir = dir
+		opts.ValueDir = dir
+		db, err := badger.Open(opts)
+		if err != nil {
+			t.Fatalf("Cannot open badger err %s", err)
+		}
+		return db
+	}
+	set := func(db *badger.DB, key []byte, value []byte) {
+		if err := db.Update(func(tx *badger.Txn) error { return tx.Set(key, value) }); err != nil {
+			t.Fatalf("Cannot store key err %s", err)
+		}
+	}
+	var version [8]byte
+
+	db := open()
+	defer func() { db.Close() }()
+
+	if err = badger_check_layout(db); err != nil {
+		t.Fatalf("new database must get current layout err %s", err)
+	}
+	if err = badger_check_layout(db); err != nil {
+		t.Fatalf("current layout must be accepted err %s", err)
+	}
+
+	binary.BigEndian.PutUint64(version[:], BADGER_LAYOUT_VERSION+1)
+	set(db, badger_layout_key, version[:])
+	if err = badger_check_layout(db); err == nil {
+		t.Fatalf("unknown layout version must be refused")
+	}
+
+	// unversioned database with keys stored as concatenated bucket names
+	if err = db.Update(func(tx *badger.Txn) error { return tx.Delete(badger_layout_key) }); err != nil {
+		t.Fatalf("Cannot delete layout err %s", err)
+	}
+	set(db, []byte("UGSkey"), []byte("value"))
+	if err = badger_check_layout(db); err == nil {
+		t.Fatalf("unversioned database must be refused")
+	}
+
+	// layout version survives reopening
+	db.Close()
+	os.RemoveAll(dir)
+	db = open()
+	if err = badger_check_layout(db); err != nil {
+		t.Fatalf("new database must get current layout err %s", err)
+	}
+	db.Close()
+	db = open()
+	if err = badger_check_layout(db); err != nil {
+		t.Fatalf("reopened database must be accepted err %s", err)
+	}
+}
@@ -350,6 +350,83 @@ func (b *BoltTXWrapper) LoadObjects(universe_name []byte, galaxy_name []byte, so
 
 }
 
+// bolt cursor wrapper, all keys/values are copied out of the mmap
+type BoltCursor struct {
+	bdb    *BoltStore
+	cursor *bolt.Cursor // nil if bucket does not exist
+	valid  bool         // whether cursor is positioned on a key
+}
+
+// returns a cursor over a specific bucket, if the bucket does not exist, an empty cursor is returned
+func (b *BoltStore) Cursor(tx *bolt.Tx, universe_name []byte, galaxy_name []byte, solar_name []byte) (DBCursor, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	c := &BoltCursor{bdb: b}
+	if universe := tx.Bucket(universe_name); universe != nil {
+		if galaxy := universe.Bucket(galaxy_name); galaxy != nil {
+			if solar := galaxy.Bucket(solar_name); solar != nil {
+				c.cursor = solar.Cursor()
+			}
+		}
+	}
+	return c, nil
+}
+
+func (b *BoltTXWrapper) Cursor(universe_name []byte, galaxy_name []byte, solar_name []byte) (DBCursor, error) {
+	return b.bdb.Cursor(b.tx, universe_name, galaxy_name, solar_name)
+}
+
+func (b *BoltTXWrapper) PrefixScan(universe_name []byte, galaxy_name []byte, solar_name []byte, prefix []byte, reverse bool, callback func(key []byte, value []byte) bool) error {
+	cursor, err := b.Cursor(universe_name, galaxy_name, solar_name)
+	if err != nil {
+		return err
+	}
+	return prefix_scan(cursor, prefix, reverse, callback)
+}
+
+// common function for all cursor movements, copies key/value and tracks validity
+func (c *BoltCursor) move(relative bool, mover func() ([]byte, []byte)) (key []byte, value []byte) {
+	if c.cursor == nil || (relative && !c.valid) {
+		return nil, nil
+	}
+
+	c.bdb.Lock()
+	k, v := mover()
+	c.bdb.Unlock()
+
+	if k == nil {
+		c.valid = false
+		return nil, nil
+	}
+	c.valid = true
+	return Duplicate(k), Duplicate(v)
+}
+
+func (c *BoltCursor) First() (key []byte, value []byte) {
+	return c.move(false, func() ([]byte, []byte) { return c.cursor.First() })
+}
+
+func (c *BoltCursor) Last() (key []byte, value []byte) {
+	return c.move(false, func() ([]byte, []byte) { return c.cursor.Last() })
+}
+
+func (c *BoltCursor) Next() (key []byte, value []byte) {
+	return c.move(true, func() ([]byte, []byte) { return c.cursor.Next() })
+}
+
+func (c *BoltCursor) Prev() (key []byte, value []byte) {
+	return c.move(true, func() ([]byte, []byte) { return c.cursor.Prev() })
+}
+
+func (c *BoltCursor) Seek(seek []byte) (key []byte, value []byte) {
+	return c.move(false, func() ([]byte, []byte) { return c.cursor.Seek(seek) })
+}
+
+// bolt cursors do not hold any resources
+func (c *BoltCursor) Close() {
+}
+
 // this function stores a uint64
 // this will automcatically use the lock
 func (b *BoltTXWrapper) StoreUint64(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte, key []byte, data uint64) error {
@@ -357,7 +434,7 @@ func (b *BoltTXWrapper) StoreUint64(universe_bucket []byte, galaxy_bucket []byte
 
 }
 
-//  this function loads the data as 64 byte integer
+// this function loads the data as 64 byte integer
 func (b *BoltTXWrapper) LoadUint64(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte, key []byte) (uint64, error) {
 	object_data, err := b.LoadObject(universe_bucket, galaxy_bucket, solar_bucket, key)
 	if err != nil {
@@ -0,0 +1,69 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package storage
+
+import "bytes"
+
+// this file contains the backend independent part of iteration
+// backends only need to provide a DBCursor, prefix scans are built on top of it
+// so as all backends behave identically
+
+// iterate over all keys of a bucket having the specific prefix, in forward or reverse order
+// iteration stops as soon as callback returns false
+// empty prefix iterates over the entire bucket
+func prefix_scan(cursor DBCursor, prefix []byte, reverse bool, callback func(key []byte, value []byte) bool) error {
+	defer cursor.Close()
+
+	var k, v []byte
+	if !reverse {
+		k, v = cursor.Seek(prefix)
+	} else { // position at last key having this prefix
+		upper := prefix_upper_bound(prefix)
+		if upper == nil { // prefix is empty or all 0xff, so last key is the last candidate
+			k, v = cursor.Last()
+		} else if k, v = cursor.Seek(upper); k == nil {
+			k, v = cursor.Last()
+		} else {
+			k, v = cursor.Prev()
+		}
+	}
+
+	for k != nil && bytes.HasPrefix(k, prefix) {
+		if !callback(k, v) {
+			break
+		}
+		if reverse {
+			k, v = cursor.Prev()
+		} else {
+			k, v = cursor.Next()
+		}
+	}
+	return nil
+}
+
+// returns the smallest key which is greater than all keys having this prefix
+// returns nil if no such key exists ( prefix is empty or contains only 0xff)
+func prefix_upper_bound(prefix []byte) []byte {
+	upper := Duplicate(prefix)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] != 0xff {
+			upper[i]++
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+
+package storage
+
+import "os"
+import "sort"
+import "bytes"
+import "testing"
+import "io/ioutil"
+
+import log "github.com/sirupsen/logrus"
+
+import "github.com/deroproject/derosuite/globals"
+
+// conformance tests for iteration, all backends must produce identical results
+
+var conformance_keys = [][]byte{[]byte("a"), []byte("ab"), []byte("abc"), []byte("abd"), []byte("b"), []byte("ba"), []byte("c"),
+	{0x00}, {0x7f, 0xff}, {0xff}, {0xff, 0xff}, {0xff, 0xff, 0x01}}
+
+var universe = []byte("U")
+var galaxy = []byte("G")
+var solar = []byte("S")
+var solar_other = []byte("T") // must not be visible while iterating solar
+
+// sorted copy of the keys, which is the expected iteration order
+func sorted_keys() (keys [][]byte) {
+	for i := range conformance_keys {
+		keys = append(keys, conformance_keys[i])
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return
+}
+
+func value_for(key []byte) []byte {
+	return append([]byte("value_"), key...)
+}
+
+// opens both backends in a temporary data directory
+func setup_backends(t *testing.T) (stores map[string]Store, cleanup func()) {
+	dir, err := ioutil.TempDir("", "storage_test")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir err %s", err)
+	}
+
+	globals.Logger = log.New()
+	globals.Arguments = map[string]interface{}{"--data-dir": dir}
+	if err = os.MkdirAll(globals.GetDataDirectory(), 0700); err != nil {
+		t.Fatalf("Cannot create data dir err %s", err)
+	}
+
+	bolt_store := &BoltStore{}
+	badger_store := &BadgerDBStore{}
+	memory_store := &MemoryStore{}
+	stores = map[string]Store{"boltdb": bolt_store, "badgerdb": badger_store, "memorydb": memory_store}
+	for name, store := range stores {
+		if err = store.Init(map[string]interface{}{}); err != nil {
+			t.Fatalf("%s init failed err %s", name, err)
+		}
+	}
+
+	cleanup = func() {
+		for _, store := range stores {
+			store.Shutdown()
+		}
+		os.RemoveAll(dir)
+	}
+	return
+}
+
+func populate(t *testing.T, name string, dbtx DBTX) {
+	for _, k := range conformance_keys {
+		if err := dbtx.StoreObject(universe, galaxy, solar, k, value_for(k)); err != nil {
+			t.Fatalf("%s store failed err %s", name, err)
+		}
+		if err := dbtx.StoreObject(universe, galaxy, solar_other, k, []byte("other")); err != nil {
+			t.Fatalf("%s store failed err %s", name, err)
+		}
+	}
+}
+
+// collect keys by walking the cursor in specific direction
+func walk(cursor DBCursor, reverse bool) (keys [][]byte, values [][]byte) {
+	var k, v []byte
+	if reverse {
+		k, v = cursor.Last()
+	} else {
+		k, v = cursor.First()
+	}
+	for k != nil {
+		keys = append(keys, k)
+		values = append(values, v)
+		if reverse {
+			k, v = cursor.Prev()
+		} else {
+			k, v = cursor.Next()
+		}
+	}
+	return
+}
+
+func compare_keys(t *testing.T, name string, what string, actual [][]byte, expected [][]byte) {
+	if len(actual) != len(expected) {
+		t.Errorf("%s %s: expected %d keys, actual %d keys %x", name, what, len(expected), len(actual), actual)
+		return
+	}
+	for i := range expected {
+		if !bytes.Equal(actual[i], expected[i]) {
+			t.Errorf("%s %s: key %d expected %x actual %x", name, what, i, expected[i], actual[i])
+		}
+	}
+}
+
+func reversed(keys [][]byte) (result [][]byte) {
+	for i := len(keys) - 1; i >= 0; i-- {
+		result = append(result, keys[i])
+	}
+	return
+}
+
+func with_prefix(keys [][]byte, prefix []byte) (result [][]byte) {
+	for i := range keys {
+		if bytes.HasPrefix(keys[i], prefix) {
+			result = append(result, keys[i])
+		}
+	}
+	return
+}
+
+// runs all iteration checks against a tx
+func check_iteration(t *testing.T, name string, dbtx DBTX) {
+	expected := sorted_keys()
+
+	cursor, err := dbtx.Cursor(universe, galaxy, solar)
+	if err != nil {
+		t.Fatalf("%s cursor failed err %s", name, err)
+	}
+	defer cursor.Close()
+
+	keys, values := walk(cursor, false)
+	compare_keys(t, name, "forward", keys, expected)
+	for i := range keys {
+		if !bytes.Equal(values[i], value_for(keys[i])) {
+			t.Errorf("%s forward: key %x has invalid value %x", name, keys[i], values[i])
+		}
+	}
+
+	keys, _ = walk(cursor, true)
+	compare_keys(t, name, "reverse", keys, reversed(expected))
+
+	// once past the end, cursor must not move without repositioning
+	cursor.Last()
+	if k, _ := cursor.Next(); k != nil {
+		t.Errorf("%s: Next after Last must return nil, actual %x", name, k)
+	}
+	if k, _ := cursor.Prev(); k != nil {
+		t.Errorf("%s: Prev after end must return nil, actual %x", name, k)
+	}
+
+	// seek semantics, first key >= seek
+	seeks := []struct {
+		seek     []byte
+		expected []byte
+	}{
+		{nil, []byte{0x00}},
+		{[]byte("a"), []byte("a")},
+		{[]byte("abcd"), []byte("abd")},
+		{[]byte("b"), []byte("b")},
+		{[]byte("bb"), []byte("c")},
+		{[]byte{0x80}, []byte{0xff}},
+		{[]byte{0xff, 0xff, 0x02}, nil},
+	}
+	for _, s := range seeks {
+		if k, _ := cursor.Seek(s.seek); !bytes.Equal(k, s.expected) {
+			t.Errorf("%s: Seek %x expected %x actual %x", name, s.seek, s.expected, k)
+		}
+	}
+
+	// direction changes
+	cursor.Seek([]byte("b"))
+	if k, _ := cursor.Prev(); !bytes.Equal(k, []byte("abd")) {
+		t.Errorf("%s: Prev after Seek expected %x actual %x", name, "abd", k)
+	}
+	if k, _ := cursor.Next(); !bytes.Equal(k, []byte("b")) {
+		t.Errorf("%s: Next after Prev expected %x actual %x", name, "b", k)
+	}
+	if k, _ := cursor.Next(); !bytes.Equal(k, []byte("ba")) {
+		t.Errorf("%s: Next after Next expected %x actual %x", name, "ba", k)
+	}
+	if k, _ := cursor.Prev(); !bytes.Equal(k, []byte("b")) {
+		t.Errorf("%s: Prev after Next expected %x actual %x", name, "b", k)
+	}
+
+	// prefix scans in both directions
+	prefixes := [][]byte{nil, []byte("a"), []byte("ab"), []byte("abc"), []byte("b"), []byte("z"), {0xff}, {0xff, 0xff}, {0x7f}}
+	for _, prefix := range prefixes {
+		for _, reverse := range []bool{false, true} {
+			var scanned [][]byte
+			err := dbtx.PrefixScan(universe, galaxy, solar, prefix, reverse, func(k, v []byte) bool {
+				scanned = append(scanned, k)
+				return true
+			})
+			if err != nil {
+				t.Errorf("%s prefix scan %x failed err %s", name, prefix, err)
+			}
+			want := with_prefix(expected, prefix)
+			if reverse {
+				want = reversed(want)
+			}
+			compare_keys(t, name, "prefix scan "+string(prefix), scanned, want)
+		}
+	}
+
+	// callback can stop the scan
+	count := 0
+	dbtx.PrefixScan(universe, galaxy, solar, []byte("a"), true, func(k, v []byte) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Errorf("%s: prefix scan did not stop, callback called %d times", name, count)
+	}
+
+	// missing buckets are empty
+	empty, err := dbtx.Cursor(universe, galaxy, []byte("missing"))
+	if err != nil {
+		t.Fatalf("%s cursor on missing bucket failed err %s", name, err)
+	}
+	if k, _ := empty.First(); k != nil {
+		t.Errorf("%s: missing bucket returned key %x", name, k)
+	}
+	if k, _ := empty.Last(); k != nil {
+		t.Errorf("%s: missing bucket returned key %x", name, k)
+	}
+	empty.Close()
+}
+
+func Test_Iteration_Conformance(t *testing.T) {
+	stores, cleanup := setup_backends(t)
+	defer cleanup()
+
+	for name, store := range stores {
+		dbtx, err := store.BeginTX(true)
+		if err != nil {
+			t.Fatalf("%s begin tx failed err %s", name, err)
+		}
+		populate(t, name, dbtx)
+
+		check_iteration(t, name, dbtx) // uncommitted writes must be visible within the tx
+		if err = dbtx.Commit(); err != nil {
+			t.Fatalf("%s commit failed err %s", name, err)
+		}
+
+		dbtx, err = store.BeginTX(false)
+		if err != nil {
+			t.Fatalf("%s begin tx failed err %s", name, err)
+		}
+		check_iteration(t, name, dbtx)
+		dbtx.Rollback()
+	}
+}
+
+// a bucket whose name is a prefix of another bucket name must not see keys of the other bucket
+func Test_Iteration_Bucket_Names(t *testing.T) {
+	stores, cleanup := setup_backends(t)
+	defer cleanup()
+
+	for name, store := range stores {
+		dbtx, err := store.BeginTX(true)
+		if err != nil {
+			t.Fatalf("%s begin tx failed err %s", name, err)
+		}
+		dbtx.StoreObject(universe, galaxy, []byte("a"), []byte("key"), []byte("value_a"))
+		dbtx.StoreObject(universe, galaxy, []byte("ab"), []byte("key"), []byte("value_ab"))
+		dbtx.StoreObject(universe, galaxy, []byte("ab"), []byte("bkey"), []byte("value_ab"))
+		dbtx.StoreObject(universe, []byte("Ga"), []byte("b"), []byte("key"), []byte("value_Ga"))
+		if err = dbtx.Commit(); err != nil {
+			t.Fatalf("%s commit failed err %s", name, err)
+		}
+
+		dbtx, _ = store.BeginTX(false)
+		for _, solar := range [][]byte{[]byte("a"), []byte("ab")} {
+			cursor, err := dbtx.Cursor(universe, galaxy, solar)
+			if err != nil {
+				t.Fatalf("%s cursor failed err %s", name, err)
+			}
+			keys, values := walk(cursor, false)
+			cursor.Close()
+
+			expected := [][]byte{[]byte("key")}
+			if string(solar) == "ab" {
+				expected = [][]byte{[]byte("bkey"), []byte("key")}
+			}
+			compare_keys(t, name, "bucket "+string(solar), keys, expected)
+			for i := range values {
+				if string(values[i]) != "value_"+string(solar) {
+					t.Errorf("%s bucket %s: key %s has value %s of another bucket", name, solar, keys[i], values[i])
+				}
+			}
+
+			var scanned [][]byte
+			dbtx.PrefixScan(universe, galaxy, solar, nil, true, func(k, v []byte) bool {
+				scanned = append(scanned, k)
+				return true
+			})
+			compare_keys(t, name, "prefix scan bucket "+string(solar), scanned, reversed(expected))
+		}
+
+		// same at galaxy level, G+ab must not be mixed up with Ga+b
+		cursor, _ := dbtx.Cursor(universe, []byte("Ga"), []byte("b"))
+		keys, _ := walk(cursor, false)
+		cursor.Close()
+		compare_keys(t, name, "galaxy Ga", keys, [][]byte{[]byte("key")})
+		dbtx.Rollback()
+	}
+}
+
+// both backends must return the same results for the same data
+func Test_Iteration_Backends_Identical(t *testing.T) {
+	stores, cleanup := setup_backends(t)
+	defer cleanup()
+
+	results := map[string][][]byte{}
+	for name, store := range stores {
+		dbtx, _ := store.BeginTX(true)
+		populate(t, name, dbtx)
+		dbtx.Commit()
+
+		dbtx, _ = store.BeginTX(false)
+		dbtx.PrefixScan(universe, galaxy, solar, nil, false, func(k, v []byte) bool {
+			results[name] = append(results[name], k, v)
+			return true
+		})
+		dbtx.Rollback()
+	}
+
+	compare_keys(t, "backends", "identical", results["badgerdb"], results["boltdb"])
+	compare_keys(t, "backends", "identical", results["memorydb"], results["boltdb"])
+}
@@ -38,6 +38,23 @@ type DBTX interface {
 	LoadUint64(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte, key []byte) (uint64, error)     // load object
 	// CreateBucket(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte) error // creates an object bucket
 
+	Cursor(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte) (DBCursor, error)                                                                    // cursor over all keys of a specific bucket
+	PrefixScan(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte, prefix []byte, reverse bool, callback func(key []byte, value []byte) bool) error // iterate keys with prefix, stops if callback returns false
+}
+
+// cursor style iteration over a single bucket (universe/galaxy/solar), keys are returned in sorted byte order
+// keys returned are relative to the bucket, ie they do not contain universe/galaxy/solar names
+// keys/values returned are copies and remain valid even after the TX has been closed
+// a nil key means the cursor has moved past either end ( or the bucket is empty/does not exist)
+// once a nil key is returned, Next/Prev keep returning nil till the cursor is positioned again using First/Last/Seek
+// cursor must be closed before the TX is committed or rolled back
+type DBCursor interface {
+	First() (key []byte, value []byte)           // move to first key
+	Last() (key []byte, value []byte)            // move to last key
+	Next() (key []byte, value []byte)            // move to next key
+	Prev() (key []byte, value []byte)            // move to previous key
+	Seek(seek []byte) (key []byte, value []byte) // move to the first key which is >= seek
+	Close()                                      // release any resources held by the cursor
 }
 
 type Store interface {
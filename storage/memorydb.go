@@ -0,0 +1,397 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license.
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+//
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND ANY
+// EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL
+// THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+// PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT,
+// STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF
+// THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package storage
+
+import "fmt"
+import "sort"
+import "sync"
+import "encoding/binary"
+
+import log "github.com/sirupsen/logrus"
+
+import "github.com/deroproject/derosuite/globals"
+
+// this file implements an in-memory backend, nothing is ever written to disk
+// it is used for tests, simulation and throwaway nodes
+// semantics match boltdb
+// only a single writable tx can exist at a time, other writers block till it is committed/rolled back
+// readable TX see a consistent snapshot as of the time they were started
+// writes are only visible to other TX after Commit, Rollback discards them
+
+type MemoryStore struct {
+	sync.RWMutex            // protects all fields below
+	writer       sync.Mutex // held by the writable tx
+
+	version   uint64                       // last committed version
+	universes map[string]uint64            // universe name and version at which it was created
+	galaxies  map[[2]string]uint64         // galaxy name and version at which it was created
+	buckets   map[[3]string]*memory_bucket // solar buckets
+	readers   map[uint64]int               // snapshots in use by readable tx, used to discard old versions
+}
+
+// a solar bucket, every key keeps all versions which may still be visible to some TX
+type memory_bucket struct {
+	version uint64                      // version at which bucket was created
+	keys    []string                    // sorted keys
+	values  map[string][]memory_version // oldest version first
+}
+
+type memory_version struct {
+	version uint64
+	data    []byte
+}
+
+// this object is returned
+type MemoryTXWrapper struct {
+	mdb      *MemoryStore
+	version  uint64                          // snapshot which this TX reads
+	writable bool                            // whether the tx can store objects
+	closed   bool                            // tx has been committed or rolled back
+	pending  map[[3]string]map[string][]byte // uncommitted writes
+}
+
+var Memory_backend *MemoryStore = &MemoryStore{} // global variable
+
+func (m *MemoryStore) Init(params map[string]interface{}) (err error) {
+	logger = globals.Logger.WithFields(log.Fields{"com": "STORE"})
+	logger.Infof("Initializing memory store, data will be lost on exit")
+
+	m.Lock()
+	m.version = 0
+	m.universes = map[string]uint64{}
+	m.galaxies = map[[2]string]uint64{}
+	m.buckets = map[[3]string]*memory_bucket{}
+	m.readers = map[uint64]int{}
+	m.Unlock()
+	return nil
+}
+
+func (m *MemoryStore) Shutdown() (err error) {
+	logger.Infof("Shutting memory store")
+	m.Lock()
+	m.universes = nil
+	m.galaxies = nil
+	m.buckets = nil
+	m.Unlock()
+	return nil
+}
+
+// writable tx blocks till any other writable tx is finished, as in boltdb
+func (m *MemoryStore) BeginTX(writable bool) (DBTX, error) {
+	if writable {
+		m.writer.Lock()
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.buckets == nil {
+		if writable {
+			m.writer.Unlock()
+		}
+		return nil, fmt.Errorf("memory store is not initialized")
+	}
+
+	txwrapper := &MemoryTXWrapper{mdb: m, version: m.version, writable: writable}
+	if writable {
+		txwrapper.pending = map[[3]string]map[string][]byte{}
+	} else {
+		m.readers[m.version]++
+	}
+	return txwrapper, nil
+}
+
+// apply all pending writes atomically as a new version
+func (b *MemoryTXWrapper) Commit() error {
+	if b.closed {
+		return fmt.Errorf("tx closed")
+	}
+	if !b.writable {
+		return fmt.Errorf("tx not writable")
+	}
+
+	m := b.mdb
+	m.Lock()
+	version := m.version + 1
+	oldest := version // versions older than this are not visible to anyone
+	for v := range m.readers {
+		if v < oldest {
+			oldest = v
+		}
+	}
+
+	for name, objects := range b.pending {
+		if _, ok := m.universes[name[0]]; !ok {
+			m.universes[name[0]] = version
+		}
+		if _, ok := m.galaxies[[2]string{name[0], name[1]}]; !ok {
+			m.galaxies[[2]string{name[0], name[1]}] = version
+		}
+		bucket, ok := m.buckets[name]
+		if !ok {
+			bucket = &memory_bucket{version: version, values: map[string][]memory_version{}}
+			m.buckets[name] = bucket
+		}
+
+		for key, data := range objects {
+			versions, ok := bucket.values[key]
+			if !ok { // new key, keep keys sorted
+				i := sort.SearchStrings(bucket.keys, key)
+				bucket.keys = append(bucket.keys, "")
+				copy(bucket.keys[i+1:], bucket.keys[i:])
+				bucket.keys[i] = key
+			}
+			versions = append(versions, memory_version{version: version, data: data})
+
+			// discard versions which are shadowed for all readers
+			for len(versions) >= 2 && versions[1].version <= oldest {
+				versions = versions[1:]
+			}
+			bucket.values[key] = versions
+		}
+	}
+	m.version = version
+	m.Unlock()
+
+	b.close()
+	return nil
+}
+
+// discard all changes
+func (b *MemoryTXWrapper) Rollback() {
+	if b.closed {
+		return
+	}
+	b.close()
+}
+
+func (b *MemoryTXWrapper) close() {
+	b.closed = true
+	b.pending = nil
+	if b.writable {
+		b.mdb.writer.Unlock()
+	} else {
+		b.mdb.Lock()
+		if b.mdb.readers[b.version]--; b.mdb.readers[b.version] <= 0 {
+			delete(b.mdb.readers, b.version)
+		}
+		b.mdb.Unlock()
+	}
+}
+
+// nothing to sync
+func (b *MemoryTXWrapper) Sync() {
+}
+
+func (b *MemoryTXWrapper) StoreObject(universe_name []byte, galaxy_name []byte, solar_name []byte, key []byte, data []byte) (err error) {
+	if b.closed {
+		return fmt.Errorf("tx closed")
+	}
+	if !b.writable {
+		return fmt.Errorf("tx not writable")
+	}
+	if len(universe_name) == 0 || len(galaxy_name) == 0 || len(solar_name) == 0 {
+		return fmt.Errorf("bucket name required")
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("key required")
+	}
+
+	name := [3]string{string(universe_name), string(galaxy_name), string(solar_name)}
+	objects, ok := b.pending[name]
+	if !ok {
+		objects = map[string][]byte{}
+		b.pending[name] = objects
+	}
+	objects[string(key)] = Duplicate(data)
+	return nil
+}
+
+// find the value of a key as visible to this tx, m must be read locked
+func (b *MemoryTXWrapper) visible(bucket *memory_bucket, key string) (data []byte, ok bool) {
+	versions := bucket.values[key]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].version <= b.version {
+			return versions[i].data, true
+		}
+	}
+	return nil, false
+}
+
+// checks whether bucket is visible to this tx and returns error similar to boltdb, m must be read locked
+func (b *MemoryTXWrapper) check_bucket(name [3]string) error {
+	if _, ok := b.pending[name]; ok {
+		return nil
+	}
+	pending_universe, pending_galaxy := false, false
+	for pname := range b.pending {
+		if pname[0] == name[0] {
+			pending_universe = true
+			if pname[1] == name[1] {
+				pending_galaxy = true
+			}
+		}
+	}
+
+	if v, ok := b.mdb.universes[name[0]]; !pending_universe && (!ok || v > b.version) {
+		return fmt.Errorf("No Such Universe %x", name[0])
+	}
+	if v, ok := b.mdb.galaxies[[2]string{name[0], name[1]}]; !pending_galaxy && (!ok || v > b.version) {
+		return fmt.Errorf("No Such Bucket %x", name[1])
+	}
+	if bucket, ok := b.mdb.buckets[name]; !ok || bucket.version > b.version {
+		return fmt.Errorf("No Such Bucket %x", name[2])
+	}
+	return nil
+}
+
+func (b *MemoryTXWrapper) LoadObject(universe_name []byte, galaxy_name []byte, solar_name []byte, key []byte) (data []byte, err error) {
+	if b.closed {
+		return data, fmt.Errorf("tx closed")
+	}
+
+	name := [3]string{string(universe_name), string(galaxy_name), string(solar_name)}
+	if value, ok := b.pending[name][string(key)]; ok {
+		return Duplicate(value), nil
+	}
+
+	b.mdb.RLock()
+	defer b.mdb.RUnlock()
+
+	if err = b.check_bucket(name); err != nil {
+		return
+	}
+
+	// as in boltdb, missing key in an existing bucket is not an error, it returns empty data
+	if bucket, ok := b.mdb.buckets[name]; ok {
+		if value, ok := b.visible(bucket, string(key)); ok {
+			return Duplicate(value), nil
+		}
+	}
+	return []byte{}, nil
+}
+
+// this function stores a uint64
+func (b *MemoryTXWrapper) StoreUint64(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte, key []byte, data uint64) error {
+	return b.StoreObject(universe_bucket, galaxy_bucket, solar_bucket, key, itob(data))
+}
+
+// this function loads the data as 64 byte integer
+func (b *MemoryTXWrapper) LoadUint64(universe_bucket []byte, galaxy_bucket []byte, solar_bucket []byte, key []byte) (uint64, error) {
+	object_data, err := b.LoadObject(universe_bucket, galaxy_bucket, solar_bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(object_data) == 0 {
+		return 0, fmt.Errorf("No value stored here, we should look more")
+	}
+
+	if len(object_data) != 8 {
+		panic("Database corruption, invalid data ")
+	}
+
+	return binary.BigEndian.Uint64(object_data), nil
+}
+
+// cursor takes a snapshot of the bucket as visible to this tx, including uncommitted writes
+func (b *MemoryTXWrapper) Cursor(universe_name []byte, galaxy_name []byte, solar_name []byte) (DBCursor, error) {
+	if b.closed {
+		return nil, fmt.Errorf("tx closed")
+	}
+
+	name := [3]string{string(universe_name), string(galaxy_name), string(solar_name)}
+	objects := map[string][]byte{}
+
+	b.mdb.RLock()
+	if bucket, ok := b.mdb.buckets[name]; ok {
+		for _, key := range bucket.keys {
+			if value, ok := b.visible(bucket, key); ok {
+				objects[key] = value
+			}
+		}
+	}
+	b.mdb.RUnlock()
+
+	for key, value := range b.pending[name] {
+		objects[key] = value
+	}
+
+	c := &MemoryCursor{index: -1}
+	for key := range objects {
+		c.keys = append(c.keys, key)
+	}
+	sort.Strings(c.keys)
+	for _, key := range c.keys {
+		c.values = append(c.values, objects[key])
+	}
+	return c, nil
+}
+
+func (b *MemoryTXWrapper) PrefixScan(universe_name []byte, galaxy_name []byte, solar_name []byte, prefix []byte, reverse bool, callback func(key []byte, value []byte) bool) error {
+	cursor, err := b.Cursor(universe_name, galaxy_name, solar_name)
+	if err != nil {
+		return err
+	}
+	return prefix_scan(cursor, prefix, reverse, callback)
+}
+
+// cursor over a sorted snapshot of keys
+type MemoryCursor struct {
+	keys   []string
+	values [][]byte
+	index  int // -1 if not positioned
+}
+
+func (c *MemoryCursor) at(index int) (key []byte, value []byte) {
+	if index < 0 || index >= len(c.keys) {
+		c.index = -1
+		return nil, nil
+	}
+	c.index = index
+	return []byte(c.keys[index]), Duplicate(c.values[index])
+}
+
+func (c *MemoryCursor) First() (key []byte, value []byte) {
+	return c.at(0)
+}
+
+func (c *MemoryCursor) Last() (key []byte, value []byte) {
+	return c.at(len(c.keys) - 1)
+}
+
+func (c *MemoryCursor) Next() (key []byte, value []byte) {
+	if c.index < 0 {
+		return nil, nil
+	}
+	return c.at(c.index + 1)
+}
+
+func (c *MemoryCursor) Prev() (key []byte, value []byte) {
+	if c.index < 0 {
+		return nil, nil
+	}
+	return c.at(c.index - 1)
+}
+
+func (c *MemoryCursor) Seek(seek []byte) (key []byte, value []byte) {
+	return c.at(sort.SearchStrings(c.keys, string(seek)))
+}
+
+func (c *MemoryCursor) Close() {
+	c.index = -1
+}
@@ -0,0 +1,159 @@
+// Copyright 2017-2018 DERO Project. All rights reserved.
+// Use of this source code in any form is governed by RESEARCH license
+// license can be found in the LICENSE file.
+// GPG: 0F39 E425 8C65 3947 702A  8234 08B2 0360 A03A 9DE8
+
+package storage
+
+import "bytes"
+import "testing"
+
+import log "github.com/sirupsen/logrus"
+
+import "github.com/deroproject/derosuite/globals"
+
+// memory backend must follow boltdb commit/rollback semantics
+// returns a description of every observation, so as backends can be compared
+func tx_semantics(t *testing.T, store Store) (observations []string) {
+	observe := func(what string, data []byte, err error) {
+		observations = append(observations, what+":"+string(data)+":"+boolstr(err != nil))
+	}
+
+	// rollback must discard everything
+	dbtx, _ := store.BeginTX(true)
+	dbtx.StoreObject(universe, galaxy, solar, []byte("k1"), []byte("v1"))
+	data, err := dbtx.LoadObject(universe, galaxy, solar, []byte("k1"))
+	observe("uncommitted read", data, err)
+	dbtx.Rollback()
+
+	dbtx, _ = store.BeginTX(false)
+	data, err = dbtx.LoadObject(universe, galaxy, solar, []byte("k1"))
+	observe("after rollback", data, err)
+	dbtx.Rollback()
+
+	// commit must make everything visible
+	dbtx, _ = store.BeginTX(true)
+	dbtx.StoreObject(universe, galaxy, solar, []byte("k1"), []byte("v1"))
+	dbtx.StoreUint64(universe, galaxy, solar, []byte("u1"), 99)
+	err = dbtx.Commit()
+	observe("commit", nil, err)
+
+	dbtx, _ = store.BeginTX(false)
+	data, err = dbtx.LoadObject(universe, galaxy, solar, []byte("k1"))
+	observe("after commit", data, err)
+	value, err := dbtx.LoadUint64(universe, galaxy, solar, []byte("u1"))
+	observe("uint64", []byte{byte(value)}, err)
+	data, err = dbtx.LoadObject(universe, galaxy, solar, []byte("missing"))
+	observe("missing key", data, err)
+	_, err = dbtx.LoadUint64(universe, galaxy, solar, []byte("missing"))
+	observe("missing uint64", nil, err)
+	data, err = dbtx.LoadObject(universe, galaxy, []byte("missing"), []byte("k1"))
+	observe("missing solar", data, err)
+	data, err = dbtx.LoadObject(universe, []byte("missing"), solar, []byte("k1"))
+	observe("missing galaxy", data, err)
+	data, err = dbtx.LoadObject([]byte("missing"), galaxy, solar, []byte("k1"))
+	observe("missing universe", data, err)
+	err = dbtx.StoreObject(universe, galaxy, solar, []byte("k2"), []byte("v2"))
+	observe("store in readable tx", nil, err)
+	err = dbtx.Commit()
+	observe("commit readable tx", nil, err)
+	dbtx.Rollback()
+
+	// readable tx keeps seeing the snapshot from the time it was started
+	reader, _ := store.BeginTX(false)
+	dbtx, _ = store.BeginTX(true)
+	dbtx.StoreObject(universe, galaxy, solar, []byte("k1"), []byte("v1 modified"))
+	dbtx.StoreObject(universe, galaxy, []byte("new solar"), []byte("k1"), []byte("v1"))
+	dbtx.Commit()
+
+	data, err = reader.LoadObject(universe, galaxy, solar, []byte("k1"))
+	observe("snapshot", data, err)
+	data, err = reader.LoadObject(universe, galaxy, []byte("new solar"), []byte("k1"))
+	observe("snapshot new solar", data, err)
+	reader.Rollback()
+
+	dbtx, _ = store.BeginTX(false)
+	data, err = dbtx.LoadObject(universe, galaxy, solar, []byte("k1"))
+	observe("latest", data, err)
+	dbtx.Rollback()
+
+	// committing twice is an error
+	dbtx, _ = store.BeginTX(true)
+	dbtx.Commit()
+	err = dbtx.Commit()
+	observe("double commit", nil, err)
+
+	return
+}
+
+func boolstr(b bool) string {
+	if b {
+		return "error"
+	}
+	return "ok"
+}
+
+func Test_Memory_TX_Semantics(t *testing.T) {
+	stores, cleanup := setup_backends(t)
+	defer cleanup()
+
+	bolt_observations := tx_semantics(t, stores["boltdb"])
+	memory_observations := tx_semantics(t, stores["memorydb"])
+
+	if len(bolt_observations) != len(memory_observations) {
+		t.Fatalf("observation count mismatch bolt %d memory %d", len(bolt_observations), len(memory_observations))
+	}
+	for i := range bolt_observations {
+		if bolt_observations[i] != memory_observations[i] {
+			t.Errorf("boltdb \"%s\" memorydb \"%s\"", bolt_observations[i], memory_observations[i])
+		}
+	}
+
+	// sanity check the expectations themselves
+	expected := map[int]string{0: "uncommitted read:v1:ok", 1: "after rollback::error", 3: "after commit:v1:ok", 5: "missing key::ok", 12: "snapshot:v1:ok", 13: "snapshot new solar::error", 14: "latest:v1 modified:ok"}
+	for i, e := range expected {
+		if memory_observations[i] != e {
+			t.Errorf("observation %d expected \"%s\" actual \"%s\"", i, e, memory_observations[i])
+		}
+	}
+}
+
+// old versions must be discarded once no readable tx can see them
+func Test_Memory_Version_Pruning(t *testing.T) {
+	var store MemoryStore
+	globals.Logger = log.New()
+	store.Init(nil)
+
+	for i := 0; i < 100; i++ {
+		dbtx, _ := store.BeginTX(true)
+		dbtx.StoreUint64(universe, galaxy, solar, []byte("counter"), uint64(i))
+		dbtx.Commit()
+	}
+	if versions := len(store.buckets[[3]string{string(universe), string(galaxy), string(solar)}].values["counter"]); versions != 1 {
+		t.Errorf("expected 1 version actual %d", versions)
+	}
+
+	reader, _ := store.BeginTX(false)
+	for i := 0; i < 10; i++ {
+		dbtx, _ := store.BeginTX(true)
+		dbtx.StoreUint64(universe, galaxy, solar, []byte("counter"), uint64(1000+i))
+		dbtx.Commit()
+	}
+	if value, _ := reader.LoadUint64(universe, galaxy, solar, []byte("counter")); value != 99 {
+		t.Errorf("reader expected 99 actual %d", value)
+	}
+	reader.Rollback()
+
+	dbtx, _ := store.BeginTX(true)
+	dbtx.StoreObject(universe, galaxy, solar, []byte("counter"), itob(7))
+	dbtx.Commit()
+	if versions := len(store.buckets[[3]string{string(universe), string(galaxy), string(solar)}].values["counter"]); versions != 1 {
+		t.Errorf("expected 1 version after reader finished, actual %d", versions)
+	}
+
+	dbtx, _ = store.BeginTX(false)
+	if data, _ := dbtx.LoadObject(universe, galaxy, solar, []byte("counter")); !bytes.Equal(data, itob(7)) {
+		t.Errorf("expected 7 actual %x", data)
+	}
+	dbtx.Rollback()
+}
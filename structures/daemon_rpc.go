@@ -126,6 +126,14 @@ type (
 	GetTxPool_Result struct {
 		Tx_list []string `json:"txs,omitempty"`
 		Status  string   `json:"status"`
+
+		Evicted []TxPool_Evicted `json:"evicted,omitempty"` // most recent txs removed without being mined, oldest first
+	}
+	TxPool_Evicted struct {
+		TXID   string `json:"txid"`
+		Reason string `json:"reason"` // replaced, pool_full, key_image_spent or expired
+		Detail string `json:"detail,omitempty"`
+		Time   int64  `json:"time"`
 	}
 )
 
@@ -176,6 +184,23 @@ type (
 		SCBalance      uint64                     `json:"sc_balance"`
 		SC             dvm.SmartContract          `json:"sc"`
 		SCRAW          string                     `json:"sc_raw"`
+		Pruned         bool                       `json:"pruned"`                  // prunable data has been discarded, as_hex is not a complete tx
+		Prunable_Hash  string                     `json:"prunable_hash,omitempty"` // only available if pruned
+
+		SC_Receipt *SC_Receipt `json:"sc_receipt,omitempty"` // only available for mined SC txs
+	}
+
+	// result of SC tx execution in the block in which it is valid
+	SC_Receipt struct {
+		SCID       string      `json:"scid"`
+		EntryPoint string      `json:"entrypoint"`
+		Value      uint64      `json:"value"`  // DERO sent to SC
+		Refund     uint64      `json:"refund"` // DERO paid back to signer as a spendable output
+		Success    bool        `json:"success"`
+		Error      string      `json:"error,omitempty"`
+		Return     SC_Variable `json:"return"` // type is Invalid if entrypoint did not execute
+		Gas_Used   uint64      `json:"gas_used"`
+		Lines      int64       `json:"lines"` // lines interpreted
 	}
 )
 
@@ -256,3 +281,234 @@ type (
 		Status string `json:"status"`
 	}
 )
+
+// websocket notifications, client sends a request on /ws to subscribe to events
+// events are new_block, new_tx, reorg and block_template
+// block_template requires wallet_address and reserve_size, same as getblocktemplate
+type (
+	WS_Request struct {
+		Method string              `json:"method"` // subscribe, unsubscribe or ping
+		Params WS_Subscribe_Params `json:"params"`
+	}
+	WS_Subscribe_Params struct {
+		Events         []string `json:"events"`
+		Wallet_Address string   `json:"wallet_address,omitempty"`
+		Reserve_size   uint64   `json:"reserve_size,omitempty"`
+	}
+
+	// every message sent by server, reply to requests carry method, notifications carry event
+	WS_Notification struct {
+		Method string      `json:"method,omitempty"`
+		Event  string      `json:"event,omitempty"`
+		Result interface{} `json:"result,omitempty"`
+		Error  string      `json:"error,omitempty"`
+	}
+
+	WS_NewTX_Result struct {
+		TXID string `json:"txid"`
+		Size uint64 `json:"size"`
+		Fee  uint64 `json:"fee"`
+	}
+
+	// blocks starting at topoheight have been reordered, removed blocks have empty new order
+	WS_Reorg_Result struct {
+		TopoHeight int64    `json:"topoheight"`
+		Old_Order  []string `json:"old_order"`
+		New_Order  []string `json:"new_order"`
+	}
+)
+
+// index queries, available only if daemon is running with --index
+const INDEX_DISABLED_STATUS = "Index is not enabled, start daemon with --index"
+
+type (
+	GetSCTransactions_Params struct {
+		SCID       string `json:"scid"`
+		TopoHeight int64  `json:"topoheight"` // txs at or above this topoheight are returned
+		Count      int    `json:"count"`      // maximum txs returned, defaults to 100
+	}
+	GetSCTransactions_Result struct {
+		Txs    []SC_Transaction_Info `json:"txs"`
+		Status string                `json:"status"`
+	}
+	SC_Transaction_Info struct {
+		TXID       string `json:"txid"`
+		TopoHeight int64  `json:"topoheight"`
+	}
+)
+
+type (
+	GetTXBlocks_Params struct {
+		TXID string `json:"txid"`
+	}
+	GetTXBlocks_Result struct {
+		Blocks []TX_Block_Info `json:"blocks"`
+		Status string          `json:"status"`
+	}
+	TX_Block_Info struct {
+		BLID       string `json:"blid"`
+		TopoHeight int64  `json:"topoheight"` // -1 if block is not ordered
+		Position   int64  `json:"position"`   // position within block, 0 is miner tx
+		Valid      bool   `json:"valid"`      // whether tx is valid in this block
+	}
+)
+
+type (
+	GetBlockMiner_Params struct {
+		Hash string `json:"hash"`
+	}
+	GetBlockMiner_Result struct {
+		Miner_TX      string `json:"miner_tx"`
+		TX_Public_Key string `json:"tx_public_key"`
+		Output_Key    string `json:"output_key"`
+		Status        string `json:"status"`
+	}
+)
+
+// runs an SC entrypoint against current chain state, nothing is persisted
+type (
+	SC_DryRun_Params struct {
+		SCID       string            `json:"scid"`
+		EntryPoint string            `json:"entrypoint"`
+		Params     map[string]string `json:"params"` // all params are passed as strings, same as SC tx
+		Value      uint64            `json:"value"`  // DERO attached to the call
+		Gas        uint64            `json:"gas"`    // gas limit, 0 runs with the maximum allowed by the node, required by restricted nodes
+		Signer     string            `json:"signer"` // address seen as SIGNER(), optional
+	}
+	SC_DryRun_Result struct {
+		Result    SC_Variable   `json:"result"`
+		Output    string        `json:"output"`    // PRINT output
+		Committed bool          `json:"committed"` // whether a real tx would have persisted the changes
+		Error     string        `json:"error,omitempty"`
+		Writes    []SC_Write    `json:"writes"`
+		Transfers []SC_Transfer `json:"transfers"`
+		Events    []SC_Event    `json:"events"`
+		Lines     int64         `json:"lines"`    // lines interpreted
+		Gas_Used  uint64        `json:"gas_used"` // gas consumed
+		Status    string        `json:"status"`
+	}
+
+	SC_Variable struct {
+		Type  string      `json:"type"`
+		Value interface{} `json:"value,omitempty"`
+	}
+	SC_Write struct {
+		SCID     string      `json:"scid"`
+		Key      SC_Variable `json:"key"`
+		Previous SC_Variable `json:"previous"` // type is Invalid if key did not exist
+		Value    SC_Variable `json:"value"`
+
+		Collection string `json:"collection,omitempty"` // Map or Array, if key is an element of a collection
+		Name       string `json:"name,omitempty"`       // name of collection, key is Invalid for collection length
+	}
+	SC_Transfer struct {
+		SCID          string                 `json:"scid"`
+		Balance_Start uint64                 `json:"balance_start"`
+		Balance       uint64                 `json:"balance"`
+		Received      []uint64               `json:"received"`
+		Sent          []uint64               `json:"sent"`
+		External      []SC_External_Transfer `json:"external"`
+	}
+	SC_External_Transfer struct {
+		Address string `json:"address"`
+		Amount  uint64 `json:"amount"`
+	}
+	SC_Event struct {
+		SCID string        `json:"scid"` // SC which emitted the event
+		Name string        `json:"name"`
+		Args []SC_Variable `json:"args"`
+	}
+)
+
+// events emitted by an SC using EMIT within a topoheight range, in chain order
+type (
+	GetSCEvents_Params struct {
+		SCID             string `json:"scid"`
+		Name             string `json:"name"` // only events with this name are returned, empty returns all
+		TopoHeight_Start int64  `json:"topoheight_start"`
+		TopoHeight_End   int64  `json:"topoheight_end"` // inclusive, range cannot exceed 1000 topoheights
+	}
+	GetSCEvents_Result struct {
+		Events []SC_Event_Info `json:"events"`
+		Status string          `json:"status"`
+	}
+	SC_Event_Info struct {
+		TXID       string   `json:"txid"`
+		TopoHeight int64    `json:"topoheight"`
+		Event      SC_Event `json:"event"`
+	}
+)
+
+// execution trace of an SC tx, available only if daemon is running with --sc-trace
+const SC_TRACE_DISABLED_STATUS = "SC tracing is not enabled, start daemon with --sc-trace"
+
+type (
+	TraceTransaction_Params struct {
+		TXID string `json:"txid"`
+	}
+	TraceTransaction_Result struct {
+		Entries   []SC_Trace_Entry `json:"entries"`
+		Lines     int64            `json:"lines"` // lines interpreted
+		Evals     int64            `json:"evals"` // expressions evaluated
+		Loads     uint64           `json:"loads"`
+		Stores    uint64           `json:"stores"`
+		Transfers uint64           `json:"transfers"`
+		Gas_Used  uint64           `json:"gas_used"`
+		Failure   string           `json:"failure,omitempty"` // empty if changes were committed
+		Truncated bool             `json:"truncated"`         // entries were dropped, counters are complete
+		Status    string           `json:"status"`
+	}
+	SC_Trace_Entry struct {
+		Kind     string `json:"kind"` // line, load, store, transfer, call, info or error
+		SCID     string `json:"scid"`
+		Function string `json:"function,omitempty"`
+		Line     uint64 `json:"line,omitempty"`
+		Message  string `json:"message"`
+	}
+)
+
+// reads SC source, functions, balance and stored values, topoheight >= 1 reads state as it was at that topoheight
+// historic state is only available for the last 1000 topoheights, and not at all on restricted nodes
+type (
+	GetSC_Params struct {
+		SCID       string   `json:"scid"`
+		Code       bool     `json:"code"` // return source code
+		KeysUint64 []uint64 `json:"keysuint64"`
+		KeysString []string `json:"keysstring"`
+		TopoHeight int64    `json:"topoheight"`
+	}
+	GetSC_Result struct {
+		TopoHeight   int64         `json:"topoheight"`
+		Code         string        `json:"code,omitempty"`
+		Functions    []SC_Function `json:"functions"`
+		Balance      uint64        `json:"balance"`
+		ValuesUint64 []SC_Variable `json:"valuesuint64"` // values of keysuint64 in same order, type is Invalid if key does not exist
+		ValuesString []SC_Variable `json:"valuesstring"` // values of keysstring in same order, type is Invalid if key does not exist
+		Status       string        `json:"status"`
+	}
+
+	SC_Function struct {
+		Name   string        `json:"name"`
+		Params []SC_Variable `json:"params"` // type and name of params, value is name
+		Return string        `json:"return"` // type of return value
+	}
+)
+
+// complete SC state as it was at the end of topoheight, reconstructed from SC changelogs
+// keys are returned as hashes, since original keys are not stored
+type (
+	GetSCState_Params struct {
+		SCID       string `json:"scid"`
+		TopoHeight int64  `json:"topoheight"` // 0 or below gives current state, historic state as with getsc
+	}
+	GetSCState_Result struct {
+		TopoHeight int64         `json:"topoheight"`
+		Balance    uint64        `json:"balance"`
+		Values     []SC_KeyValue `json:"values"` // ordered by key hash, balance is not included
+		Status     string        `json:"status"`
+	}
+	SC_KeyValue struct {
+		Key   string      `json:"key"` // keccak256 of serialized key
+		Value SC_Variable `json:"value"`
+	}
+)
@@ -30,6 +30,7 @@ type SC_Transaction struct {
 	SCID       crypto.Key        `msgpack:"I,omitempty" json:"scid,omitempty"` // to which smart contract is the entrypoint directed, 64 bytes hex
 	EntryPoint string            `msgpack:"E,omitempty" json:"entrypoint,omitempty"`
 	Params     map[string]string `msgpack:"P,omitempty" json:"params,omitempty"` // all parameters in named form
+	Gas        uint64            `msgpack:"G,omitempty" json:"gas,omitempty"`    // gas limit, paid from value, required
 
 	Value uint64 `msgpack:"-" json:"value,omitempty"` // DERO to transfer to SC
 }
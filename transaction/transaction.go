@@ -210,6 +210,15 @@ func (tx *Transaction) IsCoinbase() (result bool) {
 }
 
 func (tx *Transaction) DeserializeHeader(buf []byte) (err error) {
+	return tx.deserialize(buf, nil)
+}
+
+// deserialize a tx whose prunable part has been discarded, the prunable hash is required to calculate tx hash
+func (tx *Transaction) DeserializeHeaderPruned(buf []byte, prunable_hash crypto.Hash) (err error) {
+	return tx.deserialize(buf, &prunable_hash)
+}
+
+func (tx *Transaction) deserialize(buf []byte, prunable_hash *crypto.Hash) (err error) {
 
 	Key_offset_count := uint64(0) // used to calculate expected signatures in v1
 
@@ -449,7 +458,11 @@ func (tx *Transaction) DeserializeHeader(buf []byte) (err error) {
 
 		Mixin -= 1 // one is ours, rest are mixin
 
-		tx.RctSignature, err = ringct.ParseRingCtSignature(bufreader, len(tx.Vin), len(tx.Vout), Mixin)
+		if prunable_hash != nil {
+			tx.RctSignature, err = ringct.ParseRingCtSignaturePruned(bufreader, len(tx.Vin), len(tx.Vout), *prunable_hash)
+		} else {
+			tx.RctSignature, err = ringct.ParseRingCtSignature(bufreader, len(tx.Vin), len(tx.Vout), Mixin)
+		}
 		if err != nil {
 			return err
 		}
@@ -683,6 +696,12 @@ func (tx *Transaction) SerializeHeader() []byte {
 
 }
 
+// whether the prunable part of the tx has been discarded
+// serialized form of a pruned tx is not a valid tx and must never be relayed
+func (tx *Transaction) IsPruned() bool {
+	return tx.Version == 2 && tx.RctSignature != nil && tx.RctSignature.IsPruned()
+}
+
 // serialize entire transaction include signature
 func (tx *Transaction) Serialize() []byte {
 